@@ -3,10 +3,16 @@ package main
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
 	"fmt"
+	"io"
+	"path/filepath"
 
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -15,12 +21,21 @@ import (
 
 	pb "github.com/ayushsarode/termiXchat/proto"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 const (
-	serverAddr = "localhost:50051"
-	timeFormat = "15:04:05"
+	serverAddr     = "localhost:50051"
+	timeFormat     = "15:04:05"
+	dateTimeFormat = "2006-01-02 15:04"
+	// exportTimeFormat keeps seconds so transcripts can be lined up with logs
+	exportTimeFormat = "2006-01-02 15:04:05"
+	// maxRecentMessages bounds the messages kept for quoting in replies
+	maxRecentMessages = 500
 )
 
 // cmd colors
@@ -43,11 +58,39 @@ type chatClient struct {
 	conn      *grpc.ClientConn
 	userID    int32
 	username  string
+	// session token attached to every call by the interceptors
+	token     string
 	roomID    int32
 	roomName  string
+	// password sent when joining a room, members do not need one
+	roomPassword string
+	// oldest message shown by /history, used as the cursor for the next page
+	historyBefore int32
+	// the last message sent, which /edit and /delete call "last"
+	lastMessageID int32
+	// room messages seen lately, quoted above replies to them
+	recent    map[int32]*pb.ReceiveMessageResponse
+	// read position waiting to be sent, and who was reported to have read
+	// the last message sent
+	read   readMarker
+	seenBy map[string]int32
 	inputChan chan string
+	// password changes collected by the input goroutine
+	passwdChan chan passwordChange
+	// confirmed account deletions collected by the input goroutine
+	deleteChan chan accountDeletion
 	msgChan   chan *pb.ReceiveMessageResponse
 	errChan   chan error
+	// input reads what the user types and knows the line typed so far
+	input *lineReader
+	// typing signals from the input goroutine and the last one it sent
+	typingChan chan bool
+	lastTyping typingSignal
+	// who is typing in the room, received on the typing stream
+	typingUpdates chan *pb.TypingUpdate
+	typing        typingIndicator
+	// keys for end-to-end encrypted direct messages
+	e2e *e2eKeys
 }
 
 func main() {
@@ -55,9 +98,15 @@ func main() {
 	displayColorZenithLogo()
 	
 	chat := &chatClient{
-		inputChan: make(chan string),
-		msgChan:   make(chan *pb.ReceiveMessageResponse),
-		errChan:   make(chan error),
+		recent:        make(map[int32]*pb.ReceiveMessageResponse),
+		seenBy:        make(map[string]int32),
+		inputChan:     make(chan string),
+		passwdChan:    make(chan passwordChange),
+		deleteChan:    make(chan accountDeletion),
+		msgChan:       make(chan *pb.ReceiveMessageResponse),
+		errChan:       make(chan error),
+		typingChan:    make(chan bool),
+		typingUpdates: make(chan *pb.TypingUpdate),
 	}
 	
 	// connecting to server
@@ -72,7 +121,17 @@ serverPort := os.Getenv("SERVER_PORT")
 if serverPort == "" {
 	serverPort = "50051"
 }
-	conn, err := grpc.NewClient(serverHost + ":" + serverPort, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	chat.e2e = newE2EKeys(serverHost, serverPort)
+	creds, err := transportCredentials()
+	if err != nil {
+		fmt.Printf("%s❌ Invalid TLS settings: %v%s\n", colorRed, err, colorReset)
+		return
+	}
+	conn, err := grpc.NewClient(serverHost + ":" + serverPort,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithUnaryInterceptor(chat.unaryInterceptor),
+		grpc.WithStreamInterceptor(chat.streamInterceptor),
+	)
 	if err != nil {
 		fmt.Printf("%s❌ Failed to connect to server: %v%s\n", colorRed, err, colorReset)
 		return
@@ -90,6 +149,7 @@ if serverPort == "" {
 		fmt.Printf("\n%s👋 Disconnecting from chat server...%s\n", colorYellow, colorReset)
 		conn.Close()
 		// Move cursor to bottom of screen and restore terminal
+		chat.restoreTerminal()
 		fmt.Print("\033[r\033[999;999H\n")
 		os.Exit(0)
 	}()
@@ -98,6 +158,7 @@ if serverPort == "" {
 	if !chat.authenticate() {
 		return
 	}
+	chat.loadE2E()
 	
 	if !chat.setupRoom() {
 		return
@@ -107,6 +168,9 @@ if serverPort == "" {
 	clearScreen()
 	chat.displayChatHeader()
 	
+	// read keys as they are pressed from here on, for typing indicators
+	chat.input = newLineReader(os.Stdin, chat.noteTyping)
+	
 	// message receiver in a goroutine
 	go chat.receiveMessages()
 	
@@ -117,14 +181,56 @@ if serverPort == "" {
 	chat.messageLoop()
 }
 
+// transportCredentials configures TLS from the environment. TLS is used when
+// TLS_ENABLED is true or a custom CA is given in TLS_CA_FILE. TLS_SERVER_NAME
+// overrides the name checked against the server certificate, and
+// TLS_CERT_FILE with TLS_KEY_FILE present a client certificate for mutual TLS.
+func transportCredentials() (credentials.TransportCredentials, error) {
+	caFile := os.Getenv("TLS_CA_FILE")
+	if os.Getenv("TLS_ENABLED") != "true" && caFile == "" {
+		return insecure.NewCredentials(), nil
+	}
+
+	cfg := &tls.Config{
+		ServerName: os.Getenv("TLS_SERVER_NAME"),
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if caFile != "" {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %v", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+	}
+
+	certFile, keyFile := os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE")
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return credentials.NewTLS(cfg), nil
+}
+
 func (c *chatClient) authenticate() bool {
 	reader := bufio.NewReader(os.Stdin)
 	
-	fmt.Println("\n1. Create new account\n2. Login to existing account")
-	fmt.Print("Choose an option (1/2): ")
+	fmt.Println("\n1. Create new account\n2. Login to existing account\n3. Reset password with a code")
+	fmt.Print("Choose an option (1/2/3): ")
 	option, _ := reader.ReadString('\n')
 	option = strings.TrimSpace(option)
 	
+	if option == "3" {
+		return c.redeemResetCode(reader)
+	}
+	
 	var username, password string
 	var err error
 	
@@ -176,21 +282,159 @@ func (c *chatClient) authenticate() bool {
 	
 	c.userID = userResp.UserId
 	c.username = username
+	c.token = userResp.Token
+	
+	return true
+}
+
+// redeemResetCode sets a new password with a code issued by an admin and
+// logs in with it
+func (c *chatClient) redeemResetCode(reader *bufio.Reader) bool {
+	fmt.Print("Enter your reset code: ")
+	code, _ := reader.ReadString('\n')
+	code = strings.TrimSpace(code)
+	
+	password, ok := readNewPassword()
+	if !ok {
+		return false
+	}
+	
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	
+	resetResp, err := c.client.CompletePasswordReset(ctx, &pb.CompletePasswordResetRequest{
+		ResetCode:   code,
+		NewPassword: password,
+	})
+	if err != nil {
+		fmt.Printf("%s❌ Password reset failed: %v%s\n", colorRed, err, colorReset)
+		return false
+	}
+	
+	userResp, err := c.client.LoginUser(ctx, &pb.LoginUserRequest{Username: resetResp.Username, Password: password})
+	if err != nil {
+		fmt.Printf("%s❌ Login failed: %v%s\n", colorRed, err, colorReset)
+		return false
+	}
+	fmt.Printf("%s✅ Password reset, logged in as %s!%s\n", colorGreen, resetResp.Username, colorReset)
+	
+	c.userID = userResp.UserId
+	c.username = userResp.Username
+	c.token = userResp.Token
 	
 	return true
 }
 
+// readNewPassword prompts twice for a new password without echoing it
+func readNewPassword() (string, bool) {
+	fmt.Print("Enter your new password: ")
+	password, err := term.ReadPassword(int(syscall.Stdin))
+	fmt.Println()
+	if err != nil {
+		fmt.Printf("%s❌ Error reading password: %v%s\n", colorRed, err, colorReset)
+		return "", false
+	}
+	if len(password) == 0 {
+		fmt.Printf("%s❌ Password cannot be empty.%s\n", colorRed, colorReset)
+		return "", false
+	}
+	
+	fmt.Print("Confirm your new password: ")
+	confirm, err := term.ReadPassword(int(syscall.Stdin))
+	fmt.Println()
+	if err != nil {
+		fmt.Printf("%s❌ Error reading password: %v%s\n", colorRed, err, colorReset)
+		return "", false
+	}
+	if string(confirm) != string(password) {
+		fmt.Printf("%s❌ Passwords do not match.%s\n", colorRed, colorReset)
+		return "", false
+	}
+	
+	return string(password), true
+}
+
+// withToken adds the session token to the outgoing metadata once the user
+// has logged in
+// parseVisibility reads a room visibility name, empty meaning public
+func parseVisibility(name string) (pb.RoomVisibility, bool) {
+	switch strings.ToLower(name) {
+	case "", "public":
+		return pb.RoomVisibility_ROOM_VISIBILITY_PUBLIC, true
+	case "unlisted":
+		return pb.RoomVisibility_ROOM_VISIBILITY_UNLISTED, true
+	case "private":
+		return pb.RoomVisibility_ROOM_VISIBILITY_PRIVATE, true
+	}
+	return 0, false
+}
+
+// roomTags describes how a room can be joined for room listings
+func roomTags(visibility pb.RoomVisibility, hasPassword bool) string {
+	var tags string
+	switch visibility {
+	case pb.RoomVisibility_ROOM_VISIBILITY_UNLISTED:
+		tags += " [unlisted]"
+	case pb.RoomVisibility_ROOM_VISIBILITY_PRIVATE:
+		tags += " [private]"
+	}
+	if hasPassword {
+		tags += " [password]"
+	}
+	return tags
+}
+
+// readRoomPassword prompts for a room password without echoing it
+func readRoomPassword(prompt string) (string, bool) {
+	fmt.Print(prompt)
+	password, err := term.ReadPassword(int(syscall.Stdin))
+	fmt.Println()
+	if err != nil {
+		fmt.Printf("%s❌ Error reading password: %v%s\n", colorRed, err, colorReset)
+		return "", false
+	}
+	return string(password), true
+}
+
+func (c *chatClient) withToken(ctx context.Context) context.Context {
+	if c.token == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+c.token)
+}
+
+// unaryInterceptor authenticates calls and turns rate limit rejections into
+// a message saying how long to wait. Only throttled calls carry a
+// retry-after trailer, other ResourceExhausted errors are passed on as is.
+func (c *chatClient) unaryInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	var trailer metadata.MD
+	err := invoker(c.withToken(ctx), method, req, reply, cc, append(opts, grpc.Trailer(&trailer))...)
+	retryAfter := trailer.Get("retry-after")
+	if status.Code(err) != codes.ResourceExhausted || len(retryAfter) == 0 {
+		return err
+	}
+	
+	wait := "a moment"
+	// lockouts run for minutes, so show the wait as a duration
+	if secs, err := strconv.Atoi(retryAfter[0]); err == nil {
+		wait = (time.Duration(secs) * time.Second).String()
+	}
+	return fmt.Errorf("slow down, try again in %s", wait)
+}
+
+func (c *chatClient) streamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamer(c.withToken(ctx), desc, cc, method, opts...)
+}
+
 func (c *chatClient) setupRoom() bool {
 	reader := bufio.NewReader(os.Stdin)
 	
-	fmt.Println("\n1. Create a new room\n2. Join an existing room\n3. List available rooms")
-	fmt.Print("Choose an option (1/2/3): ")
+	fmt.Println("\n1. Create a new room\n2. Join an existing room\n3. List available rooms\n4. Accept an invite code")
+	fmt.Print("Choose an option (1/2/3/4): ")
 	option, _ := reader.ReadString('\n')
 	option = strings.TrimSpace(option)
 	
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	
+	ok := true
 	switch option {
 	case "1":
 		
@@ -203,7 +447,26 @@ func (c *chatClient) setupRoom() bool {
 			return false
 		}
 		
-		roomResp, err := c.client.CreateRoom(ctx, &pb.CreateRoomRequest{Name: roomName})
+		fmt.Print("Visibility (public/unlisted/private) [public]: ")
+		visibilityName, _ := reader.ReadString('\n')
+		visibility, ok := parseVisibility(strings.TrimSpace(visibilityName))
+		if !ok {
+			fmt.Printf("%s❌ Visibility must be public, unlisted or private.%s\n", colorRed, colorReset)
+			return false
+		}
+		
+		password, ok := readRoomPassword("Room password (leave empty for none): ")
+		if !ok {
+			return false
+		}
+		
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		roomResp, err := c.client.CreateRoom(ctx, &pb.CreateRoomRequest{
+			Name:       roomName,
+			Visibility: visibility,
+			Password:   password,
+		})
+		cancel()
 		if err != nil {
 			fmt.Printf("%s❌ Could not create room: %v%s\n", colorRed, err, colorReset)
 			return false
@@ -226,19 +489,29 @@ func (c *chatClient) setupRoom() bool {
 		reader.ReadString('\n')
 		
 		// Get room info
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		roomInfo, err := c.client.GetRoomInfo(ctx, &pb.GetRoomInfoRequest{RoomId: roomID})
+		cancel()
 		if err != nil {
 			fmt.Printf("%s❌ Failed to get room info: %v%s\n", colorRed, err, colorReset)
 			return false
 		}
 		
+		if roomInfo.HasPassword {
+			if c.roomPassword, ok = readRoomPassword("Room password (leave empty if you are a member): "); !ok {
+				return false
+			}
+		}
+		
 		c.roomID = roomID
 		c.roomName = roomInfo.Name
 		fmt.Printf("%s✅ Joined room '%s' with ID: %d%s\n", colorGreen, c.roomName, c.roomID, colorReset)
 		
 	case "3":
 		
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		rooms, err := c.client.ListRooms(ctx, &pb.ListRoomsRequest{})
+		cancel()
 		if err != nil {
 			fmt.Printf("%s❌ Failed to list rooms: %v%s\n", colorRed, err, colorReset)
 			return false
@@ -247,7 +520,7 @@ func (c *chatClient) setupRoom() bool {
 		fmt.Println("\nAvailable Rooms:")
 		fmt.Println("----------------------------------")
 		for _, room := range rooms.Rooms {
-			fmt.Printf("ID: %d | Name: %s | Users: %d\n", room.RoomId, room.Name, room.UserCount)
+			fmt.Printf("ID: %d | Name: %s | Users: %d%s\n", room.RoomId, room.Name, room.UserCount, roomTags(room.Visibility, room.HasPassword))
 		}
 		fmt.Println("----------------------------------")
 		
@@ -267,6 +540,11 @@ func (c *chatClient) setupRoom() bool {
 		for _, room := range rooms.Rooms {
 			if room.RoomId == roomID {
 				roomName = room.Name
+				if room.HasPassword {
+					if c.roomPassword, ok = readRoomPassword("Room password (leave empty if you are a member): "); !ok {
+						return false
+					}
+				}
 				break
 			}
 		}
@@ -275,6 +553,22 @@ func (c *chatClient) setupRoom() bool {
 		c.roomName = roomName
 		fmt.Printf("%s✅ Joined room '%s' with ID: %d%s\n", colorGreen, c.roomName, c.roomID, colorReset)
 		
+	case "4":
+		fmt.Print("Enter invite code: ")
+		code, _ := reader.ReadString('\n')
+		
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		inviteResp, err := c.client.AcceptInvite(ctx, &pb.AcceptInviteRequest{InviteCode: strings.TrimSpace(code)})
+		cancel()
+		if err != nil {
+			fmt.Printf("%s❌ Could not accept invite: %v%s\n", colorRed, err, colorReset)
+			return false
+		}
+		
+		c.roomID = inviteResp.RoomId
+		c.roomName = inviteResp.RoomName
+		fmt.Printf("%s✅ Joined room '%s' with ID: %d%s\n", colorGreen, c.roomName, c.roomID, colorReset)
+		
 	default:
 		fmt.Printf("%s❌ Invalid option.%s\n", colorRed, colorReset)
 		return false
@@ -339,8 +633,8 @@ func (c *chatClient) displayChatHeader() {
 func (c *chatClient) receiveMessages() {
 	ctx := context.Background()
 	stream, err := c.client.JoinRoom(ctx, &pb.JoinRoomRequest{
-		UserId: c.userID,
-		RoomId: c.roomID,
+		RoomId:   c.roomID,
+		Password: c.roomPassword,
 	})
 	
 	if err != nil {
@@ -360,6 +654,15 @@ func (c *chatClient) receiveMessages() {
 	for {
 		resp, err := stream.Recv()
 		if err != nil {
+			// leaving the room ends the stream cleanly
+			if err == io.EOF {
+				return
+			}
+			// kicks, bans and refused joins end the stream
+			if status.Code(err) == codes.PermissionDenied {
+				c.errChan <- fmt.Errorf("%s, use /join to enter a room", status.Convert(err).Message())
+				return
+			}
 			c.errChan <- fmt.Errorf("error receiving message: %v", err)
 			return
 		}
@@ -368,20 +671,78 @@ func (c *chatClient) receiveMessages() {
 }
 
 func (c *chatClient) handleUserInput() {
-	reader := bufio.NewReader(os.Stdin)
-	
 	for {
-		input, err := reader.ReadString('\n')
+		input, err := c.input.readLine()
 		if err != nil {
 			c.errChan <- fmt.Errorf("error reading input: %v", err)
 			return
 		}
+		// the line is done, whether it is sent or not
+		c.noteTyping(false)
 		
 		input = strings.TrimSpace(input)
+		
+		// the password prompts read stdin themselves, so they have to run
+		// here rather than in messageLoop while this loop waits on a line
+		if input == "/passwd" {
+			c.promptPasswordChange()
+			continue
+		}
+		if input == "/deleteaccount" {
+			c.promptAccountDeletion()
+			continue
+		}
+		
 		c.inputChan <- input
 	}
 }
 
+// promptPasswordChange asks for the current and new password and queues the
+// change for messageLoop
+func (c *chatClient) promptPasswordChange() {
+	fmt.Print("\r\033[KEnter your current password: ")
+	current, err := term.ReadPassword(int(syscall.Stdin))
+	fmt.Println()
+	if err != nil {
+		c.errChan <- fmt.Errorf("error reading password: %v", err)
+		return
+	}
+	
+	password, ok := readNewPassword()
+	if !ok {
+		fmt.Print("> ")
+		return
+	}
+	
+	c.passwdChan <- passwordChange{current: string(current), new: password}
+}
+
+// promptAccountDeletion asks for the password and whether to delete the
+// user's messages too, and queues the deletion for messageLoop once confirmed
+func (c *chatClient) promptAccountDeletion() {
+	fmt.Printf("\r\033[K%s⚠️  This deletes your account and cannot be undone.%s\n", colorYellow, colorReset)
+	fmt.Print("Enter your password: ")
+	password, err := term.ReadPassword(int(syscall.Stdin))
+	fmt.Println()
+	if err != nil {
+		c.errChan <- fmt.Errorf("error reading password: %v", err)
+		return
+	}
+	
+	fmt.Print("Also delete every message you sent? (y/N): ")
+	answer, _ := c.input.readAnswer()
+	deleteMessages := strings.EqualFold(strings.TrimSpace(answer), "y")
+	
+	fmt.Printf("Type %s to confirm: ", c.username)
+	confirm, _ := c.input.readAnswer()
+	if strings.TrimSpace(confirm) != c.username {
+		fmt.Printf("%sAccount deletion cancelled%s\n> ", colorYellow, colorReset)
+		return
+	}
+	
+	c.deleteChan <- accountDeletion{password: string(password), deleteMessages: deleteMessages}
+}
+
 func (c *chatClient) messageLoop() {
 	
 	fmt.Print("> ")
@@ -402,8 +763,7 @@ func (c *chatClient) messageLoop() {
 			
 			// normal message
 			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			_, err := c.client.SendMessage(ctx, &pb.SendMessageRequest{
-				UserId:  c.userID,
+			resp, err := c.client.SendMessage(ctx, &pb.SendMessageRequest{
 				RoomId:  c.roomID,
 				Message: input,
 			})
@@ -412,27 +772,56 @@ func (c *chatClient) messageLoop() {
 			if err != nil {
 				fmt.Printf("\r\033[K%s❌ Error sending message: %v%s\n> ", colorRed, err, colorReset)
 			} else {
-				
+				c.lastMessageID = resp.MessageId
 				fmt.Print("\r\033[K> ")
 			}
 			
-		case msg := <-c.msgChan:
+		case change := <-c.passwdChan:
+			c.changePassword(change)
 			
-			timestamp := time.Unix(msg.Timestamp, 0).Format(timeFormat)
+		case deletion := <-c.deleteChan:
+			c.deleteAccount(deletion)
+			
+		case typing := <-c.typingChan:
+			c.sendTyping(typing)
+			
+		case update := <-c.typingUpdates:
+			c.showTyping(update)
+			
+		case <-c.read.due:
+			c.markRead()
+			
+		case msg := <-c.msgChan:
+			// the first message from the room means the server has let us
+			// in, so typing can be watched there
+			if msg.MessageId > 0 && c.typing.roomID != c.roomID {
+				c.openTyping()
+			}
+			if msg.Event == pb.MessageEvent_MESSAGE_EVENT_NEW {
+				c.stoppedTyping(msg.Username)
+			}
+			// read positions are not messages to show
+			if msg.Event == pb.MessageEvent_MESSAGE_EVENT_READ {
+				c.showReadBy(msg)
+				continue
+			}
 			
 			// clear current input line
 			fmt.Print("\r\033[K")
-			
-			// Format based on message type
-			if msg.IsSystem {
-				fmt.Printf("%s[%s] %s%s\n> ", colorGray, timestamp, msg.Message, colorReset)
-			} else if msg.Username == c.username {
-				// Own messages (Green username, white message)
-				fmt.Printf("%s[%s] %s%s[You]:%s %s\n> ", colorGray, timestamp, colorBlue, msg.Username, colorReset, msg.Message)
+			if msg.ReplyToMessageId != 0 && msg.Event == pb.MessageEvent_MESSAGE_EVENT_NEW {
+				fmt.Println(c.quoteMessage(msg.ReplyToMessageId))
+			}
+			c.remember(msg)
+			// reaction changes only show the new counts, not the message again
+			if msg.Event == pb.MessageEvent_MESSAGE_EVENT_REACTIONS {
+				fmt.Printf("%sReactions on #%d:%s %s\n", colorGray, msg.MessageId, colorReset, formatReactions(msg.Reactions))
 			} else {
-				// Others' messages (Blue username, white message)
-				fmt.Printf("%s[%s] %s%s:%s %s\n> ", colorGray, timestamp, colorPurple, msg.Username, colorReset, msg.Message)
+				fmt.Println(c.formatMessage(msg))
+			}
+			if !msg.IsDirect && msg.MessageId > 0 {
+				c.noteRead(msg.MessageId)
 			}
+			c.redrawPrompt()
 			
 		case err := <-c.errChan:
 			fmt.Printf("\r\033[K%s❌ Error: %v%s\n> ", colorRed, err, colorReset)
@@ -440,6 +829,50 @@ func (c *chatClient) messageLoop() {
 	}
 }
 
+// formatMessage renders a single chat line without the trailing newline
+func (c *chatClient) formatMessage(msg *pb.ReceiveMessageResponse) string {
+	timestamp := time.Unix(msg.Timestamp, 0).Format(timeFormat)
+	
+	// Format based on message type
+	if msg.IsSystem {
+		return fmt.Sprintf("%s[%s] %s%s", colorGray, timestamp, msg.Message, colorReset)
+	} else if msg.IsDirect {
+		text := msg.Message
+		if msg.Encrypted {
+			text = c.decryptFrom(msg.Username, msg.Username, msg.Message)
+		}
+		return fmt.Sprintf("%s[%s] %s(DM from %s):%s %s", colorGray, timestamp, colorPurple, msg.Username, colorReset, text)
+	}
+	
+	// room messages show their ID so /edit, /delete and /reply can name
+	// them, and replies the thread they belong to
+	id := fmt.Sprintf("#%d", msg.MessageId)
+	if msg.ReplyToMessageId != 0 {
+		id += fmt.Sprintf(" ↪ #%d", msg.ReplyToMessageId)
+	}
+	text := msg.Message
+	if msg.Deleted {
+		text = colorGray + "(deleted)" + colorReset
+	} else if msg.EditedAt != 0 {
+		text += colorGray + " (edited)" + colorReset
+	}
+	if msg.ReplyCount == 1 {
+		text += colorGray + " (1 reply)" + colorReset
+	} else if msg.ReplyCount > 1 {
+		text += fmt.Sprintf("%s (%d replies)%s", colorGray, msg.ReplyCount, colorReset)
+	}
+	// reactions go on a line of their own under the message
+	if len(msg.Reactions) > 0 {
+		text += "\n    " + formatReactions(msg.Reactions)
+	}
+	if msg.Username == c.username {
+		// Own messages (Green username, white message)
+		return fmt.Sprintf("%s[%s %s] %s%s[You]:%s %s", colorGray, timestamp, id, colorBlue, msg.Username, colorReset, text)
+	}
+	// Others' messages (Blue username, white message)
+	return fmt.Sprintf("%s[%s %s] %s%s:%s %s", colorGray, timestamp, id, colorPurple, msg.Username, colorReset, text)
+}
+
 func (c *chatClient) handleCommand(cmd string) {
 	parts := strings.Fields(cmd)
 	if len(parts) == 0 {
@@ -453,6 +886,8 @@ func (c *chatClient) handleCommand(cmd string) {
 		
 	case "/quit", "/exit":
 		fmt.Printf("\n%s👋 Thanks for using Zenith!%s\n", colorYellow, colorReset)
+		c.markRead()
+		c.restoreTerminal()
 		c.conn.Close()
 		os.Exit(0)
 		
@@ -480,12 +915,54 @@ func (c *chatClient) handleCommand(cmd string) {
 		newName := parts[1]
 		c.changeUsername(newName)
 		
+	case "/resetpw":
+		if len(parts) < 2 {
+			fmt.Printf("\r\033[K%s❌ Usage: /resetpw <username>%s\n> ", colorRed, colorReset)
+			return
+		}
+		c.resetPassword(parts[1])
+		
+	case "/unlock":
+		if len(parts) < 2 {
+			fmt.Printf("\r\033[K%s❌ Usage: /unlock <username>%s\n> ", colorRed, colorReset)
+			return
+		}
+		c.unlockAccount(parts[1])
+		
+	case "/audit":
+		// an optional action or category, then key=value filters
+		req := &pb.ListAuditEventsRequest{}
+		for _, arg := range parts[1:] {
+			key, value, _ := strings.Cut(arg, "=")
+			var err error
+			switch key {
+			case "by":
+				req.Actor = value
+			case "on":
+				req.Target = value
+			case "room":
+				_, err = fmt.Sscanf(value, "%d", &req.RoomId)
+			case "before":
+				_, err = fmt.Sscanf(value, "%d", &req.BeforeId)
+			default:
+				if value != "" || req.Action != "" {
+					err = fmt.Errorf("unknown filter %s", arg)
+				}
+				req.Action = arg
+			}
+			if err != nil {
+				fmt.Printf("\r\033[K%s❌ Usage: /audit [action] [by=<user>] [on=<user>] [room=<id>] [before=<id>]%s\n> ", colorRed, colorReset)
+				return
+			}
+		}
+		c.listAuditEvents(req)
+		
 	case "/rooms":
 		c.listRooms()
 		
 	case "/join":
 		if len(parts) < 2 {
-			fmt.Printf("\r\033[K%s❌ Usage: /join <room_id>%s\n> ", colorRed, colorReset)
+			fmt.Printf("\r\033[K%s❌ Usage: /join <room_id> [password]%s\n> ", colorRed, colorReset)
 			return
 		}
 		var roomID int32
@@ -494,116 +971,1330 @@ func (c *chatClient) handleCommand(cmd string) {
 			fmt.Printf("\r\033[K%s❌ Invalid room ID%s\n> ", colorRed, colorReset)
 			return
 		}
-		c.changeRoom(roomID)
+		password := ""
+		if len(parts) > 2 {
+			password = parts[2]
+		}
+		c.changeRoom(roomID, password)
 
-	default:
-		fmt.Printf("\r\033[K%s❌ Unknown command: %s. Type /help for available commands.%s\n> ", colorRed, parts[0], colorReset)
-	}
-}
+	case "/access":
+		if len(parts) < 2 {
+			fmt.Printf("\r\033[K%s❌ Usage: /access <public|unlisted|private> [password]%s\n> ", colorRed, colorReset)
+			return
+		}
+		visibility, ok := parseVisibility(parts[1])
+		if !ok {
+			fmt.Printf("\r\033[K%s❌ Visibility must be public, unlisted or private%s\n> ", colorRed, colorReset)
+			return
+		}
+		password := ""
+		if len(parts) > 2 {
+			password = parts[2]
+		}
+		c.setRoomAccess(visibility, password)
 
-func (c *chatClient) displayHelp() {
-	fmt.Print("\r\033[K")
-	fmt.Printf("%s\n╔════════════════════════════════════════╗\n", colorCyan)
-	fmt.Printf("║%s             AVAILABLE COMMANDS           %s║\n", colorYellow+colorBold, colorReset+colorCyan)
-	fmt.Printf("╠════════════════════════════════════════╣\n")
-	fmt.Printf("║ /help    - Show this help message      ║\n")
-	fmt.Printf("║ /quit    - Exit the chat application   ║\n")
-	fmt.Printf("║ /clear   - Clear the screen            ║\n")
-	fmt.Printf("║ /users   - List users in current room  ║\n")
-	fmt.Printf("║ /dm <user> <msg> - Send private message║\n")
-	fmt.Printf("║ /nick <name> - Change your username    ║\n")
-	fmt.Printf("║ /rooms   - List available rooms        ║\n")
-	fmt.Printf("║ /join <id> - Join a different room     ║\n")
-	fmt.Printf("╚════════════════════════════════════════╝%s\n", colorReset)
-}
+	case "/invite":
+		// optional lifetime in hours and number of uses, 0 leaves them open
+		var hours, uses int
+		if len(parts) > 1 {
+			if _, err := fmt.Sscanf(parts[1], "%d", &hours); err != nil || hours < 0 {
+				fmt.Printf("\r\033[K%s❌ Usage: /invite [hours] [uses]%s\n> ", colorRed, colorReset)
+				return
+			}
+		}
+		if len(parts) > 2 {
+			if _, err := fmt.Sscanf(parts[2], "%d", &uses); err != nil || uses < 0 {
+				fmt.Printf("\r\033[K%s❌ Usage: /invite [hours] [uses]%s\n> ", colorRed, colorReset)
+				return
+			}
+		}
+		c.createInvite(time.Duration(hours)*time.Hour, int32(uses))
 
-func (c *chatClient) listUsers() {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	resp, err := c.client.ListUsers(ctx, &pb.ListUsersRequest{RoomId: c.roomID})
-	cancel()
-	
-	if err != nil {
-		fmt.Printf("\r\033[K%s❌ Error listing users: %v%s\n> ", colorRed, err, colorReset)
-		return
-	}
-	
-	fmt.Print("\r\033[K")
-	fmt.Printf("%s\n══════ Users in %s ══════\n", colorYellow, c.roomName)
-	for _, user := range resp.Users {
-		status := "🟢 Online"
-		if user.Username == c.username {
-			fmt.Printf("  %s%s (you)%s - %s\n", colorGreen, user.Username, colorReset, status)
-		} else {
-			fmt.Printf("  %s - %s\n", user.Username, status)
+	case "/accept":
+		if len(parts) < 2 {
+			fmt.Printf("\r\033[K%s❌ Usage: /accept <invite_code>%s\n> ", colorRed, colorReset)
+			return
 		}
-	}
-	fmt.Printf("══════ Total: %d users ══════%s\n", len(resp.Users), colorReset)
-	fmt.Print("> ")
-}
+		c.acceptInvite(strings.Join(parts[1:], ""))
 
-func (c *chatClient) sendDirectMessage(recipient, message string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	_, err := c.client.SendDirectMessage(ctx, &pb.SendDirectMessageRequest{
-		SenderId:    c.userID,
-		RecipientUsername: recipient,
-		Message:     message,
-	})
-	cancel()
-	
-	if err != nil {
-		fmt.Printf("\r\033[K%s❌ Error sending DM: %v%s\n> ", colorRed, err, colorReset)
-		return
-	}
-	
-	fmt.Printf("\r\033[K%s[%s] %s(DM to %s): %s%s\n> ", 
-		colorGray, time.Now().Format(timeFormat), 
-		colorPurple, recipient, colorReset, message)
-}
+	case "/knock":
+		if len(parts) < 2 {
+			fmt.Printf("\r\033[K%s❌ Usage: /knock <room_id>%s\n> ", colorRed, colorReset)
+			return
+		}
+		var roomID int32
+		if _, err := fmt.Sscanf(parts[1], "%d", &roomID); err != nil {
+			fmt.Printf("\r\033[K%s❌ Invalid room ID%s\n> ", colorRed, colorReset)
+			return
+		}
+		c.requestJoin(roomID)
 
-func (c *chatClient) changeUsername(newName string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	_, err := c.client.ChangeUsername(ctx, &pb.ChangeUsernameRequest{
-		UserId:      c.userID,
-		NewUsername: newName,
-	})
-	cancel()
-	
-	if err != nil {
-		fmt.Printf("\r\033[K%s❌ Error changing username: %v%s\n> ", colorRed, err, colorReset)
-		return
-	}
-	
-	oldName := c.username
-	c.username = newName
-	fmt.Printf("\r\033[K%sSystem: Username changed from %s to %s%s\n> ", 
-		colorYellow, oldName, newName, colorReset)
-}
+	case "/requests":
+		c.listJoinRequests()
 
-func (c *chatClient) listRooms() {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	case "/kick":
+		if len(parts) < 2 {
+			fmt.Printf("\r\033[K%s❌ Usage: /kick <username> [reason]%s\n> ", colorRed, colorReset)
+			return
+		}
+		c.kickUser(parts[1], strings.Join(parts[2:], " "))
+
+	case "/ban", "/mute":
+		if len(parts) < 2 {
+			fmt.Printf("\r\033[K%s❌ Usage: %s <username> [minutes]%s\n> ", colorRed, parts[0], colorReset)
+			return
+		}
+		// without a duration the ban or mute lasts until lifted
+		var minutes int64
+		if len(parts) > 2 {
+			if _, err := fmt.Sscanf(parts[2], "%d", &minutes); err != nil || minutes < 0 {
+				fmt.Printf("\r\033[K%s❌ Usage: %s <username> [minutes]%s\n> ", colorRed, parts[0], colorReset)
+				return
+			}
+		}
+		c.restrictUser(parts[0], parts[1], time.Duration(minutes)*time.Minute, false)
+
+	case "/unban", "/unmute":
+		if len(parts) < 2 {
+			fmt.Printf("\r\033[K%s❌ Usage: %s <username>%s\n> ", colorRed, parts[0], colorReset)
+			return
+		}
+		c.restrictUser(strings.TrimPrefix(parts[0], "/un"), parts[1], 0, true)
+
+	case "/op", "/deop":
+		if len(parts) < 2 {
+			fmt.Printf("\r\033[K%s❌ Usage: %s <username>%s\n> ", colorRed, parts[0], colorReset)
+			return
+		}
+		role := pb.RoomRole_ROOM_ROLE_MODERATOR
+		if parts[0] == "/deop" {
+			role = pb.RoomRole_ROOM_ROLE_MEMBER
+		}
+		c.setRole(parts[1], role)
+
+	case "/blocklist":
+		action := "show"
+		if len(parts) > 1 {
+			action = parts[1]
+		}
+		switch {
+		case action == "show" && len(parts) < 3, action == "clear" && len(parts) < 3:
+		case (action == "add" || action == "remove") && len(parts) > 2:
+		default:
+			fmt.Printf("\r\033[K%s❌ Usage: /blocklist [add|remove <words...>|clear]%s\n> ", colorRed, colorReset)
+			return
+		}
+		c.editBlocklist(action, parts[min(len(parts), 2):])
+
+	case "/approve", "/deny":
+		if len(parts) < 2 {
+			fmt.Printf("\r\033[K%s❌ Usage: %s <username>%s\n> ", colorRed, parts[0], colorReset)
+			return
+		}
+		c.respondJoinRequest(parts[1], parts[0] == "/approve")
+
+	case "/history":
+		limit := int32(20)
+		if len(parts) > 1 {
+			if _, err := fmt.Sscanf(parts[1], "%d", &limit); err != nil || limit <= 0 {
+				fmt.Printf("\r\033[K%s❌ Usage: /history [n]%s\n> ", colorRed, colorReset)
+				return
+			}
+		}
+		c.showHistory(limit)
+
+	case "/edit":
+		if len(parts) < 3 {
+			fmt.Printf("\r\033[K%s❌ Usage: /edit <message_id|last> <new text>%s\n> ", colorRed, colorReset)
+			return
+		}
+		if id, ok := c.messageRef(parts[1]); ok {
+			c.editMessage(id, strings.Join(parts[2:], " "))
+		}
+		
+	case "/delete":
+		if len(parts) < 2 {
+			fmt.Printf("\r\033[K%s❌ Usage: /delete <message_id|last>%s\n> ", colorRed, colorReset)
+			return
+		}
+		if id, ok := c.messageRef(parts[1]); ok {
+			c.deleteMessage(id)
+		}
+		
+	case "/edits":
+		if len(parts) < 2 {
+			fmt.Printf("\r\033[K%s❌ Usage: /edits <message_id|last>%s\n> ", colorRed, colorReset)
+			return
+		}
+		if id, ok := c.messageRef(parts[1]); ok {
+			c.showMessageEdits(id)
+		}
+		
+	case "/reply":
+		if len(parts) < 3 {
+			fmt.Printf("\r\033[K%s❌ Usage: /reply <message_id|last> <text>%s\n> ", colorRed, colorReset)
+			return
+		}
+		if id, ok := c.messageRef(parts[1]); ok {
+			c.sendReply(id, strings.Join(parts[2:], " "))
+		}
+		
+	case "/thread":
+		if len(parts) < 2 {
+			fmt.Printf("\r\033[K%s❌ Usage: /thread <message_id|last>%s\n> ", colorRed, colorReset)
+			return
+		}
+		if id, ok := c.messageRef(parts[1]); ok {
+			c.showThread(id)
+		}
+		
+	case "/react":
+		if len(parts) < 3 {
+			fmt.Printf("\r\033[K%s❌ Usage: /react <message_id|last> <emoji|:shortcode:>%s\n> ", colorRed, colorReset)
+			return
+		}
+		if id, ok := c.messageRef(parts[1]); ok {
+			c.toggleReaction(id, parts[2])
+		}
+		
+	case "/seen":
+		if len(parts) < 2 {
+			fmt.Printf("\r\033[K%s❌ Usage: /seen <message_id|last>%s\n> ", colorRed, colorReset)
+			return
+		}
+		if id, ok := c.messageRef(parts[1]); ok {
+			c.showReadReceipts(id)
+		}
+		
+	case "/search":
+		if len(parts) < 2 {
+			fmt.Printf("\r\033[K%s❌ Usage: /search <query>%s\n> ", colorRed, colorReset)
+			return
+		}
+		c.searchMessages(strings.Join(parts[1:], " "))
+
+	case "/export":
+		usage := "Usage: /export <file> [--format json|md|txt] [--since <time>] [--until <time>]"
+		if len(parts) < 2 {
+			fmt.Printf("\r\033[K%s❌ %s%s\n> ", colorRed, usage, colorReset)
+			return
+		}
+		format := exportFormatFromPath(parts[1])
+		var since, until int64
+		for i := 2; i < len(parts); i++ {
+			name, value, ok := strings.Cut(parts[i], "=")
+			if !ok && i+1 < len(parts) {
+				i++
+				value, ok = parts[i], true
+			}
+			var err error
+			switch {
+			case ok && name == "--format":
+				format = value
+			case ok && name == "--since":
+				since, err = parseExportTime(value)
+			case ok && name == "--until":
+				until, err = parseExportTime(value)
+			default:
+				fmt.Printf("\r\033[K%s❌ %s%s\n> ", colorRed, usage, colorReset)
+				return
+			}
+			if err != nil {
+				fmt.Printf("\r\033[K%s❌ Invalid time %q: use YYYY-MM-DD, YYYY-MM-DDTHH:MM or a duration such as 24h%s\n> ", colorRed, value, colorReset)
+				return
+			}
+		}
+		c.exportRoom(parts[1], format, since, until)
+
+	case "/exportdata":
+		path := c.username + "-data.json"
+		if len(parts) > 1 {
+			path = parts[1]
+		}
+		c.exportMyData(path)
+
+	case "/e2e":
+		switch {
+		case len(parts) < 2:
+			state := "off"
+			if c.e2e.enabled {
+				state = "on"
+			}
+			fmt.Printf("\r\033[K%sSystem: End-to-end encryption is %s%s\n> ", colorYellow, state, colorReset)
+		case parts[1] == "on":
+			c.enableE2E()
+		case parts[1] == "off":
+			// the key stays so encrypted messages can still be read
+			c.e2e.enabled = false
+			fmt.Printf("\r\033[K%sSystem: Direct messages are sent in plaintext now%s\n> ", colorYellow, colorReset)
+		default:
+			fmt.Printf("\r\033[K%s❌ Usage: /e2e [on|off]%s\n> ", colorRed, colorReset)
+		}
+
+	case "/fingerprint":
+		username := ""
+		if len(parts) > 1 {
+			username = parts[1]
+		}
+		c.showFingerprint(username)
+
+	case "/trust":
+		if len(parts) < 2 {
+			fmt.Printf("\r\033[K%s❌ Usage: /trust <username>%s\n> ", colorRed, colorReset)
+			return
+		}
+		c.trustKey(parts[1])
+
+	case "/dms":
+		c.listConversations()
+
+	case "/dm-history":
+		if len(parts) < 2 {
+			fmt.Printf("\r\033[K%s❌ Usage: /dm-history <username>%s\n> ", colorRed, colorReset)
+			return
+		}
+		c.showConversation(parts[1])
+
+	default:
+		fmt.Printf("\r\033[K%s❌ Unknown command: %s. Type /help for available commands.%s\n> ", colorRed, parts[0], colorReset)
+	}
+}
+
+func (c *chatClient) displayHelp() {
+	fmt.Print("\r\033[K")
+	fmt.Printf("%s\n╔════════════════════════════════════════╗\n", colorCyan)
+	fmt.Printf("║%s             AVAILABLE COMMANDS           %s║\n", colorYellow+colorBold, colorReset+colorCyan)
+	fmt.Printf("╠════════════════════════════════════════╣\n")
+	fmt.Printf("║ /help    - Show this help message      ║\n")
+	fmt.Printf("║ /quit    - Exit the chat application   ║\n")
+	fmt.Printf("║ /clear   - Clear the screen            ║\n")
+	fmt.Printf("║ /users   - List users in current room  ║\n")
+	fmt.Printf("║ /dm <user> <msg> - Send private message║\n")
+	fmt.Printf("║ /nick <name> - Change your username    ║\n")
+	fmt.Printf("║ /passwd  - Change your password        ║\n")
+	fmt.Printf("║ /resetpw <user> - Issue a reset code   ║\n")
+	fmt.Printf("║ /unlock <user> - Clear failed logins   ║\n")
+	fmt.Printf("║ /audit [action] [by=|on=|room=] - Log  ║\n")
+	fmt.Printf("║ /rooms   - List available rooms        ║\n")
+	fmt.Printf("║ /join <id> [pw] - Join a different room║\n")
+	fmt.Printf("║ /access <mode> [pw] - Set room access  ║\n")
+	fmt.Printf("║ /invite [hours] [uses] - Invite code   ║\n")
+	fmt.Printf("║ /accept <code> - Join with an invite   ║\n")
+	fmt.Printf("║ /knock <id> - Ask to join a room       ║\n")
+	fmt.Printf("║ /requests - List requests to join      ║\n")
+	fmt.Printf("║ /approve, /deny <user> - Answer request║\n")
+	fmt.Printf("║ /kick <user> [reason] - Remove a user  ║\n")
+	fmt.Printf("║ /ban, /mute <user> [min] - Restrict    ║\n")
+	fmt.Printf("║ /unban, /unmute <user> - Lift it       ║\n")
+	fmt.Printf("║ /op, /deop <user> - Set moderator      ║\n")
+	fmt.Printf("║ /blocklist [add|remove|clear] - Words  ║\n")
+	fmt.Printf("║ /edit <id|last> <text> - Edit a message║\n")
+	fmt.Printf("║ /delete <id|last> - Delete a message   ║\n")
+	fmt.Printf("║ /edits <id|last> - Earlier versions    ║\n")
+	fmt.Printf("║ /reply <id|last> <text> - Reply to it  ║\n")
+	fmt.Printf("║ /thread <id|last> - Show its replies   ║\n")
+	fmt.Printf("║ /react <id|last> <emoji> - Toggle it   ║\n")
+	fmt.Printf("║ /seen <id|last> - Who has read it      ║\n")
+	fmt.Printf("║ /history [n] - Show older messages     ║\n")
+	fmt.Printf("║ /search <query> - Search all messages  ║\n")
+	fmt.Printf("║ /export <file> - Save room transcript  ║\n")
+	fmt.Printf("║ /dms     - List your conversations     ║\n")
+	fmt.Printf("║ /dm-history <user> - Show conversation ║\n")
+	fmt.Printf("║ /e2e [on|off] - Encrypt your DMs       ║\n")
+	fmt.Printf("║ /fingerprint [user] - Show a key       ║\n")
+	fmt.Printf("║ /trust <user> - Accept a changed key   ║\n")
+	fmt.Printf("║ /exportdata [file] - Save all your data║\n")
+	fmt.Printf("║ /deleteaccount - Delete your account   ║\n")
+	fmt.Printf("╚════════════════════════════════════════╝%s\n", colorReset)
+}
+
+func (c *chatClient) listUsers() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	resp, err := c.client.ListUsers(ctx, &pb.ListUsersRequest{RoomId: c.roomID})
+	cancel()
+	
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error listing users: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+	
+	fmt.Print("\r\033[K")
+	fmt.Printf("%s\n══════ Users in %s ══════\n", colorYellow, c.roomName)
+	for _, user := range resp.Users {
+		status := "🟢 Online"
+		role := ""
+		switch user.Role {
+		case pb.RoomRole_ROOM_ROLE_OWNER:
+			role = " [owner]"
+		case pb.RoomRole_ROOM_ROLE_MODERATOR:
+			role = " [mod]"
+		}
+		if user.Username == c.username {
+			fmt.Printf("  %s%s (you)%s%s - %s\n", colorGreen, user.Username, colorReset, role, status)
+		} else {
+			fmt.Printf("  %s%s - %s\n", user.Username, role, status)
+		}
+	}
+	fmt.Printf("══════ Total: %d users ══════%s\n", len(resp.Users), colorReset)
+	fmt.Print("> ")
+}
+
+func (c *chatClient) sendDirectMessage(recipient, message string) {
+	req := &pb.SendDirectMessageRequest{
+		RecipientUsername: recipient,
+		Message:           message,
+	}
+	if c.e2e.enabled {
+		sealed, err := c.encryptFor(recipient, message)
+		if err != nil {
+			fmt.Printf("\r\033[K%s❌ Error encrypting DM: %v%s\n> ", colorRed, err, colorReset)
+			return
+		}
+		req.Message = sealed
+		req.Encrypted = true
+		message = "🔒 " + message
+	}
+	
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	resp, err := c.client.SendDirectMessage(ctx, req)
+	cancel()
+	
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error sending DM: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+	
+	if resp.Status == "queued" {
+		fmt.Printf("\r\033[K%s[%s] %s(DM to %s, delivered when they connect): %s%s\n> ", 
+			colorGray, time.Now().Format(timeFormat), 
+			colorPurple, recipient, colorReset, message)
+		return
+	}
+	
+	fmt.Printf("\r\033[K%s[%s] %s(DM to %s): %s%s\n> ", 
+		colorGray, time.Now().Format(timeFormat), 
+		colorPurple, recipient, colorReset, message)
+}
+
+type accountDeletion struct {
+	password       string
+	deleteMessages bool
+}
+
+// deleteAccount deletes the user's account and exits, since the session is
+// gone with it
+func (c *chatClient) deleteAccount(deletion accountDeletion) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	_, err := c.client.DeleteAccount(ctx, &pb.DeleteAccountRequest{
+		Password:       deletion.password,
+		DeleteMessages: deletion.deleteMessages,
+	})
+	cancel()
+	
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error deleting account: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+	
+	fmt.Printf("\r\033[K%s👋 Your account has been deleted.%s\n", colorYellow, colorReset)
+	c.restoreTerminal()
+	c.conn.Close()
+	os.Exit(0)
+}
+
+// exportMyData downloads the archive of everything the server stores about
+// the user and writes it to path
+func (c *chatClient) exportMyData(path string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	stream, err := c.client.ExportMyData(ctx, &pb.ExportMyDataRequest{})
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error exporting your data: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+	
+	var data []byte
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Printf("\r\033[K%s❌ Error exporting your data: %v%s\n> ", colorRed, err, colorReset)
+			return
+		}
+		data = append(data, chunk.Data...)
+	}
+	
+	// the archive holds private messages, so only the user may read it
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		fmt.Printf("\r\033[K%s❌ Error writing %s: %v%s\n> ", colorRed, path, err, colorReset)
+		return
+	}
+	
+	fmt.Printf("\r\033[K%sSystem: Saved your data to %s%s\n> ", colorYellow, path, colorReset)
+}
+
+type passwordChange struct {
+	current string
+	new     string
+}
+
+func (c *chatClient) changePassword(change passwordChange) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	resp, err := c.client.ChangePassword(ctx, &pb.ChangePasswordRequest{
+		CurrentPassword: change.current,
+		NewPassword:     change.new,
+	})
+	cancel()
+	
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error changing password: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+	
+	fmt.Printf("\r\033[K%sSystem: Password changed, %d other sessions signed out%s\n> ", 
+		colorYellow, resp.SessionsRevoked, colorReset)
+}
+
+func (c *chatClient) resetPassword(username string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	resp, err := c.client.ResetPassword(ctx, &pb.ResetPasswordRequest{Username: username})
+	cancel()
+	
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error resetting password: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+	
+	fmt.Printf("\r\033[K%sSystem: Reset code for %s: %s (valid until %s)%s\n> ", 
+		colorYellow, username, resp.ResetCode, 
+		time.Unix(resp.ExpiresAt, 0).Format(dateTimeFormat), colorReset)
+}
+
+func (c *chatClient) unlockAccount(username string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	_, err := c.client.UnlockAccount(ctx, &pb.UnlockAccountRequest{Username: username})
+	cancel()
+	
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error unlocking account: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+	
+	fmt.Printf("\r\033[K%sSystem: Failed logins for %s cleared%s\n> ", colorYellow, username, colorReset)
+}
+
+// listAuditEvents shows a page of the audit log, newest first
+func (c *chatClient) listAuditEvents(req *pb.ListAuditEventsRequest) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	resp, err := c.client.ListAuditEvents(ctx, req)
+	cancel()
+	
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error listing audit events: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+	
+	fmt.Print("\r\033[K")
+	if len(resp.Events) == 0 {
+		fmt.Printf("%sSystem: No matching audit events%s\n> ", colorYellow, colorReset)
+		return
+	}
+	fmt.Printf("%s\n══════ Audit log ══════%s\n", colorCyan, colorReset)
+	for _, e := range resp.Events {
+		actor := e.Actor
+		if actor == "" {
+			actor = "(server)"
+		}
+		line := fmt.Sprintf("%s[%s] #%d%s %s%s%s %s", colorGray, time.Unix(e.Timestamp, 0).Format(dateTimeFormat), e.Id, colorReset,
+			colorPurple, actor, colorReset, e.Action)
+		if e.Target != "" {
+			line += " " + e.Target
+		}
+		if e.RoomId != 0 {
+			room := e.RoomName
+			if room == "" {
+				room = fmt.Sprint(e.RoomId)
+			}
+			line += fmt.Sprintf(" in %s#%s%s", colorCyan, room, colorReset)
+		}
+		if e.Details != "" {
+			line += " (" + e.Details + ")"
+		}
+		if e.Reason != "" {
+			line += ": " + e.Reason
+		}
+		fmt.Println(line)
+	}
+	if resp.HasMore {
+		fmt.Printf("%s══════ Older events: /audit ... before=%d ══════%s\n", colorCyan, resp.Events[len(resp.Events)-1].Id, colorReset)
+	} else {
+		fmt.Printf("%s══════ End of the audit log ══════%s\n", colorCyan, colorReset)
+	}
+	fmt.Print("> ")
+}
+
+func (c *chatClient) changeUsername(newName string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	_, err := c.client.ChangeUsername(ctx, &pb.ChangeUsernameRequest{
+		NewUsername: newName,
+	})
+	cancel()
+	
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error changing username: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+	
+	oldName := c.username
+	c.username = newName
+	fmt.Printf("\r\033[K%sSystem: Username changed from %s to %s%s\n> ", 
+		colorYellow, oldName, newName, colorReset)
+}
+
+func (c *chatClient) listRooms() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	resp, err := c.client.ListRooms(ctx, &pb.ListRoomsRequest{})
 	cancel()
 	
 	if err != nil {
-		fmt.Printf("\r\033[K%s❌ Error listing rooms: %v%s\n> ", colorRed, err, colorReset)
+		fmt.Printf("\r\033[K%s❌ Error listing rooms: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+	
+	fmt.Print("\r\033[K")
+	fmt.Printf("%s\n══════ Available Rooms ══════\n", colorCyan)
+	for _, room := range resp.Rooms {
+		tags := roomTags(room.Visibility, room.HasPassword)
+		if room.UnreadCount > 0 {
+			tags += fmt.Sprintf(" %s(%d unread)%s", colorYellow, room.UnreadCount, colorCyan)
+		}
+		if room.RoomId == c.roomID {
+			fmt.Printf("  %s[%d] %s (current)%s - %d users%s\n", 
+				colorGreen, room.RoomId, room.Name, colorReset+colorCyan, room.UserCount, tags)
+		} else {
+			fmt.Printf("  [%d] %s - %d users%s\n", room.RoomId, room.Name, room.UserCount, tags)
+		}
+	}
+	fmt.Printf("══════ Total: %d rooms ══════%s\n", len(resp.Rooms), colorReset)
+	fmt.Print("> ")
+}
+
+// showHistory prints the page of messages preceding the last page shown, so
+// repeated calls scroll further back through the room
+func (c *chatClient) showHistory(limit int32) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	resp, err := c.client.GetMessageHistory(ctx, &pb.GetMessageHistoryRequest{
+		RoomId:   c.roomID,
+		BeforeId: c.historyBefore,
+		Limit:    limit,
+	})
+	cancel()
+	
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error fetching history: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+	
+	fmt.Print("\r\033[K")
+	fmt.Printf("%s══════ History of %s ══════%s\n", colorCyan, c.roomName, colorReset)
+	for _, msg := range resp.Messages {
+		fmt.Println(c.formatMessage(msg))
+	}
+	if len(resp.Messages) > 0 {
+		c.historyBefore = resp.Messages[0].MessageId
+	}
+	if resp.HasMore {
+		fmt.Printf("%s══════ /history again for older messages ══════%s\n", colorCyan, colorReset)
+	} else {
+		fmt.Printf("%s══════ Beginning of room ══════%s\n", colorCyan, colorReset)
+	}
+	fmt.Print("> ")
+}
+
+// messageRef parses the message argument of /edit, /delete, /edits, /reply,
+// /thread, /react and /seen, where "last" is the last message sent
+func (c *chatClient) messageRef(arg string) (int32, bool) {
+	if arg == "last" {
+		if c.lastMessageID == 0 {
+			fmt.Printf("\r\033[K%s❌ You have not sent a message yet%s\n> ", colorRed, colorReset)
+			return 0, false
+		}
+		return c.lastMessageID, true
+	}
+	
+	id, err := strconv.ParseInt(strings.TrimPrefix(arg, "#"), 10, 32)
+	if err != nil || id <= 0 {
+		fmt.Printf("\r\033[K%s❌ Invalid message ID: %s%s\n> ", colorRed, arg, colorReset)
+		return 0, false
+	}
+	return int32(id), true
+}
+
+func (c *chatClient) editMessage(id int32, text string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	_, err := c.client.EditMessage(ctx, &pb.EditMessageRequest{MessageId: id, Message: text})
+	cancel()
+	
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error editing message: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+	
+	// the edited line arrives on the room stream
+	fmt.Print("\r\033[K> ")
+}
+
+func (c *chatClient) deleteMessage(id int32) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	_, err := c.client.DeleteMessage(ctx, &pb.DeleteMessageRequest{MessageId: id})
+	cancel()
+	
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error deleting message: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+	
+	fmt.Print("\r\033[K> ")
+}
+
+func (c *chatClient) showMessageEdits(id int32) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	resp, err := c.client.GetMessageEdits(ctx, &pb.GetMessageEditsRequest{MessageId: id})
+	cancel()
+	
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error loading edits: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+	
+	fmt.Print("\r\033[K")
+	if len(resp.Edits) == 0 {
+		fmt.Printf("%sSystem: Message #%d was never edited%s\n> ", colorYellow, id, colorReset)
+		return
+	}
+	fmt.Printf("%s\n══════ Earlier versions of #%d ══════%s\n", colorCyan, id, colorReset)
+	for _, edit := range resp.Edits {
+		fmt.Printf("%s[%s] replaced by %s:%s %s\n", colorGray, time.Unix(edit.EditedAt, 0).Format(dateTimeFormat), edit.EditedBy, colorReset, edit.Message)
+	}
+	fmt.Printf("%s══════ %d edits ══════%s\n", colorCyan, len(resp.Edits), colorReset)
+	fmt.Print("> ")
+}
+
+func (c *chatClient) sendReply(id int32, text string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	resp, err := c.client.SendMessage(ctx, &pb.SendMessageRequest{
+		RoomId:           c.roomID,
+		Message:          text,
+		ReplyToMessageId: id,
+	})
+	cancel()
+	
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error sending reply: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+	
+	// the reply arrives on the room stream with its parent quoted
+	c.lastMessageID = resp.MessageId
+	fmt.Print("\r\033[K> ")
+}
+
+func (c *chatClient) showThread(id int32) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	resp, err := c.client.GetThread(ctx, &pb.GetThreadRequest{MessageId: id, Limit: 100})
+	cancel()
+	
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error loading thread: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+	
+	fmt.Print("\r\033[K")
+	fmt.Printf("%s\n══════ Thread #%d ══════%s\n", colorCyan, resp.Message.MessageId, colorReset)
+	c.remember(resp.Message)
+	fmt.Println(c.formatMessage(resp.Message))
+	for _, reply := range resp.Replies {
+		fmt.Printf("  %s\n", c.formatMessage(reply))
+	}
+	if resp.HasMore {
+		fmt.Printf("%s══════ Showing the first %d replies ══════%s\n", colorCyan, len(resp.Replies), colorReset)
+	} else {
+		fmt.Printf("%s══════ %d replies ══════%s\n", colorCyan, len(resp.Replies), colorReset)
+	}
+	fmt.Print("> ")
+}
+
+// toggleReaction reacts to a message, or takes the reaction back when the
+// user already reacted with that emoji
+func (c *chatClient) toggleReaction(id int32, emoji string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	
+	_, err := c.client.AddReaction(ctx, &pb.AddReactionRequest{MessageId: id, Emoji: emoji})
+	if status.Code(err) == codes.AlreadyExists {
+		_, err = c.client.RemoveReaction(ctx, &pb.RemoveReactionRequest{MessageId: id, Emoji: emoji})
+	}
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error reacting: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+	
+	// the new counts arrive on the room stream
+	fmt.Print("\r\033[K> ")
+}
+
+// formatReactions renders reaction counts as "👍 2  🎉 1"
+func formatReactions(reactions []*pb.Reaction) string {
+	if len(reactions) == 0 {
+		return colorGray + "none" + colorReset
+	}
+	counts := make([]string, 0, len(reactions))
+	for _, r := range reactions {
+		counts = append(counts, fmt.Sprintf("%s %d", r.Emoji, r.Count))
+	}
+	return strings.Join(counts, "  ")
+}
+
+// remember keeps a room message so replies to it can quote it
+func (c *chatClient) remember(msg *pb.ReceiveMessageResponse) {
+	if msg.IsDirect || msg.IsSystem || msg.MessageId <= 0 {
+		return
+	}
+	if len(c.recent) >= maxRecentMessages {
+		clear(c.recent)
+	}
+	c.recent[msg.MessageId] = msg
+}
+
+// quoteMessage renders the message a reply answers, asking the server for
+// it when it was sent before this client saw the room
+func (c *chatClient) quoteMessage(id int32) string {
+	parent, ok := c.recent[id]
+	if !ok {
+		// replies answer messages in the same room, so the parent is the
+		// first message after the one before it
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		resp, err := c.client.GetMessageHistory(ctx, &pb.GetMessageHistoryRequest{RoomId: c.roomID, AfterId: id - 1, Limit: 1})
+		cancel()
+		if err != nil || len(resp.Messages) == 0 || resp.Messages[0].MessageId != id {
+			return fmt.Sprintf("%s  ┌ reply to #%d%s", colorGray, id, colorReset)
+		}
+		parent = resp.Messages[0]
+		c.remember(parent)
+	}
+	
+	text := parent.Message
+	if parent.Deleted {
+		text = "(deleted)"
+	} else if runes := []rune(text); len(runes) > 60 {
+		text = string(runes[:60]) + "…"
+	}
+	return fmt.Sprintf("%s  ┌ %s: %s%s", colorGray, parent.Username, text, colorReset)
+}
+
+func (c *chatClient) searchMessages(query string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	resp, err := c.client.SearchMessages(ctx, &pb.SearchMessagesRequest{Query: query})
+	cancel()
+	
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error searching messages: %v%s\n> ", colorRed, err, colorReset)
 		return
 	}
 	
 	fmt.Print("\r\033[K")
-	fmt.Printf("%s\n══════ Available Rooms ══════\n", colorCyan)
-	for _, room := range resp.Rooms {
-		if room.RoomId == c.roomID {
-			fmt.Printf("  %s[%d] %s (current)%s - %d users\n", 
-				colorGreen, room.RoomId, room.Name, colorReset+colorCyan, room.UserCount)
+	fmt.Printf("%s\n══════ Results for \"%s\" ══════%s\n", colorCyan, query, colorReset)
+	for _, hit := range resp.Hits {
+		msg := hit.Message
+		fmt.Printf("%s[%s] %s#%s %s%s:%s %s\n",
+			colorGray, time.Unix(msg.Timestamp, 0).Format(dateTimeFormat),
+			colorCyan, hit.RoomName,
+			colorPurple, msg.Username, colorReset, msg.Message)
+	}
+	if resp.HasMore {
+		fmt.Printf("%s══════ Showing the %d best matches ══════%s\n", colorCyan, len(resp.Hits), colorReset)
+	} else {
+		fmt.Printf("%s══════ Total: %d matches ══════%s\n", colorCyan, len(resp.Hits), colorReset)
+	}
+	fmt.Print("> ")
+}
+
+// exportFormatFromPath picks the transcript format matching the file
+// extension, falling back to plain text
+func exportFormatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return "json"
+	case ".md", ".markdown":
+		return "md"
+	}
+	return "txt"
+}
+
+// parseExportTime reads an /export time bound as Unix time. It takes a
+// local date, a local date and time, or a duration back from now.
+func parseExportTime(value string) (int64, error) {
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return time.Now().Add(-d).Unix(), nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t.Unix(), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid time %q", value)
+}
+
+// exportRoom downloads the current room's transcript of messages sent in
+// [since, until) and writes it to path without any terminal color codes.
+// Zero bounds are left open.
+func (c *chatClient) exportRoom(path, format string, since, until int64) {
+	var write func(io.Writer, string, []*pb.ReceiveMessageResponse) error
+	switch format {
+	case "json":
+		write = writeTranscriptJSON
+	case "md":
+		write = writeTranscriptMarkdown
+	case "txt":
+		write = writeTranscriptText
+	default:
+		fmt.Printf("\r\033[K%s❌ Unknown export format: %s (use json, md or txt)%s\n> ", colorRed, format, colorReset)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	stream, err := c.client.ExportRoom(ctx, &pb.ExportRoomRequest{
+		RoomId: c.roomID,
+		Since:  since,
+		Until:  until,
+	})
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error exporting room: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+
+	var messages []*pb.ReceiveMessageResponse
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Printf("\r\033[K%s❌ Error exporting room: %v%s\n> ", colorRed, err, colorReset)
+			return
+		}
+		messages = append(messages, msg)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error creating %s: %v%s\n> ", colorRed, path, err, colorReset)
+		return
+	}
+	w := bufio.NewWriter(file)
+	err = write(w, c.roomName, messages)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error writing %s: %v%s\n> ", colorRed, path, err, colorReset)
+		return
+	}
+
+	fmt.Printf("\r\033[K%sSystem: Exported %d messages from %s to %s%s\n> ",
+		colorYellow, len(messages), c.roomName, path, colorReset)
+}
+
+type transcriptMessage struct {
+	ID        int32  `json:"id"`
+	Username  string `json:"username"`
+	Message   string `json:"message"`
+	Timestamp string `json:"timestamp"`
+	System    bool   `json:"system,omitempty"`
+	EditedAt  string `json:"edited_at,omitempty"`
+	Deleted   bool   `json:"deleted,omitempty"`
+}
+
+type transcript struct {
+	Room       string              `json:"room"`
+	ExportedAt string              `json:"exported_at"`
+	Messages   []transcriptMessage `json:"messages"`
+}
+
+func writeTranscriptJSON(w io.Writer, room string, messages []*pb.ReceiveMessageResponse) error {
+	t := transcript{
+		Room:       room,
+		ExportedAt: time.Now().Format(time.RFC3339),
+		Messages:   make([]transcriptMessage, 0, len(messages)),
+	}
+	for _, msg := range messages {
+		m := transcriptMessage{
+			ID:        msg.MessageId,
+			Username:  msg.Username,
+			Message:   msg.Message,
+			Timestamp: time.Unix(msg.Timestamp, 0).Format(time.RFC3339),
+			System:    msg.IsSystem,
+			Deleted:   msg.Deleted,
+		}
+		if msg.EditedAt != 0 {
+			m.EditedAt = time.Unix(msg.EditedAt, 0).Format(time.RFC3339)
+		}
+		t.Messages = append(t.Messages, m)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(t)
+}
+
+func writeTranscriptMarkdown(w io.Writer, room string, messages []*pb.ReceiveMessageResponse) error {
+	if _, err := fmt.Fprintf(w, "# Transcript of %s\n\nExported %s\n\n", room, time.Now().Format(exportTimeFormat)); err != nil {
+		return err
+	}
+	for _, msg := range messages {
+		timestamp := time.Unix(msg.Timestamp, 0).Format(exportTimeFormat)
+		var err error
+		if msg.IsSystem {
+			_, err = fmt.Fprintf(w, "- *[%s] %s*\n", timestamp, msg.Message)
 		} else {
-			fmt.Printf("  [%d] %s - %d users\n", room.RoomId, room.Name, room.UserCount)
+			_, err = fmt.Fprintf(w, "- **[%s] %s:** %s\n", timestamp, msg.Username, transcriptText(msg))
+		}
+		if err != nil {
+			return err
 		}
 	}
-	fmt.Printf("══════ Total: %d rooms ══════%s\n", len(resp.Rooms), colorReset)
+	return nil
+}
+
+func writeTranscriptText(w io.Writer, room string, messages []*pb.ReceiveMessageResponse) error {
+	if _, err := fmt.Fprintf(w, "Transcript of %s, exported %s\n\n", room, time.Now().Format(exportTimeFormat)); err != nil {
+		return err
+	}
+	for _, msg := range messages {
+		timestamp := time.Unix(msg.Timestamp, 0).Format(exportTimeFormat)
+		var err error
+		if msg.IsSystem {
+			_, err = fmt.Fprintf(w, "[%s] * %s\n", timestamp, msg.Message)
+		} else {
+			_, err = fmt.Fprintf(w, "[%s] %s: %s\n", timestamp, msg.Username, transcriptText(msg))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// transcriptText is a message's text as the md and txt transcripts show it
+func transcriptText(msg *pb.ReceiveMessageResponse) string {
+	if msg.Deleted {
+		return "(message deleted)"
+	}
+	if msg.EditedAt != 0 {
+		return msg.Message + " (edited)"
+	}
+	return msg.Message
+}
+
+func (c *chatClient) listConversations() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	resp, err := c.client.ListConversations(ctx, &pb.ListConversationsRequest{})
+	cancel()
+	
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error listing conversations: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+	
+	fmt.Print("\r\033[K")
+	fmt.Printf("%s\n══════ Direct Messages ══════%s\n", colorCyan, colorReset)
+	for _, conv := range resp.Conversations {
+		unread := ""
+		if conv.UnreadCount > 0 {
+			unread = fmt.Sprintf(" %s(%d unread)%s", colorYellow, conv.UnreadCount, colorReset)
+		}
+		fmt.Printf("  %s%s%s%s\n", colorPurple, conv.PeerUsername, colorReset, unread)
+		if msg := conv.LastMessage; msg != nil {
+			text := msg.Message
+			if msg.Encrypted {
+				text = c.decryptFrom(conv.PeerUsername, msg.Username, msg.Message)
+			}
+			fmt.Printf("    %s[%s] %s:%s %s\n",
+				colorGray, time.Unix(msg.Timestamp, 0).Format(dateTimeFormat),
+				msg.Username, colorReset, text)
+		}
+	}
+	fmt.Printf("%s══════ Total: %d conversations ══════%s\n", colorCyan, len(resp.Conversations), colorReset)
+	fmt.Print("> ")
+}
+
+func (c *chatClient) showConversation(peer string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	resp, err := c.client.GetConversationHistory(ctx, &pb.GetConversationHistoryRequest{
+		PeerUsername: peer,
+	})
+	cancel()
+	
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error fetching conversation: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+	
+	fmt.Print("\r\033[K")
+	fmt.Printf("%s══════ Conversation with %s ══════%s\n", colorCyan, peer, colorReset)
+	for _, msg := range resp.Messages {
+		timestamp := time.Unix(msg.Timestamp, 0).Format(dateTimeFormat)
+		text := msg.Message
+		if msg.Encrypted {
+			text = c.decryptFrom(peer, msg.Username, msg.Message)
+		}
+		if msg.Username == c.username {
+			fmt.Printf("%s[%s] %s[You]:%s %s\n", colorGray, timestamp, colorBlue, colorReset, text)
+		} else {
+			fmt.Printf("%s[%s] %s%s:%s %s\n", colorGray, timestamp, colorPurple, msg.Username, colorReset, text)
+		}
+	}
+	if resp.HasMore {
+		fmt.Printf("%s══════ Showing the latest %d messages ══════%s\n", colorCyan, len(resp.Messages), colorReset)
+	} else {
+		fmt.Printf("%s══════ Total: %d messages ══════%s\n", colorCyan, len(resp.Messages), colorReset)
+	}
+	fmt.Print("> ")
+}
+
+func (c *chatClient) setRoomAccess(visibility pb.RoomVisibility, password string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	_, err := c.client.SetRoomAccess(ctx, &pb.SetRoomAccessRequest{
+		RoomId:     c.roomID,
+		Visibility: visibility,
+		Password:   password,
+	})
+	cancel()
+	
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error changing room access: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+	
+	fmt.Printf("\r\033[K%sSystem: Room '%s' is now%s%s\n> ", 
+		colorYellow, c.roomName, roomTags(visibility, password != ""), colorReset)
+}
+
+func (c *chatClient) createInvite(ttl time.Duration, uses int32) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	resp, err := c.client.CreateInvite(ctx, &pb.CreateInviteRequest{
+		RoomId:     c.roomID,
+		TtlSeconds: int64(ttl.Seconds()),
+		MaxUses:    uses,
+	})
+	cancel()
+	
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error creating invite: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+	
+	expiry := "never expires"
+	if resp.ExpiresAt != 0 {
+		expiry = "valid until " + time.Unix(resp.ExpiresAt, 0).Format(dateTimeFormat)
+	}
+	fmt.Printf("\r\033[K%sSystem: Invite code for '%s': %s (%s)%s\n> ", 
+		colorYellow, c.roomName, resp.InviteCode, expiry, colorReset)
+}
+
+func (c *chatClient) acceptInvite(code string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	resp, err := c.client.AcceptInvite(ctx, &pb.AcceptInviteRequest{InviteCode: code})
+	cancel()
+	
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error accepting invite: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+	
+	c.changeRoom(resp.RoomId, "")
+}
+
+func (c *chatClient) requestJoin(roomID int32) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	_, err := c.client.RequestJoin(ctx, &pb.RequestJoinRequest{RoomId: roomID})
+	cancel()
+	
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error requesting to join: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+	
+	fmt.Printf("\r\033[K%sSystem: Asked to join room %d, you will be notified of the answer%s\n> ", 
+		colorYellow, roomID, colorReset)
+}
+
+func (c *chatClient) listJoinRequests() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	resp, err := c.client.ListJoinRequests(ctx, &pb.ListJoinRequestsRequest{RoomId: c.roomID})
+	cancel()
+	
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error listing join requests: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+	
+	fmt.Print("\r\033[K")
+	if len(resp.Requests) == 0 {
+		fmt.Printf("%sSystem: No pending requests to join '%s'%s\n> ", colorYellow, c.roomName, colorReset)
+		return
+	}
+	fmt.Printf("%s\n══════ Requests to join '%s' ══════\n", colorCyan, c.roomName)
+	for _, r := range resp.Requests {
+		fmt.Printf("  %s (since %s)\n", r.Username, time.Unix(r.RequestedAt, 0).Format(dateTimeFormat))
+	}
+	fmt.Printf("══════ /approve or /deny <user> ══════%s\n", colorReset)
 	fmt.Print("> ")
 }
 
-func (c *chatClient) changeRoom(roomID int32) {
+func (c *chatClient) respondJoinRequest(username string, approve bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	_, err := c.client.RespondJoinRequest(ctx, &pb.RespondJoinRequestRequest{
+		RoomId:   c.roomID,
+		Username: username,
+		Approve:  approve,
+	})
+	cancel()
+	
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error answering join request: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+	
+	verdict := "denied"
+	if approve {
+		verdict = "approved"
+	}
+	fmt.Printf("\r\033[K%sSystem: Request from %s %s%s\n> ", colorYellow, username, verdict, colorReset)
+}
+
+func (c *chatClient) kickUser(username, reason string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	_, err := c.client.KickUser(ctx, &pb.KickUserRequest{
+		RoomId:   c.roomID,
+		Username: username,
+		Reason:   reason,
+	})
+	cancel()
+	
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error kicking %s: %v%s\n> ", colorRed, username, err, colorReset)
+		return
+	}
+	
+	fmt.Print("\r\033[K> ")
+}
+
+// restrictUser bans or mutes a user, kind being "/ban" or "/mute", or lifts
+// the restriction. The room is told through a system notice.
+func (c *chatClient) restrictUser(kind, username string, duration time.Duration, lift bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	var err error
+	if kind == "/ban" {
+		_, err = c.client.BanUser(ctx, &pb.BanUserRequest{
+			RoomId:          c.roomID,
+			Username:        username,
+			DurationSeconds: int64(duration.Seconds()),
+			Lift:            lift,
+		})
+	} else {
+		_, err = c.client.MuteUser(ctx, &pb.MuteUserRequest{
+			RoomId:          c.roomID,
+			Username:        username,
+			DurationSeconds: int64(duration.Seconds()),
+			Lift:            lift,
+		})
+	}
+	cancel()
+	
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error updating %s: %v%s\n> ", colorRed, username, err, colorReset)
+		return
+	}
+	
+	fmt.Print("\r\033[K> ")
+}
+
+func (c *chatClient) setRole(username string, role pb.RoomRole) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	_, err := c.client.SetRole(ctx, &pb.SetRoleRequest{
+		RoomId:   c.roomID,
+		Username: username,
+		Role:     role,
+	})
+	cancel()
+	
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error changing role of %s: %v%s\n> ", colorRed, username, err, colorReset)
+		return
+	}
+	
+	fmt.Print("\r\033[K> ")
+}
+
+// editBlocklist shows or changes the blocked words of the current room
+func (c *chatClient) editBlocklist(action string, words []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	resp, err := c.client.GetBlockedWords(ctx, &pb.GetBlockedWordsRequest{RoomId: c.roomID})
+	cancel()
+	
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error loading blocked words: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+	
+	blocked := resp.Words
+	switch action {
+	case "show":
+		fmt.Print("\r\033[K")
+		if len(blocked) == 0 {
+			fmt.Printf("%sSystem: No blocked words in '%s'%s\n> ", colorYellow, c.roomName, colorReset)
+			return
+		}
+		fmt.Printf("%sSystem: Blocked words in '%s': %s%s\n> ", colorYellow, c.roomName, strings.Join(blocked, ", "), colorReset)
+		return
+	case "add":
+		blocked = append(blocked, words...)
+	case "remove":
+		remove := make(map[string]bool, len(words))
+		for _, word := range words {
+			remove[strings.ToLower(word)] = true
+		}
+		kept := blocked[:0]
+		for _, word := range blocked {
+			if !remove[word] {
+				kept = append(kept, word)
+			}
+		}
+		blocked = kept
+	case "clear":
+		blocked = nil
+	}
+	
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	_, err = c.client.SetBlockedWords(ctx, &pb.SetBlockedWordsRequest{RoomId: c.roomID, Words: blocked})
+	cancel()
+	
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error updating blocked words: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+	
+	fmt.Printf("\r\033[K%sSystem: Blocked words in '%s' updated%s\n> ", colorYellow, c.roomName, colorReset)
+}
+
+func (c *chatClient) changeRoom(roomID int32, password string) {
 	// First get the room info
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	roomInfo, err := c.client.GetRoomInfo(ctx, &pb.GetRoomInfoRequest{RoomId: roomID})
@@ -614,10 +2305,10 @@ func (c *chatClient) changeRoom(roomID int32) {
 		return
 	}
 	
-	// leave current room
+	// leave current room, read up to what was shown
+	c.markRead()
 	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
 	_, err = c.client.LeaveRoom(ctx, &pb.LeaveRoomRequest{
-		UserId: c.userID,
 		RoomId: c.roomID,
 	})
 	cancel()
@@ -631,6 +2322,10 @@ func (c *chatClient) changeRoom(roomID int32) {
 	oldRoomName := c.roomName
 	c.roomID = roomID
 	c.roomName = roomInfo.Name
+	c.roomPassword = password
+	c.historyBefore = 0
+	clear(c.recent)
+	c.closeTyping()
 	
 	// Clear screen and show new chat header
 	clearScreen()
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// e2eInfo binds the message keys derived from a shared secret to this use
+const e2eInfo = "zenith e2e direct message v1"
+
+// e2eKeys holds the keys for end-to-end encrypted direct messages. The
+// identity key is an X25519 key kept on disk; peers' public keys are pinned
+// the first time they are seen, so a key the server swaps in is noticed.
+type e2eKeys struct {
+	dir string
+	// identity is nil until the user turns encryption on
+	identity *ecdh.PrivateKey
+	// enabled makes outgoing direct messages encrypted
+	enabled bool
+	// pinned maps peer user IDs to the public key first seen for them.
+	// Usernames change, so they only label the pins.
+	pinned map[int32]*pinnedKey
+	// peers caches the keys fetched this session that match their pin
+	peers map[string]*knownPeer
+}
+
+// knownPeer is a peer's public key along with the account it belongs to
+type knownPeer struct {
+	id  int32
+	key *ecdh.PublicKey
+}
+
+// pinnedKey is a peer's pinned public key and the username last seen with it
+type pinnedKey struct {
+	Username string `json:"username"`
+	Key      []byte `json:"key"`
+}
+
+// errKeyChanged is returned for a peer whose published key no longer
+// matches the pinned one
+type errKeyChanged struct {
+	username      string
+	pinned, fresh []byte
+}
+
+func (e *errKeyChanged) Error() string {
+	return fmt.Sprintf("%s's key changed from %s to %s, check the new fingerprint with them and /trust %s",
+		e.username, fingerprint(e.pinned), fingerprint(e.fresh), e.username)
+}
+
+// newE2EKeys keeps keys in E2E_KEY_DIR, or under the user config directory,
+// with one directory per server
+func newE2EKeys(host, port string) *e2eKeys {
+	dir := os.Getenv("E2E_KEY_DIR")
+	if dir == "" {
+		base, err := os.UserConfigDir()
+		if err != nil {
+			base = "."
+		}
+		dir = filepath.Join(base, "zenith", "keys", host+"_"+port)
+	}
+	return &e2eKeys{
+		dir:    dir,
+		pinned: make(map[int32]*pinnedKey),
+		peers:  make(map[string]*knownPeer),
+	}
+}
+
+// files are named by user ID so they survive a username change
+func (k *e2eKeys) identityPath(userID int32) string {
+	return filepath.Join(k.dir, fmt.Sprintf("identity-%d.key", userID))
+}
+
+func (k *e2eKeys) pinnedPath(userID int32) string {
+	return filepath.Join(k.dir, fmt.Sprintf("known-keys-%d.json", userID))
+}
+
+// load reads the identity key and pinned keys of the user, reporting false
+// if the user never turned encryption on
+func (k *e2eKeys) load(userID int32) (bool, error) {
+	raw, err := os.ReadFile(k.identityPath(userID))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	identity, err := ecdh.X25519().NewPrivateKey(raw)
+	if err != nil {
+		return false, fmt.Errorf("invalid identity key %s: %v", k.identityPath(userID), err)
+	}
+
+	data, err := os.ReadFile(k.pinnedPath(userID))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &k.pinned); err != nil {
+			return false, fmt.Errorf("invalid known keys %s: %v", k.pinnedPath(userID), err)
+		}
+	}
+
+	k.identity = identity
+	return true, nil
+}
+
+// create generates and saves a new identity key for the user
+func (k *e2eKeys) create(userID int32) error {
+	identity, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(k.dir, 0700); err != nil {
+		return err
+	}
+	// O_EXCL keeps an existing key from being overwritten by accident
+	file, err := os.OpenFile(k.identityPath(userID), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(identity.Bytes()); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	k.identity = identity
+	return nil
+}
+
+// pin records the key of a peer and saves the pinned keys
+func (k *e2eKeys) pin(userID, peerID int32, username string, key []byte) error {
+	k.pinned[peerID] = &pinnedKey{Username: username, Key: key}
+	data, err := json.MarshalIndent(k.pinned, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(k.dir, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(k.pinnedPath(userID), data, 0600)
+}
+
+// fingerprint shortens a public key to something people can compare aloud
+func fingerprint(key []byte) string {
+	sum := sha256.Sum256(key)
+	hex := strings.ToUpper(fmt.Sprintf("%x", sum[:16]))
+	groups := make([]string, 0, len(hex)/4)
+	for i := 0; i < len(hex); i += 4 {
+		groups = append(groups, hex[i:i+4])
+	}
+	return strings.Join(groups, " ")
+}
+
+// messageKey derives the AES-256 key shared by the identity and a peer. Both
+// sides of a conversation derive the same key, so senders can read their own
+// messages back.
+func messageKey(identity *ecdh.PrivateKey, peer *ecdh.PublicKey) (cipher.AEAD, error) {
+	secret, err := identity.ECDH(peer)
+	if err != nil {
+		return nil, err
+	}
+	key, err := hkdf.Key(sha256.New, secret, nil, e2eInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// messageAAD binds a message to the direction it was sent in. Both sides
+// share one key, so without it the server could reflect a message back to
+// its sender as the peer's, or replay it in another conversation.
+func messageAAD(senderID, recipientID int32) []byte {
+	aad := make([]byte, 8)
+	binary.BigEndian.PutUint32(aad[:4], uint32(senderID))
+	binary.BigEndian.PutUint32(aad[4:], uint32(recipientID))
+	return aad
+}
+
+// sealMessage encrypts a message to a peer as base64 of nonce and ciphertext
+func sealMessage(identity *ecdh.PrivateKey, peer *ecdh.PublicKey, senderID, recipientID int32, message string) (string, error) {
+	aead, err := messageKey(identity, peer)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(message), messageAAD(senderID, recipientID))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openMessage reverses sealMessage, failing unless the message was sealed
+// for the same sender and recipient
+func openMessage(identity *ecdh.PrivateKey, peer *ecdh.PublicKey, senderID, recipientID int32, encoded string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	aead, err := messageKey(identity, peer)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("message too short")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], messageAAD(senderID, recipientID))
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// loadE2E turns encryption on at login for users who enabled it before,
+// publishing their key again in case the server lost it
+func (c *chatClient) loadE2E() {
+	ok, err := c.e2e.load(c.userID)
+	if err != nil {
+		fmt.Printf("%s⚠️  End-to-end encryption is off: %v%s\n", colorYellow, err, colorReset)
+		return
+	}
+	if !ok {
+		return
+	}
+	if err := c.publishKey(); err != nil {
+		fmt.Printf("%s⚠️  Failed to publish your encryption key: %v%s\n", colorYellow, err, colorReset)
+	}
+	c.e2e.enabled = true
+}
+
+func (c *chatClient) publishKey() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := c.client.PublishKey(ctx, &pb.PublishKeyRequest{
+		PublicKey: c.e2e.identity.PublicKey().Bytes(),
+	})
+	return err
+}
+
+// enableE2E creates and publishes an identity key if needed and encrypts
+// direct messages from now on
+func (c *chatClient) enableE2E() {
+	if c.e2e.identity == nil {
+		if err := c.e2e.create(c.userID); err != nil {
+			fmt.Printf("\r\033[K%s❌ Error creating encryption key: %v%s\n> ", colorRed, err, colorReset)
+			return
+		}
+	}
+	if err := c.publishKey(); err != nil {
+		fmt.Printf("\r\033[K%s❌ Error publishing encryption key: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+	c.e2e.enabled = true
+
+	fmt.Printf("\r\033[K%sSystem: Direct messages are now end-to-end encrypted. Your fingerprint is %s%s\n> ",
+		colorYellow, fingerprint(c.e2e.identity.PublicKey().Bytes()), colorReset)
+}
+
+// peerKey returns the public key of a peer, pinning it on first use and
+// failing with errKeyChanged when it no longer matches the pin
+func (c *chatClient) peerKey(username string) (*knownPeer, error) {
+	if peer, ok := c.e2e.peers[username]; ok {
+		return peer, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	resp, err := c.client.GetPublicKey(ctx, &pb.GetPublicKeyRequest{Username: username})
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	key, err := ecdh.X25519().NewPublicKey(resp.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s published an invalid key", username)
+	}
+
+	pinned, ok := c.e2e.pinned[resp.UserId]
+	if ok && !bytes.Equal(pinned.Key, resp.PublicKey) {
+		return nil, &errKeyChanged{username: username, pinned: pinned.Key, fresh: resp.PublicKey}
+	}
+
+	switch {
+	case !ok:
+		// the name may have belonged to someone else when it was pinned
+		for id, other := range c.e2e.pinned {
+			if other.Username == username && id != resp.UserId {
+				fmt.Printf("\r\033[K%s⚠️  %s is a different account from the %s you messaged before, who has since changed their name.%s\n",
+					colorYellow, username, username, colorReset)
+				break
+			}
+		}
+		fmt.Printf("\r\033[K%s🔑 First encrypted message with %s, their fingerprint is %s. Compare it with them to be sure.%s\n",
+			colorYellow, username, fingerprint(resp.PublicKey), colorReset)
+	case pinned.Username != username:
+		fmt.Printf("\r\033[K%s🔑 %s is now called %s and still has the key you checked.%s\n",
+			colorYellow, pinned.Username, username, colorReset)
+	}
+	// save new pins and renames
+	if !ok || pinned.Username != username {
+		if err := c.e2e.pin(c.userID, resp.UserId, username, resp.PublicKey); err != nil {
+			return nil, fmt.Errorf("failed to save %s's key: %v", username, err)
+		}
+	}
+
+	peer := &knownPeer{id: resp.UserId, key: key}
+	c.e2e.peers[username] = peer
+	return peer, nil
+}
+
+// encryptFor encrypts a direct message to a peer. The key is fetched again
+// each time, since the username may have passed to someone else since it was
+// cached.
+func (c *chatClient) encryptFor(username, message string) (string, error) {
+	delete(c.e2e.peers, username)
+	peer, err := c.peerKey(username)
+	if status.Code(err) == codes.NotFound {
+		return "", fmt.Errorf("%s has no encryption key yet, use /e2e off to send in plaintext", username)
+	}
+	if err != nil {
+		return "", err
+	}
+	return sealMessage(c.e2e.identity, peer.key, c.userID, peer.id, message)
+}
+
+// decryptFrom decrypts a direct message exchanged with a peer, sent by the
+// user when sender is their own name, returning a placeholder when it cannot
+// be read
+func (c *chatClient) decryptFrom(username, sender, encoded string) string {
+	if c.e2e.identity == nil {
+		return "🔒 [encrypted message, turn on /e2e with your key to read it]"
+	}
+	_, cached := c.e2e.peers[username]
+	peer, err := c.peerKey(username)
+	if err == nil {
+		senderID, recipientID := peer.id, c.userID
+		if sender == c.username {
+			senderID, recipientID = c.userID, peer.id
+		}
+		var message string
+		if message, err = openMessage(c.e2e.identity, peer.key, senderID, recipientID, encoded); err == nil {
+			return "🔒 " + stripEscapes(message)
+		}
+		// the peer may have changed keys, or the username hands, since it
+		// was cached
+		delete(c.e2e.peers, username)
+		if cached {
+			return c.decryptFrom(username, sender, encoded)
+		}
+	}
+	return fmt.Sprintf("🔒 [cannot decrypt: %v]", err)
+}
+
+// escapeSequence matches ANSI CSI and OSC sequences and other two byte
+// escapes, as the server's escapes filter does
+var escapeSequence = regexp.MustCompile(`\x1b(?:\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(?:\x07|\x1b\\)?|[@-_])`)
+
+// stripEscapes removes terminal escape sequences, control characters and
+// bidirectional overrides from decrypted text. The server cannot filter
+// ciphertext, so the client has to before printing it.
+func stripEscapes(text string) string {
+	text = escapeSequence.ReplaceAllString(text, "")
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t':
+			return ' '
+		case unicode.IsControl(r), unicode.Is(unicode.Bidi_Control, r), r == utf8.RuneError:
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// showFingerprint prints the user's own fingerprint, or a peer's next to
+// the pinned one
+func (c *chatClient) showFingerprint(username string) {
+	if username == "" {
+		if c.e2e.identity == nil {
+			fmt.Printf("\r\033[K%s❌ You have no encryption key, use /e2e on to create one%s\n> ", colorRed, colorReset)
+			return
+		}
+		fmt.Printf("\r\033[K%sSystem: Your fingerprint is %s%s\n> ",
+			colorYellow, fingerprint(c.e2e.identity.PublicKey().Bytes()), colorReset)
+		return
+	}
+
+	delete(c.e2e.peers, username)
+	peer, err := c.peerKey(username)
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+	fmt.Printf("\r\033[K%sSystem: %s's fingerprint is %s%s\n> ",
+		colorYellow, username, fingerprint(peer.key.Bytes()), colorReset)
+}
+
+// trustKey pins the key a peer currently publishes, accepting a key change
+func (c *chatClient) trustKey(username string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	resp, err := c.client.GetPublicKey(ctx, &pb.GetPublicKeyRequest{Username: username})
+	cancel()
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error fetching %s's key: %v%s\n> ", colorRed, username, err, colorReset)
+		return
+	}
+	if err := c.e2e.pin(c.userID, resp.UserId, username, resp.PublicKey); err != nil {
+		fmt.Printf("\r\033[K%s❌ Error saving %s's key: %v%s\n> ", colorRed, username, err, colorReset)
+		return
+	}
+	delete(c.e2e.peers, username)
+
+	fmt.Printf("\r\033[K%sSystem: Trusting %s's key %s%s\n> ",
+		colorYellow, username, fingerprint(resp.PublicKey), colorReset)
+}
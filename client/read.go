@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	pb "github.com/ayushsarode/termiXchat/proto"
+)
+
+// readDelay batches read positions, so a busy room is not marked read once
+// per message
+const readDelay = 2 * time.Second
+
+// readMarker is the newest room message shown but not yet marked read.
+// messageLoop owns it.
+type readMarker struct {
+	roomID    int32
+	messageID int32
+	// due fires when the position should be sent, nil while nothing waits
+	due <-chan time.Time
+}
+
+// noteRead records that a room message was shown
+func (c *chatClient) noteRead(id int32) {
+	if c.read.roomID != c.roomID {
+		c.read = readMarker{roomID: c.roomID}
+	}
+	if id > c.read.messageID {
+		c.read.messageID = id
+	}
+	if c.read.due == nil {
+		c.read.due = time.After(readDelay)
+	}
+}
+
+// markRead tells the server how far the user has read the room
+func (c *chatClient) markRead() {
+	marker := c.read
+	c.read = readMarker{roomID: marker.roomID}
+	if marker.roomID != c.roomID || marker.messageID == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// read positions are a nicety, chat works without them
+	c.client.MarkRead(ctx, &pb.MarkReadRequest{RoomId: marker.roomID, MessageId: marker.messageID})
+}
+
+// showReadBy reports a read position once it covers the last message the
+// user sent in this room, once per reader
+func (c *chatClient) showReadBy(msg *pb.ReceiveMessageResponse) {
+	if _, ok := c.recent[c.lastMessageID]; !ok || msg.MessageId < c.lastMessageID {
+		return
+	}
+	if c.seenBy[msg.Username] == c.lastMessageID {
+		return
+	}
+	c.seenBy[msg.Username] = c.lastMessageID
+
+	fmt.Print("\r\033[K")
+	fmt.Printf("%s✓ #%d seen by %s%s\n", colorGray, c.lastMessageID, msg.Username, colorReset)
+	c.redrawPrompt()
+}
+
+func (c *chatClient) showReadReceipts(id int32) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	resp, err := c.client.GetReadReceipts(ctx, &pb.GetReadReceiptsRequest{MessageId: id})
+	cancel()
+
+	if err != nil {
+		fmt.Printf("\r\033[K%s❌ Error loading read receipts: %v%s\n> ", colorRed, err, colorReset)
+		return
+	}
+
+	fmt.Print("\r\033[K")
+	if len(resp.Readers) == 0 {
+		fmt.Printf("%sSystem: Nobody has read #%d yet%s\n> ", colorYellow, id, colorReset)
+		return
+	}
+	readers := make([]string, 0, len(resp.Readers))
+	for _, r := range resp.Readers {
+		readers = append(readers, r.Username)
+	}
+	fmt.Printf("%s✓ #%d seen by %s%s\n> ", colorGray, id, strings.Join(readers, ", "), colorReset)
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+
+	pb "github.com/ayushsarode/termiXchat/proto"
+)
+
+// typingRefresh is how often a typing signal is repeated while the user
+// keeps typing, well inside the server's expiry
+const typingRefresh = 3 * time.Second
+
+// lineReader reads what the user types. On a terminal it takes keys as they
+// are pressed, echoing and editing the line itself, so it can tell when the
+// user is typing. Elsewhere it falls back to reading whole lines.
+type lineReader struct {
+	in *bufio.Reader
+	// restore undoes keystroke mode, nil when reading whole lines
+	restore func()
+	// onChange is called after each key with whether the line holds text
+	onChange func(typing bool)
+
+	mu   sync.Mutex
+	line []rune
+}
+
+func newLineReader(in *os.File, onChange func(typing bool)) *lineReader {
+	r := &lineReader{in: bufio.NewReader(in), onChange: onChange}
+	if restore, err := keystrokeMode(in); err == nil {
+		r.restore = restore
+	}
+	return r
+}
+
+// keystrokeMode switches the terminal to deliver keys as they are pressed
+// and stop echoing them, returning a function that restores it
+func keystrokeMode(in *os.File) (func(), error) {
+	fd := int(in.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, errors.New("input is not a terminal")
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	// raw mode also stops the terminal turning "\n" into "\r\n", so output
+	// goes through a pipe that does it instead
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		term.Restore(fd, state)
+		return nil, err
+	}
+	out := os.Stdout
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		io.Copy(crlfWriter{out}, pr)
+	}()
+	os.Stdout = pw
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			os.Stdout = out
+			pw.Close()
+			<-done
+			term.Restore(fd, state)
+		})
+	}, nil
+}
+
+// crlfWriter writes "\r\n" for every "\n", as a terminal in cooked mode would
+type crlfWriter struct {
+	w io.Writer
+}
+
+func (w crlfWriter) Write(p []byte) (int, error) {
+	if _, err := w.w.Write(bytes.ReplaceAll(p, []byte("\n"), []byte("\r\n"))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// readLine reads a chat line, reporting typing as it goes
+func (r *lineReader) readLine() (string, error) {
+	return r.read(r.onChange)
+}
+
+// readAnswer reads the answer to a prompt, which is not typing a message
+func (r *lineReader) readAnswer() (string, error) {
+	return r.read(nil)
+}
+
+func (r *lineReader) read(onChange func(typing bool)) (string, error) {
+	if r.restore == nil {
+		return r.in.ReadString('\n')
+	}
+
+	r.setLine(nil)
+	for {
+		ch, _, err := r.in.ReadRune()
+		if err != nil {
+			return r.current(), err
+		}
+
+		line := []rune(r.current())
+		switch {
+		case ch == '\r' || ch == '\n':
+			fmt.Print("\n")
+			r.setLine(nil)
+			return string(line), nil
+		case ch == 0x7f || ch == '\b':
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+				fmt.Print("\b \b")
+			}
+		case ch == 0x15: // ctrl-u clears the line
+			fmt.Print(strings.Repeat("\b \b", len(line)))
+			line = nil
+		case ch == 0x03: // raw mode keeps ctrl-c from interrupting, so pass it on
+			if p, err := os.FindProcess(os.Getpid()); err == nil {
+				p.Signal(os.Interrupt)
+			}
+		case ch == 0x04: // ctrl-d ends input on an empty line
+			if len(line) == 0 {
+				return "", io.EOF
+			}
+		case ch == 0x1b:
+			r.skipEscape()
+		case ch < ' ':
+			// other control keys have no meaning here
+		default:
+			line = append(line, ch)
+			fmt.Print(string(ch))
+		}
+		r.setLine(line)
+
+		if onChange != nil {
+			onChange(len(line) > 0)
+		}
+	}
+}
+
+// skipEscape drops the rest of an escape sequence such as an arrow key
+func (r *lineReader) skipEscape() {
+	ch, _, err := r.in.ReadRune()
+	if err != nil || (ch != '[' && ch != 'O') {
+		return
+	}
+	for {
+		ch, _, err := r.in.ReadRune()
+		if err != nil || (ch >= 0x40 && ch <= 0x7e) {
+			return
+		}
+	}
+}
+
+func (r *lineReader) setLine(line []rune) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.line = line
+}
+
+// current returns the line typed so far, empty when reading whole lines
+func (r *lineReader) current() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return string(r.line)
+}
+
+// typingIndicator is the client's end of the typing stream for the current
+// room. messageLoop owns it.
+type typingIndicator struct {
+	stream pb.ChatService_TypingClient
+	cancel context.CancelFunc
+	roomID int32
+	// who else is typing, shown in front of the prompt
+	usernames []string
+}
+
+// typingSignal tracks what the input goroutine last told messageLoop
+type typingSignal struct {
+	typing bool
+	sentAt time.Time
+}
+
+// noteTyping runs on the input goroutine after each key and passes typing
+// on to messageLoop when it starts, stops, or needs refreshing
+func (c *chatClient) noteTyping(typing bool) {
+	if typing == c.lastTyping.typing && (!typing || time.Since(c.lastTyping.sentAt) < typingRefresh) {
+		return
+	}
+	c.lastTyping = typingSignal{typing: typing, sentAt: time.Now()}
+	c.typingChan <- typing
+}
+
+// openTyping starts the typing stream for the current room, replacing the
+// stream of the previous one
+func (c *chatClient) openTyping() {
+	c.closeTyping()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := c.client.Typing(ctx)
+	if err == nil {
+		err = stream.Send(&pb.TypingRequest{RoomId: c.roomID})
+	}
+	if err != nil {
+		// typing indicators are a nicety, chat works without them
+		cancel()
+		return
+	}
+	c.typing = typingIndicator{stream: stream, cancel: cancel, roomID: c.roomID}
+
+	go func() {
+		for {
+			update, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			c.typingUpdates <- update
+		}
+	}()
+}
+
+func (c *chatClient) closeTyping() {
+	if c.typing.cancel != nil {
+		c.typing.cancel()
+	}
+	c.typing = typingIndicator{}
+}
+
+// sendTyping tells the room whether the user is typing
+func (c *chatClient) sendTyping(typing bool) {
+	if c.typing.stream == nil {
+		return
+	}
+	if err := c.typing.stream.Send(&pb.TypingRequest{RoomId: c.typing.roomID, Typing: typing}); err != nil {
+		c.closeTyping()
+	}
+}
+
+// showTyping puts who else is typing in front of the prompt
+func (c *chatClient) showTyping(update *pb.TypingUpdate) {
+	if update.RoomId != c.typing.roomID {
+		return
+	}
+	c.typing.usernames = update.Usernames
+	c.redrawPrompt()
+}
+
+// stoppedTyping drops a user who just sent a message from the users typing,
+// since the message can arrive before the update saying so
+func (c *chatClient) stoppedTyping(username string) {
+	c.typing.usernames = slices.DeleteFunc(slices.Clone(c.typing.usernames), func(name string) bool {
+		return name == username
+	})
+}
+
+// redrawPrompt rewrites the prompt line with the line typed so far
+func (c *chatClient) redrawPrompt() {
+	fmt.Print("\r\033[K")
+	if len(c.typing.usernames) > 0 {
+		fmt.Printf("%s%s%s ", colorGray, formatTyping(c.typing.usernames), colorReset)
+	}
+	fmt.Print("> " + c.input.current())
+}
+
+// formatTyping renders "alice and bob are typing…"
+func formatTyping(usernames []string) string {
+	switch len(usernames) {
+	case 1:
+		return usernames[0] + " is typing…"
+	case 2, 3:
+		return strings.Join(usernames[:len(usernames)-1], ", ") + " and " + usernames[len(usernames)-1] + " are typing…"
+	default:
+		return "several people are typing…"
+	}
+}
+
+// restoreTerminal puts the terminal back the way the client found it
+func (c *chatClient) restoreTerminal() {
+	if c.input != nil && c.input.restore != nil {
+		c.input.restore()
+	}
+}
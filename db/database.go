@@ -1,91 +1,93 @@
 package db
 
 import (
-    "database/sql"
+    "errors"
     "fmt"
-
+    "io/fs"
     "os"
+
     "github.com/joho/godotenv"
-    _ "github.com/lib/pq"
 )
 
-var db *sql.DB
+// Config selects and configures the storage backend
+type Config struct {
+    // Driver is "postgres", "sqlite" or "memory"
+    Driver string
+    // DSN is the Postgres connection string or the SQLite database file
+    DSN string
+    // AutoMigrate applies pending migrations when the store is opened.
+    // Without it, opening fails while migrations are pending.
+    AutoMigrate bool
+}
 
+// ConfigFromEnv builds a Config from the environment, reading a .env file
+// first when there is one. DB_DRIVER defaults to postgres, which is
+// configured through the DB_* variables, while sqlite uses DB_PATH.
+func ConfigFromEnv() (Config, error) {
+    if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+        return Config{}, fmt.Errorf("error loading .env file: %v", err)
+    }
 
-func InitDB() (*sql.DB, error) {
-    err := godotenv.Load()
-    if err != nil {
-        return nil, fmt.Errorf("error loading .env file: %v", err)
+    cfg := Config{
+        Driver:      os.Getenv("DB_DRIVER"),
+        AutoMigrate: os.Getenv("DB_AUTO_MIGRATE") != "false",
     }
-    
-    dbUser := os.Getenv("DB_USER")
-    dbPassword := os.Getenv("DB_PASSWORD")
-    dbName := os.Getenv("DB_NAME")
-    dbHost := os.Getenv("DB_HOST")
-    dbPort := os.Getenv("DB_PORT")
-    dbSSLMode := os.Getenv("DB_SSLMODE")
-    
-    connStr := fmt.Sprintf(
-        "user=%s password=%s dbname=%s host=%s port=%s sslmode=%s",
-        dbUser, dbPassword, dbName, dbHost, dbPort, dbSSLMode,
-    )
-    
-    database, err := sql.Open("postgres", connStr)
-    if err != nil {
-        return nil, fmt.Errorf("failed to connect to database: %v", err)
+
+    switch cfg.Driver {
+    case "", "postgres":
+        cfg.Driver = "postgres"
+        cfg.DSN = fmt.Sprintf(
+            "user=%s password=%s dbname=%s host=%s port=%s sslmode=%s",
+            os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD"), os.Getenv("DB_NAME"),
+            os.Getenv("DB_HOST"), os.Getenv("DB_PORT"), os.Getenv("DB_SSLMODE"),
+        )
+    case "sqlite":
+        cfg.DSN = os.Getenv("DB_PATH")
+        if cfg.DSN == "" {
+            cfg.DSN = "zenith.db"
+        }
     }
-    
-    if err := database.Ping(); err != nil {
-        return nil, fmt.Errorf("failed to ping database: %v", err)
+
+    return cfg, nil
+}
+
+// Connect opens the configured backend without touching its schema
+func Connect(cfg Config) (Store, error) {
+    var (
+        store *sqlStore
+        err   error
+    )
+
+    switch cfg.Driver {
+    case "memory":
+        return newMemoryStore(), nil
+    case "postgres":
+        store, err = openPostgres(cfg.DSN)
+    case "sqlite":
+        store, err = openSQLite(cfg.DSN)
+    default:
+        return nil, fmt.Errorf("unknown database driver %q", cfg.Driver)
     }
-    
-    // Store in package variable for backwards compatibility
-    db = database
-    
-    if err := createTables(database); err != nil {
+    if err != nil {
         return nil, err
     }
-    
-    return database, nil
+
+    return store, nil
 }
 
-func createTables(db *sql.DB) error {
-    query := `
-    CREATE TABLE IF NOT EXISTS users (
-        id SERIAL PRIMARY KEY,
-        username TEXT UNIQUE NOT NULL,
-        password TEXT NOT NULL
-    );
-    CREATE TABLE IF NOT EXISTS rooms (
-        id SERIAL PRIMARY KEY,
-        name TEXT UNIQUE NOT NULL
-    );
-    CREATE TABLE IF NOT EXISTS messages (
-        id SERIAL PRIMARY KEY,
-        user_id INTEGER REFERENCES users(id),
-        room_id INTEGER REFERENCES rooms(id),
-        message TEXT NOT NULL
-    );
-    `
-    
-    _, err := db.Exec(query)
+// Open connects to the configured backend and prepares its schema
+func Open(cfg Config) (Store, error) {
+    store, err := Connect(cfg)
     if err != nil {
-        return fmt.Errorf("failed to create tables: %v", err)
+        return nil, err
     }
-    
-    return nil
-}
-
-// For backwards compatibility - use these if you're not using the returned DB connection
 
-func QueryRow(query string, args ...interface{}) *sql.Row {
-    return db.QueryRow(query, args...)
-}
+    if m, ok := store.(*sqlStore); ok {
+        if err := m.prepareSchema(cfg.AutoMigrate); err != nil {
+            store.Close()
+            return nil, err
+        }
+    }
 
-func Exec(query string, args ...interface{}) (sql.Result, error) {
-    return db.Exec(query, args...)
+    return store, nil
 }
-
-func Query(query string, args ...interface{}) (*sql.Rows, error) {
-    return db.Query(query, args...)
-}
\ No newline at end of file
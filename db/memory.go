@@ -0,0 +1,1285 @@
+package db
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryStore keeps everything in process memory. Nothing survives a
+// restart, so it suits tests and throwaway deployments.
+type memoryStore struct {
+	mu            sync.RWMutex
+	users         map[int32]*User
+	sessions      map[string]*Session
+	resets        map[string]*PasswordReset // keyed by code hash
+	loginFailures map[string]*LoginFailure
+	publicKeys    map[int32]*PublicKey
+	rooms         map[int32]*Room
+	memberships   map[int32]map[int32]*Membership // user ID -> room ID
+	invites       map[string]*RoomInvite          // keyed by code hash
+	joinRequests  map[[2]int32]*JoinRequest       // room ID, user ID
+	restrictions  map[restrictionKey]*Restriction // bans and mutes
+	blockedWords  map[int32][]string              // room ID -> sorted words
+	messages      []*Message                      // ordered by ID
+	conversations map[[2]int32]*Conversation      // lower user ID first
+	readAt        map[int32]int64                 // message ID -> read time
+	edits         map[int32][]*MessageEdit        // message ID -> oldest first
+	replyCounts   map[int32]int32                 // message ID -> replies
+	reactions     map[int32][]*reaction           // message ID -> oldest first
+	auditEvents   []*AuditEvent                   // ordered by ID
+	nextUserID    int32
+	nextRoomID    int32
+	nextMessageID int32
+	nextConvID    int32
+	nextAuditID   int32
+}
+
+type reaction struct {
+	userID    int32
+	emoji     string
+	createdAt int64
+}
+
+type restrictionKey struct {
+	roomID, userID int32
+	kind           string
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		users:         make(map[int32]*User),
+		sessions:      make(map[string]*Session),
+		resets:        make(map[string]*PasswordReset),
+		loginFailures: make(map[string]*LoginFailure),
+		publicKeys:    make(map[int32]*PublicKey),
+		rooms:         make(map[int32]*Room),
+		memberships:   make(map[int32]map[int32]*Membership),
+		invites:       make(map[string]*RoomInvite),
+		joinRequests:  make(map[[2]int32]*JoinRequest),
+		restrictions:  make(map[restrictionKey]*Restriction),
+		blockedWords:  make(map[int32][]string),
+		conversations: make(map[[2]int32]*Conversation),
+		readAt:        make(map[int32]int64),
+		edits:         make(map[int32][]*MessageEdit),
+		replyCounts:   make(map[int32]int32),
+		reactions:     make(map[int32][]*reaction),
+		nextUserID:    1,
+		nextRoomID:    1,
+		nextMessageID: 1,
+		nextConvID:    1,
+		nextAuditID:   1,
+	}
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
+
+func (s *memoryStore) findUser(username string) *User {
+	for _, user := range s.users {
+		if user.Username == username {
+			return user
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) CreateUser(ctx context.Context, username, passwordHash string) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.findUser(username) != nil {
+		return nil, ErrConflict
+	}
+
+	user := &User{ID: s.nextUserID, Username: username, PasswordHash: passwordHash}
+	s.nextUserID++
+	s.users[user.ID] = user
+
+	copied := *user
+	return &copied, nil
+}
+
+func (s *memoryStore) GetUser(ctx context.Context, id int32) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (s *memoryStore) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user := s.findUser(username)
+	if user == nil {
+		return nil, ErrNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (s *memoryStore) UpdateUsername(ctx context.Context, id int32, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if other := s.findUser(username); other != nil && other.ID != id {
+		return ErrConflict
+	}
+	user.Username = username
+	return nil
+}
+
+func (s *memoryStore) CreateSession(ctx context.Context, session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.sessions[session.ID]; ok {
+		return ErrConflict
+	}
+	for id, existing := range s.sessions {
+		if existing.UserID == session.UserID && existing.ExpiresAt <= session.CreatedAt {
+			delete(s.sessions, id)
+		}
+	}
+
+	stored := *session
+	s.sessions[session.ID] = &stored
+	return nil
+}
+
+func (s *memoryStore) GetSession(ctx context.Context, id string) (*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *session
+	return &copied, nil
+}
+
+func (s *memoryStore) UpdatePassword(ctx context.Context, id int32, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.PasswordHash = passwordHash
+	return nil
+}
+
+func (s *memoryStore) SetAdmin(ctx context.Context, id int32, admin bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.IsAdmin = admin
+	return nil
+}
+
+func (s *memoryStore) DeleteUser(ctx context.Context, id int32, placeholder string, deleteMessages bool) (map[int32]int32, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if other := s.findUser(placeholder); other != nil && other.ID != id {
+		return nil, ErrConflict
+	}
+	user.Username = placeholder
+	user.PasswordHash = ""
+	user.IsAdmin = false
+
+	for sessionID, session := range s.sessions {
+		if session.UserID == id {
+			delete(s.sessions, sessionID)
+		}
+	}
+	for codeHash, reset := range s.resets {
+		if reset.UserID == id {
+			delete(s.resets, codeHash)
+		}
+	}
+	delete(s.publicKeys, id)
+	delete(s.memberships, id)
+	owners := s.handOverRooms(id)
+	for key := range s.restrictions {
+		if key.userID == id {
+			delete(s.restrictions, key)
+		}
+	}
+	for key := range s.joinRequests {
+		if key[1] == id {
+			delete(s.joinRequests, key)
+		}
+	}
+	for messageID, reactions := range s.reactions {
+		s.reactions[messageID] = slices.DeleteFunc(reactions, func(r *reaction) bool { return r.userID == id })
+	}
+
+	if deleteMessages {
+		s.messages = slices.DeleteFunc(s.messages, func(msg *Message) bool {
+			if msg.UserID != id {
+				return false
+			}
+			delete(s.readAt, msg.ID)
+			delete(s.edits, msg.ID)
+			delete(s.reactions, msg.ID)
+			delete(s.replyCounts, msg.ID)
+			if msg.ThreadRootID != 0 {
+				s.replyCounts[msg.ThreadRootID]--
+			}
+			return true
+		})
+		// threads started by the deleted messages come apart, and replies
+		// to them no longer answer anything
+		for _, msg := range s.messages {
+			if msg.ThreadRootID != 0 && s.findMessage(msg.ThreadRootID) == nil {
+				msg.ThreadRootID = 0
+				msg.ReplyToID = 0
+			}
+			if msg.ReplyToID != 0 && s.findMessage(msg.ReplyToID) == nil {
+				msg.ReplyToID = 0
+			}
+		}
+	}
+	return owners, nil
+}
+
+// handOverRooms passes the rooms a user created to their most senior other
+// member: the earliest to join among those of the highest role. Callers must
+// hold s.mu and have removed the user's memberships.
+func (s *memoryStore) handOverRooms(userID int32) map[int32]int32 {
+	ranks := map[string]int{RoleOwner: 0, RoleModerator: 1}
+	rank := func(m *Membership) int {
+		if r, ok := ranks[m.Role]; ok {
+			return r
+		}
+		return len(ranks)
+	}
+
+	owners := make(map[int32]int32)
+	for _, room := range s.rooms {
+		if room.CreatedBy != userID {
+			continue
+		}
+		var next *Membership
+		for _, rooms := range s.memberships {
+			m, ok := rooms[room.ID]
+			if !ok {
+				continue
+			}
+			if next == nil || rank(m) < rank(next) ||
+				rank(m) == rank(next) && (m.JoinedAt < next.JoinedAt || m.JoinedAt == next.JoinedAt && m.UserID < next.UserID) {
+				next = m
+			}
+		}
+		room.CreatedBy = 0
+		if next != nil {
+			next.Role = RoleOwner
+			room.CreatedBy = next.UserID
+		}
+		owners[room.ID] = room.CreatedBy
+	}
+	return owners
+}
+
+func (s *memoryStore) DeleteSessions(ctx context.Context, userID int32, keepID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for id, session := range s.sessions {
+		if session.UserID == userID && id != keepID {
+			delete(s.sessions, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *memoryStore) GetLoginFailure(ctx context.Context, subject string) (*LoginFailure, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	f, ok := s.loginFailures[subject]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *f
+	return &copied, nil
+}
+
+func (s *memoryStore) SaveLoginFailure(ctx context.Context, f *LoginFailure) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *f
+	s.loginFailures[f.Subject] = &stored
+	return nil
+}
+
+func (s *memoryStore) ClearLoginFailure(ctx context.Context, subject string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.loginFailures, subject)
+	return nil
+}
+
+func (s *memoryStore) SavePublicKey(ctx context.Context, key *PublicKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *key
+	stored.Key = slices.Clone(key.Key)
+	s.publicKeys[key.UserID] = &stored
+	return nil
+}
+
+func (s *memoryStore) GetPublicKey(ctx context.Context, userID int32) (*PublicKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.publicKeys[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *key
+	copied.Key = slices.Clone(key.Key)
+	return &copied, nil
+}
+
+func (s *memoryStore) CreatePasswordReset(ctx context.Context, reset *PasswordReset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.resets[reset.CodeHash]; ok {
+		return ErrConflict
+	}
+	for hash, existing := range s.resets {
+		if existing.UserID == reset.UserID {
+			delete(s.resets, hash)
+		}
+	}
+
+	stored := *reset
+	s.resets[reset.CodeHash] = &stored
+	return nil
+}
+
+func (s *memoryStore) TakePasswordReset(ctx context.Context, codeHash string) (*PasswordReset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reset, ok := s.resets[codeHash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	delete(s.resets, codeHash)
+	return reset, nil
+}
+
+func (s *memoryStore) CreateRoom(ctx context.Context, room *Room) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.rooms {
+		if existing.Name == room.Name {
+			return ErrConflict
+		}
+	}
+
+	room.ID = s.nextRoomID
+	s.nextRoomID++
+
+	stored := *room
+	stored.Metadata = copyMetadata(room.Metadata)
+	s.rooms[room.ID] = &stored
+	return nil
+}
+
+func copyMetadata(metadata map[string]string) map[string]string {
+	copied := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		copied[k] = v
+	}
+	return copied
+}
+
+func (s *memoryStore) ListRooms(ctx context.Context) ([]*Room, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rooms := make([]*Room, 0, len(s.rooms))
+	for _, room := range s.rooms {
+		copied := *room
+		copied.Metadata = copyMetadata(room.Metadata)
+		rooms = append(rooms, &copied)
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].ID < rooms[j].ID })
+	return rooms, nil
+}
+
+func (s *memoryStore) UpdateRoomAccess(ctx context.Context, roomID int32, visibility, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room, ok := s.rooms[roomID]
+	if !ok {
+		return ErrNotFound
+	}
+	room.Visibility = visibility
+	room.PasswordHash = passwordHash
+	return nil
+}
+
+func (s *memoryStore) AddMembership(ctx context.Context, roomID, userID int32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rooms, ok := s.memberships[userID]
+	if !ok {
+		rooms = make(map[int32]*Membership)
+		s.memberships[userID] = rooms
+	}
+	if _, ok := rooms[roomID]; !ok {
+		rooms[roomID] = &Membership{RoomID: roomID, UserID: userID, JoinedAt: time.Now().Unix(), Role: RoleMember}
+	}
+	return nil
+}
+
+func (s *memoryStore) RemoveMembership(ctx context.Context, roomID, userID int32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.memberships[userID], roomID)
+	return nil
+}
+
+func (s *memoryStore) SetMemberRole(ctx context.Context, roomID, userID int32, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.memberships[userID][roomID]
+	if !ok {
+		return ErrNotFound
+	}
+	m.Role = role
+	return nil
+}
+
+func (s *memoryStore) GetMembership(ctx context.Context, roomID, userID int32) (*Membership, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m, ok := s.memberships[userID][roomID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *m
+	return &copied, nil
+}
+
+func (s *memoryStore) ListMemberships(ctx context.Context, userID int32) ([]*Membership, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	memberships := make([]*Membership, 0, len(s.memberships[userID]))
+	for _, m := range s.memberships[userID] {
+		copied := *m
+		memberships = append(memberships, &copied)
+	}
+	sort.Slice(memberships, func(i, j int) bool {
+		if memberships[i].JoinedAt != memberships[j].JoinedAt {
+			return memberships[i].JoinedAt < memberships[j].JoinedAt
+		}
+		return memberships[i].RoomID < memberships[j].RoomID
+	})
+	return memberships, nil
+}
+
+func (s *memoryStore) MarkRoomRead(ctx context.Context, roomID, userID, messageID int32) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.memberships[userID][roomID]
+	if !ok {
+		return false, ErrNotFound
+	}
+	if m.LastReadID >= messageID {
+		return false, nil
+	}
+	m.LastReadID = messageID
+	return true, nil
+}
+
+func (s *memoryStore) RoomUnreadCounts(ctx context.Context, userID int32) (map[int32]int32, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rooms := s.memberships[userID]
+	counts := make(map[int32]int32)
+	for _, msg := range s.messages {
+		m, ok := rooms[msg.RoomID]
+		if !ok || msg.IsDirect || msg.IsSystem || msg.UserID == userID || msg.DeletedAt != 0 || msg.ID <= m.LastReadID {
+			continue
+		}
+		counts[msg.RoomID]++
+	}
+	return counts, nil
+}
+
+func (s *memoryStore) RoomUnreadCount(ctx context.Context, roomID, userID int32) (int32, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m, ok := s.memberships[userID][roomID]
+	if !ok {
+		return 0, nil
+	}
+	var count int32
+	for _, msg := range s.messages {
+		if msg.RoomID != roomID || msg.IsDirect || msg.IsSystem || msg.UserID == userID || msg.DeletedAt != 0 || msg.ID <= m.LastReadID {
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (s *memoryStore) RoomReaders(ctx context.Context, roomID, messageID int32) ([]*ReadReceipt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var readers []*ReadReceipt
+	for userID, rooms := range s.memberships {
+		m, ok := rooms[roomID]
+		user, exists := s.users[userID]
+		if !ok || !exists || m.LastReadID < messageID {
+			continue
+		}
+		readers = append(readers, &ReadReceipt{UserID: userID, Username: user.Username, LastReadID: m.LastReadID})
+	}
+	sort.Slice(readers, func(i, j int) bool {
+		return readers[i].Username < readers[j].Username
+	})
+	return readers, nil
+}
+
+func (s *memoryStore) SaveRestriction(ctx context.Context, r *Restriction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *r
+	s.restrictions[restrictionKey{r.RoomID, r.UserID, r.Kind}] = &stored
+	return nil
+}
+
+func (s *memoryStore) GetRestriction(ctx context.Context, roomID, userID int32, kind string) (*Restriction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r, ok := s.restrictions[restrictionKey{roomID, userID, kind}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *r
+	return &copied, nil
+}
+
+func (s *memoryStore) DeleteRestriction(ctx context.Context, roomID, userID int32, kind string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := restrictionKey{roomID, userID, kind}
+	if _, ok := s.restrictions[key]; !ok {
+		return ErrNotFound
+	}
+	delete(s.restrictions, key)
+	return nil
+}
+
+func (s *memoryStore) UserRestrictions(ctx context.Context, userID int32) ([]*Restriction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var restrictions []*Restriction
+	for _, r := range s.restrictions {
+		if r.UserID == userID {
+			copied := *r
+			restrictions = append(restrictions, &copied)
+		}
+	}
+	sort.Slice(restrictions, func(i, j int) bool {
+		a, b := restrictions[i], restrictions[j]
+		if a.CreatedAt != b.CreatedAt {
+			return a.CreatedAt < b.CreatedAt
+		}
+		if a.RoomID != b.RoomID {
+			return a.RoomID < b.RoomID
+		}
+		return a.Kind < b.Kind
+	})
+	return restrictions, nil
+}
+
+func (s *memoryStore) SetBlockedWords(ctx context.Context, roomID int32, words []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sorted := slices.Clone(words)
+	slices.Sort(sorted)
+	s.blockedWords[roomID] = slices.Compact(sorted)
+	return nil
+}
+
+func (s *memoryStore) ListBlockedWords(ctx context.Context, roomID int32) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return slices.Clone(s.blockedWords[roomID]), nil
+}
+
+func (s *memoryStore) CreateRoomInvite(ctx context.Context, invite *RoomInvite) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.invites[invite.CodeHash]; ok {
+		return ErrConflict
+	}
+	stored := *invite
+	s.invites[invite.CodeHash] = &stored
+	return nil
+}
+
+func (s *memoryStore) UseRoomInvite(ctx context.Context, codeHash string, now int64) (*RoomInvite, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	invite, ok := s.invites[codeHash]
+	if !ok || (invite.ExpiresAt != 0 && invite.ExpiresAt <= now) || (invite.MaxUses != 0 && invite.Uses >= invite.MaxUses) {
+		return nil, ErrNotFound
+	}
+	invite.Uses++
+	copied := *invite
+	return &copied, nil
+}
+
+func (s *memoryStore) CreateJoinRequest(ctx context.Context, roomID, userID int32, requestedAt int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := [2]int32{roomID, userID}
+	if _, ok := s.joinRequests[key]; ok {
+		return ErrConflict
+	}
+	s.joinRequests[key] = &JoinRequest{RoomID: roomID, UserID: userID, RequestedAt: requestedAt}
+	return nil
+}
+
+func (s *memoryStore) ListJoinRequests(ctx context.Context, roomID int32) ([]*JoinRequest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var requests []*JoinRequest
+	for _, r := range s.joinRequests {
+		if r.RoomID != roomID {
+			continue
+		}
+		copied := *r
+		if user, ok := s.users[r.UserID]; ok {
+			copied.Username = user.Username
+		}
+		requests = append(requests, &copied)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		if requests[i].RequestedAt != requests[j].RequestedAt {
+			return requests[i].RequestedAt < requests[j].RequestedAt
+		}
+		return requests[i].UserID < requests[j].UserID
+	})
+	return requests, nil
+}
+
+func (s *memoryStore) DeleteJoinRequest(ctx context.Context, roomID, userID int32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := [2]int32{roomID, userID}
+	if _, ok := s.joinRequests[key]; !ok {
+		return ErrNotFound
+	}
+	delete(s.joinRequests, key)
+	return nil
+}
+
+func (s *memoryStore) UserJoinRequests(ctx context.Context, userID int32) ([]*JoinRequest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var requests []*JoinRequest
+	for _, r := range s.joinRequests {
+		if r.UserID != userID {
+			continue
+		}
+		copied := *r
+		if user, ok := s.users[r.UserID]; ok {
+			copied.Username = user.Username
+		}
+		requests = append(requests, &copied)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		if requests[i].RequestedAt != requests[j].RequestedAt {
+			return requests[i].RequestedAt < requests[j].RequestedAt
+		}
+		return requests[i].RoomID < requests[j].RoomID
+	})
+	return requests, nil
+}
+
+func (s *memoryStore) SaveMessage(ctx context.Context, msg *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg.ID = s.nextMessageID
+	s.nextMessageID++
+
+	stored := *msg
+	s.messages = append(s.messages, &stored)
+	if msg.ThreadRootID != 0 {
+		s.replyCounts[msg.ThreadRootID]++
+	}
+	return nil
+}
+
+func (s *memoryStore) UserMessages(ctx context.Context, userID, afterID int32, limit int) ([]*Message, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// collect one extra message to find out whether another page exists
+	var messages []*Message
+	for i := 0; i < len(s.messages) && len(messages) <= limit; i++ {
+		msg := s.messages[i]
+		if msg.UserID != userID || msg.IsDirect || msg.DeletedAt != 0 || msg.ID <= afterID {
+			continue
+		}
+		messages = append(messages, s.withUsername(msg))
+	}
+	messages, hasMore := trimPage(messages, limit)
+	return messages, hasMore, nil
+}
+
+func (s *memoryStore) GetMessage(ctx context.Context, id int32) (*Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	msg := s.findMessage(id)
+	if msg == nil {
+		return nil, ErrNotFound
+	}
+	return s.withUsername(msg), nil
+}
+
+func (s *memoryStore) EditMessage(ctx context.Context, id int32, content string, editedBy int32, editedAt int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg := s.findMessage(id)
+	if msg == nil || msg.DeletedAt != 0 {
+		return ErrNotFound
+	}
+	s.edits[id] = append(s.edits[id], &MessageEdit{
+		MessageID: id,
+		Content:   msg.Content,
+		EditedBy:  editedBy,
+		EditedAt:  editedAt,
+	})
+	msg.Content = content
+	msg.EditedAt = editedAt
+	return nil
+}
+
+func (s *memoryStore) DeleteMessage(ctx context.Context, id int32, deletedAt int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg := s.findMessage(id)
+	if msg == nil || msg.DeletedAt != 0 {
+		return ErrNotFound
+	}
+	msg.Content = ""
+	msg.DeletedAt = deletedAt
+	delete(s.edits, id)
+	delete(s.reactions, id)
+	return nil
+}
+
+func (s *memoryStore) MessageEdits(ctx context.Context, messageID int32) ([]*MessageEdit, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	edits := make([]*MessageEdit, 0, len(s.edits[messageID]))
+	for _, edit := range s.edits[messageID] {
+		copied := *edit
+		if user, ok := s.users[edit.EditedBy]; ok {
+			copied.EditedByName = user.Username
+		}
+		edits = append(edits, &copied)
+	}
+	return edits, nil
+}
+
+func (s *memoryStore) ThreadReplies(ctx context.Context, messageID, afterID int32, limit int) ([]*Message, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// replies always come after the message they answer
+	start := sort.Search(len(s.messages), func(i int) bool { return s.messages[i].ID > max(messageID, afterID) })
+
+	// collect one extra message to find out whether another page exists
+	var messages []*Message
+	for i := start; i < len(s.messages) && len(messages) <= limit; i++ {
+		if s.messages[i].ThreadRootID == messageID {
+			messages = append(messages, s.withUsername(s.messages[i]))
+		}
+	}
+	messages, hasMore := trimPage(messages, limit)
+	return messages, hasMore, nil
+}
+
+func (s *memoryStore) AddReaction(ctx context.Context, messageID, userID int32, emoji string, createdAt int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.findMessage(messageID) == nil {
+		return ErrNotFound
+	}
+	for _, r := range s.reactions[messageID] {
+		if r.userID == userID && r.emoji == emoji {
+			return ErrConflict
+		}
+	}
+	s.reactions[messageID] = append(s.reactions[messageID], &reaction{userID: userID, emoji: emoji, createdAt: createdAt})
+	return nil
+}
+
+func (s *memoryStore) RemoveReaction(ctx context.Context, messageID, userID int32, emoji string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reactions := s.reactions[messageID]
+	i := slices.IndexFunc(reactions, func(r *reaction) bool { return r.userID == userID && r.emoji == emoji })
+	if i < 0 {
+		return ErrNotFound
+	}
+	s.reactions[messageID] = slices.Delete(reactions, i, i+1)
+	return nil
+}
+
+func (s *memoryStore) MessageReactions(ctx context.Context, messageIDs []int32) (map[int32][]*Reaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[int32][]*Reaction)
+	for _, id := range messageIDs {
+		// reactions are kept oldest first, so emojis are counted in the
+		// order they were first used
+		var counts []*Reaction
+		for _, r := range s.reactions[id] {
+			i := slices.IndexFunc(counts, func(c *Reaction) bool { return c.Emoji == r.emoji })
+			if i < 0 {
+				counts = append(counts, &Reaction{Emoji: r.emoji})
+				i = len(counts) - 1
+			}
+			counts[i].Count++
+		}
+		if len(counts) > 0 {
+			result[id] = counts
+		}
+	}
+	return result, nil
+}
+
+func (s *memoryStore) UserReactions(ctx context.Context, userID int32) ([]*UserReaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var reactions []*UserReaction
+	for messageID, list := range s.reactions {
+		for _, r := range list {
+			if r.userID != userID {
+				continue
+			}
+			reaction := &UserReaction{MessageID: messageID, Emoji: r.emoji, CreatedAt: r.createdAt}
+			if msg := s.findMessage(messageID); msg != nil {
+				reaction.RoomID = msg.RoomID
+			}
+			reactions = append(reactions, reaction)
+		}
+	}
+	sort.Slice(reactions, func(i, j int) bool {
+		a, b := reactions[i], reactions[j]
+		if a.CreatedAt != b.CreatedAt {
+			return a.CreatedAt < b.CreatedAt
+		}
+		if a.MessageID != b.MessageID {
+			return a.MessageID < b.MessageID
+		}
+		return a.Emoji < b.Emoji
+	})
+	return reactions, nil
+}
+
+func (s *memoryStore) PendingDirectMessages(ctx context.Context, recipientID int32) ([]*Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var messages []*Message
+	for _, msg := range s.messages {
+		if msg.IsDirect && msg.RecipientID == recipientID && msg.DeliveredAt == 0 {
+			messages = append(messages, s.withUsername(msg))
+		}
+	}
+	return messages, nil
+}
+
+func (s *memoryStore) MarkDelivered(ctx context.Context, messageID int32, deliveredAt int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if msg := s.findMessage(messageID); msg != nil && msg.DeliveredAt == 0 {
+		msg.DeliveredAt = deliveredAt
+	}
+	return nil
+}
+
+// findMessage looks a message up by ID, relying on s.messages being sorted
+func (s *memoryStore) findMessage(id int32) *Message {
+	i := sort.Search(len(s.messages), func(i int) bool { return s.messages[i].ID >= id })
+	if i < len(s.messages) && s.messages[i].ID == id {
+		return s.messages[i]
+	}
+	return nil
+}
+
+// withUsername copies msg with the sender's current username and its reply
+// count filled in
+func (s *memoryStore) withUsername(msg *Message) *Message {
+	copied := *msg
+	copied.ReplyCount = s.replyCounts[msg.ID]
+	if user, ok := s.users[msg.UserID]; ok {
+		copied.Username = user.Username
+	} else {
+		copied.Username = "SYSTEM"
+	}
+	return &copied
+}
+
+func (s *memoryStore) ConversationID(ctx context.Context, userA, userB int32) (int32, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if userA > userB {
+		userA, userB = userB, userA
+	}
+	key := [2]int32{userA, userB}
+	if c, ok := s.conversations[key]; ok {
+		return c.ID, nil
+	}
+
+	c := &Conversation{ID: s.nextConvID, CreatedAt: time.Now().Unix()}
+	s.nextConvID++
+	s.conversations[key] = c
+	return c.ID, nil
+}
+
+func (s *memoryStore) FindConversation(ctx context.Context, userA, userB int32) (int32, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if userA > userB {
+		userA, userB = userB, userA
+	}
+	c, ok := s.conversations[[2]int32{userA, userB}]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	return c.ID, nil
+}
+
+func (s *memoryStore) ListConversations(ctx context.Context, userID int32) ([]*Conversation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	byID := make(map[int32]*Conversation)
+	for key, c := range s.conversations {
+		peerID := key[0]
+		if peerID == userID {
+			peerID = key[1]
+		} else if key[1] != userID {
+			continue
+		}
+		peer, ok := s.users[peerID]
+		if !ok {
+			continue
+		}
+		byID[c.ID] = &Conversation{ID: c.ID, PeerID: peerID, PeerUsername: peer.Username, CreatedAt: c.CreatedAt}
+	}
+
+	for _, msg := range s.messages {
+		c, ok := byID[msg.ConversationID]
+		if !ok {
+			continue
+		}
+		c.LastMessage = s.withUsername(msg)
+		if _, read := s.readAt[msg.ID]; msg.RecipientID == userID && !read {
+			c.UnreadCount++
+		}
+	}
+
+	conversations := make([]*Conversation, 0, len(byID))
+	for _, c := range byID {
+		conversations = append(conversations, c)
+	}
+	lastID := func(c *Conversation) int32 {
+		if c.LastMessage == nil {
+			return 0
+		}
+		return c.LastMessage.ID
+	}
+	sort.Slice(conversations, func(i, j int) bool {
+		if a, b := lastID(conversations[i]), lastID(conversations[j]); a != b {
+			return a > b
+		}
+		return conversations[i].ID > conversations[j].ID
+	})
+	return conversations, nil
+}
+
+func (s *memoryStore) ConversationHistory(ctx context.Context, conversationID, beforeID int32, limit int) ([]*Message, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// collect one extra message to find out whether another page exists
+	var messages []*Message
+	for i := len(s.messages) - 1; i >= 0 && len(messages) <= limit; i-- {
+		msg := s.messages[i]
+		if msg.ConversationID != conversationID || (beforeID > 0 && msg.ID >= beforeID) {
+			continue
+		}
+		messages = append(messages, s.withUsername(msg))
+	}
+	messages, hasMore := trimPage(messages, limit)
+	reverse(messages)
+	return messages, hasMore, nil
+}
+
+func (s *memoryStore) MarkConversationRead(ctx context.Context, conversationID, userID int32, readAt int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, msg := range s.messages {
+		if msg.ConversationID != conversationID || msg.RecipientID != userID {
+			continue
+		}
+		if _, ok := s.readAt[msg.ID]; !ok {
+			s.readAt[msg.ID] = readAt
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) RoomHistory(ctx context.Context, roomID, beforeID, afterID int32, limit int) ([]*Message, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	inPage := func(msg *Message) bool {
+		if msg.RoomID != roomID || msg.IsDirect {
+			return false
+		}
+		if afterID > 0 {
+			return msg.ID > afterID
+		}
+		return beforeID <= 0 || msg.ID < beforeID
+	}
+
+	// collect one extra message to find out whether another page exists
+	var messages []*Message
+	if afterID > 0 {
+		for i := 0; i < len(s.messages) && len(messages) <= limit; i++ {
+			if inPage(s.messages[i]) {
+				messages = append(messages, s.withUsername(s.messages[i]))
+			}
+		}
+		messages, hasMore := trimPage(messages, limit)
+		return messages, hasMore, nil
+	}
+
+	for i := len(s.messages) - 1; i >= 0 && len(messages) <= limit; i-- {
+		if inPage(s.messages[i]) {
+			messages = append(messages, s.withUsername(s.messages[i]))
+		}
+	}
+	messages, hasMore := trimPage(messages, limit)
+	reverse(messages)
+	return messages, hasMore, nil
+}
+
+func (s *memoryStore) RoomTranscript(ctx context.Context, roomID int32, since, until int64, afterID int32, limit int) ([]*Message, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// collect one extra message to find out whether another page exists
+	var messages []*Message
+	for i := 0; i < len(s.messages) && len(messages) <= limit; i++ {
+		msg := s.messages[i]
+		if msg.RoomID != roomID || msg.IsDirect || msg.ID <= afterID {
+			continue
+		}
+		if (since > 0 && msg.Timestamp < since) || (until > 0 && msg.Timestamp >= until) {
+			continue
+		}
+		messages = append(messages, s.withUsername(msg))
+	}
+	messages, hasMore := trimPage(messages, limit)
+	return messages, hasMore, nil
+}
+
+// SearchMessages matches messages containing every word of the query,
+// ignoring case, and ranks them by how often the words occur
+func (s *memoryStore) SearchMessages(ctx context.Context, q SearchQuery) ([]*SearchResult, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	words := strings.Fields(strings.ToLower(q.Text))
+	if len(words) == 0 {
+		return nil, false, nil
+	}
+
+	var results []*SearchResult
+	for _, msg := range s.messages {
+		if msg.IsDirect || msg.IsSystem {
+			continue
+		}
+		room, ok := s.rooms[msg.RoomID]
+		if !ok {
+			continue
+		}
+		if q.RoomID != 0 && msg.RoomID != q.RoomID {
+			continue
+		}
+		if q.RoomIDs != nil && !slices.Contains(q.RoomIDs, msg.RoomID) {
+			continue
+		}
+		if q.Since != 0 && msg.Timestamp < q.Since {
+			continue
+		}
+		if q.Until != 0 && msg.Timestamp >= q.Until {
+			continue
+		}
+		found := s.withUsername(msg)
+		if q.Username != "" && found.Username != q.Username {
+			continue
+		}
+
+		content := strings.ToLower(msg.Content)
+		var rank float32
+		for _, word := range words {
+			n := strings.Count(content, word)
+			if n == 0 {
+				rank = 0
+				break
+			}
+			rank += float32(n)
+		}
+		if rank == 0 {
+			continue
+		}
+
+		results = append(results, &SearchResult{Message: found, RoomName: room.Name, Rank: rank})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].Message.ID > results[j].Message.ID
+	})
+
+	if q.Offset >= len(results) {
+		return nil, false, nil
+	}
+	results, hasMore := trimPage(results[q.Offset:], q.Limit)
+	return results, hasMore, nil
+}
+
+func (s *memoryStore) AppendAuditEvent(ctx context.Context, event *AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event.ID = s.nextAuditID
+	s.nextAuditID++
+
+	stored := *event
+	s.auditEvents = append(s.auditEvents, &stored)
+	return nil
+}
+
+func (s *memoryStore) ListAuditEvents(ctx context.Context, q AuditQuery) ([]*AuditEvent, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matches := func(event *AuditEvent) bool {
+		switch {
+		case q.Action == "":
+		case strings.Contains(q.Action, "."):
+			if event.Action != q.Action {
+				return false
+			}
+		default:
+			if !strings.HasPrefix(event.Action, q.Action+".") {
+				return false
+			}
+		}
+		return (q.ActorID == 0 || event.ActorID == q.ActorID) &&
+			(q.TargetID == 0 || event.TargetID == q.TargetID) &&
+			(q.RoomID == 0 || event.RoomID == q.RoomID) &&
+			(q.Since == 0 || event.CreatedAt >= q.Since) &&
+			(q.Until == 0 || event.CreatedAt < q.Until) &&
+			(q.BeforeID == 0 || event.ID < q.BeforeID)
+	}
+
+	// collect one extra event to find out whether another page exists
+	var events []*AuditEvent
+	for i := len(s.auditEvents) - 1; i >= 0 && len(events) <= q.Limit; i-- {
+		if matches(s.auditEvents[i]) {
+			event := *s.auditEvents[i]
+			events = append(events, &event)
+		}
+	}
+	events, hasMore := trimPage(events, q.Limit)
+	return events, hasMore, nil
+}
@@ -0,0 +1,309 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// Migration is one versioned schema change. Files are named
+// NNNN_description.up.sql and NNNN_description.down.sql.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus describes whether a migration has been applied
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt int64
+}
+
+// loadMigrations reads the embedded migrations of a dialect sorted by version
+func loadMigrations(d *dialect) ([]Migration, error) {
+	dir := path.Join("migrations", d.name)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %v", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		file := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(file, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(file, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("unexpected migration file %s", file)
+		}
+
+		base := strings.TrimSuffix(file, "."+direction+".sql")
+		versionPart, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration file %s has no description", file)
+		}
+		version, err := strconv.Atoi(versionPart)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration file %s has an invalid version", file)
+		}
+
+		body, err := migrationFiles.ReadFile(path.Join(dir, file))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %v", file, err)
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// migrationLockKey names the Postgres advisory lock held while migrating
+const migrationLockKey = 0x7465726d6978
+
+// execQuerier is what the migration helpers need from a *sql.DB or the
+// *sql.Conn that holds the migration lock
+type execQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// withMigrationLock runs fn in a transaction on one connection that holds
+// the dialect's migration lock, so servers starting together on the same
+// database do not apply a migration twice. The transaction is committed
+// even when fn fails, keeping the steps that succeeded.
+func withMigrationLock(db *sql.DB, d *dialect, fn func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for _, stmt := range d.lockMigrations {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			conn.ExecContext(ctx, "ROLLBACK")
+			return fmt.Errorf("failed to lock migrations: %v", err)
+		}
+	}
+
+	fnErr := fn(conn)
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		conn.ExecContext(ctx, "ROLLBACK")
+		if fnErr == nil {
+			return fmt.Errorf("failed to commit migrations: %v", err)
+		}
+	}
+	return fnErr
+}
+
+func ensureMigrationsTable(db execQuerier) error {
+	_, err := db.ExecContext(context.Background(), `
+    CREATE TABLE IF NOT EXISTS schema_migrations (
+        version INTEGER PRIMARY KEY,
+        name TEXT NOT NULL,
+        applied_at BIGINT NOT NULL
+    )`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %v", err)
+	}
+	return nil
+}
+
+// appliedMigrations returns the applied versions and when they were applied
+func appliedMigrations(db execQuerier) (map[int]MigrationStatus, error) {
+	if err := ensureMigrationsTable(db); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(context.Background(), "SELECT version, name, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %v", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]MigrationStatus)
+	for rows.Next() {
+		st := MigrationStatus{Applied: true}
+		if err := rows.Scan(&st.Version, &st.Name, &st.AppliedAt); err != nil {
+			return nil, fmt.Errorf("failed to read schema_migrations: %v", err)
+		}
+		applied[st.Version] = st
+	}
+	return applied, rows.Err()
+}
+
+// runMigration executes one migration step and records it under a
+// savepoint, so a failed step leaves the schema untouched. The caller holds
+// the migration lock on conn.
+func runMigration(conn *sql.Conn, d *dialect, m Migration, up bool) error {
+	ctx := context.Background()
+	if _, err := conn.ExecContext(ctx, "SAVEPOINT migration"); err != nil {
+		return err
+	}
+
+	body := m.Down
+	if up {
+		body = m.Up
+	}
+	_, err := conn.ExecContext(ctx, body)
+	if err == nil && up {
+		_, err = conn.ExecContext(ctx,
+			d.rebind("INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)"),
+			m.Version, m.Name, time.Now().Unix(),
+		)
+	} else if err == nil {
+		_, err = conn.ExecContext(ctx, d.rebind("DELETE FROM schema_migrations WHERE version = $1"), m.Version)
+	}
+	if err != nil {
+		conn.ExecContext(ctx, "ROLLBACK TO SAVEPOINT migration")
+		return err
+	}
+
+	_, err = conn.ExecContext(ctx, "RELEASE SAVEPOINT migration")
+	return err
+}
+
+// migrate applies every pending migration in order and returns the ones
+// that were applied
+func migrate(db *sql.DB, d *dialect) ([]Migration, error) {
+	migrations, err := loadMigrations(d)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	err = withMigrationLock(db, d, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			if err := runMigration(conn, d, m, true); err != nil {
+				return fmt.Errorf("failed to apply migration %04d_%s: %v", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// migrateDown rolls back the most recent steps applied migrations and
+// returns the ones that were rolled back
+func migrateDown(db *sql.DB, d *dialect, steps int) ([]Migration, error) {
+	migrations, err := loadMigrations(d)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[int]Migration, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = m
+	}
+
+	var done []Migration
+	err = withMigrationLock(db, d, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+
+		versions := make([]int, 0, len(applied))
+		for version := range applied {
+			versions = append(versions, version)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+		for _, version := range versions {
+			if len(done) == steps {
+				break
+			}
+			m, ok := known[version]
+			if !ok {
+				return fmt.Errorf("migration %04d_%s is not known to this binary", version, applied[version].Name)
+			}
+			if err := runMigration(conn, d, m, false); err != nil {
+				return fmt.Errorf("failed to roll back migration %04d_%s: %v", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// migrationStatus lists every known migration and whether it has been
+// applied, followed by applied versions this binary does not know about
+func migrationStatus(db *sql.DB, d *dialect) ([]MigrationStatus, error) {
+	migrations, err := loadMigrations(d)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		st, ok := applied[m.Version]
+		if !ok {
+			st = MigrationStatus{Version: m.Version, Name: m.Name}
+		}
+		statuses = append(statuses, st)
+		delete(applied, m.Version)
+	}
+
+	var unknown []MigrationStatus
+	for _, st := range applied {
+		unknown = append(unknown, st)
+	}
+	sort.Slice(unknown, func(i, j int) bool {
+		return unknown[i].Version < unknown[j].Version
+	})
+	return append(statuses, unknown...), nil
+}
@@ -0,0 +1,183 @@
+package db
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	sqlite, err := loadMigrations(sqliteDialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	postgres, err := loadMigrations(postgresDialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		dialect    string
+		migrations []Migration
+	}{
+		{"sqlite", sqlite},
+		{"postgres", postgres},
+	} {
+		t.Run(tt.dialect, func(t *testing.T) {
+			for i, m := range tt.migrations {
+				if m.Version != i+1 {
+					t.Errorf("migration %d has version %d, versions must count up from 1", i, m.Version)
+				}
+			}
+		})
+	}
+
+	// both dialects describe the same schema history
+	if len(sqlite) != len(postgres) {
+		t.Fatalf("sqlite has %d migrations, postgres %d", len(sqlite), len(postgres))
+	}
+	for i := range sqlite {
+		if sqlite[i].Name != postgres[i].Name {
+			t.Errorf("migration %d is %s for sqlite but %s for postgres", sqlite[i].Version, sqlite[i].Name, postgres[i].Name)
+		}
+	}
+}
+
+// sqliteTables lists the tables in a SQLite database
+func sqliteTables(t *testing.T, s *sqlStore) []string {
+	t.Helper()
+	rows, err := s.db.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, name)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return tables
+}
+
+func TestSQLiteMigrateRoundTrip(t *testing.T) {
+	s, err := openSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	migrations, err := loadMigrations(sqliteDialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := s.Migrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(migrations) {
+		t.Fatalf("applied %d migrations, want %d", len(applied), len(migrations))
+	}
+	schema := sqliteTables(t, s)
+
+	steps := []struct {
+		name string
+		run  func() ([]Migration, error)
+		// done is how many migrations the step applies or rolls back
+		done int
+		// applied is how many migrations are applied afterwards
+		applied int
+	}{
+		{"up again", s.Migrate, 0, len(migrations)},
+		{"down one", func() ([]Migration, error) { return s.MigrateDown(1) }, 1, len(migrations) - 1},
+		{"up after one down", s.Migrate, 1, len(migrations)},
+		{"down all", func() ([]Migration, error) { return s.MigrateDown(len(migrations)) }, len(migrations), 0},
+		{"down past the first", func() ([]Migration, error) { return s.MigrateDown(1) }, 0, 0},
+		{"up from scratch", s.Migrate, len(migrations), len(migrations)},
+	}
+
+	for _, step := range steps {
+		done, err := step.run()
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if len(done) != step.done {
+			t.Errorf("%s: ran %d migrations, want %d", step.name, len(done), step.done)
+		}
+
+		statuses, err := s.MigrationStatus()
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if len(statuses) != len(migrations) {
+			t.Fatalf("%s: got %d statuses, want %d", step.name, len(statuses), len(migrations))
+		}
+		for i, st := range statuses {
+			if want := i < step.applied; st.Applied != want {
+				t.Errorf("%s: migration %04d_%s applied %v, want %v", step.name, st.Version, st.Name, st.Applied, want)
+			}
+		}
+
+		tables := sqliteTables(t, s)
+		switch step.applied {
+		case 0:
+			if !slices.Equal(tables, []string{"schema_migrations"}) {
+				t.Errorf("%s: tables %v left after rolling everything back", step.name, tables)
+			}
+		case len(migrations):
+			if !slices.Equal(tables, schema) {
+				t.Errorf("%s: got tables %v, want %v", step.name, tables, schema)
+			}
+		}
+	}
+}
+
+func TestSQLiteMigrateConcurrently(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	migrations, err := loadMigrations(sqliteDialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// servers starting together on one database share out the migrations
+	// instead of each applying them
+	const servers = 4
+	applied := make(chan int, servers)
+	errs := make(chan error, servers)
+	for i := 0; i < servers; i++ {
+		go func() {
+			s, err := openSQLite(path)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer s.Close()
+			done, err := s.Migrate()
+			if err != nil {
+				errs <- err
+				return
+			}
+			applied <- len(done)
+		}()
+	}
+
+	total := 0
+	for i := 0; i < servers; i++ {
+		select {
+		case err := <-errs:
+			t.Fatal(err)
+		case n := <-applied:
+			total += n
+		}
+	}
+	if total != len(migrations) {
+		t.Errorf("applied %d migrations in total, want %d", total, len(migrations))
+	}
+}
@@ -0,0 +1,7 @@
+-- the room_id foreign key is not restored, stored messages may reference
+-- rooms that no longer exist
+DROP INDEX IF EXISTS messages_room_id_idx;
+ALTER TABLE messages DROP COLUMN IF EXISTS is_direct;
+ALTER TABLE messages DROP COLUMN IF EXISTS is_system;
+ALTER TABLE messages DROP COLUMN IF EXISTS created_at;
+ALTER TABLE messages DROP COLUMN IF EXISTS recipient_id;
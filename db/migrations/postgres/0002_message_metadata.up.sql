@@ -0,0 +1,8 @@
+-- messages written before rooms were persisted point at rooms that were
+-- never stored, so room_id is not a foreign key
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_room_id_fkey;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS recipient_id INTEGER REFERENCES users(id);
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS created_at BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS is_system BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS is_direct BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE INDEX IF NOT EXISTS messages_room_id_idx ON messages (room_id, id);
@@ -0,0 +1,3 @@
+ALTER TABLE rooms DROP COLUMN IF EXISTS metadata;
+ALTER TABLE rooms DROP COLUMN IF EXISTS created_by;
+ALTER TABLE rooms DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS messages_search_idx ON messages USING GIN (to_tsvector('english', message));
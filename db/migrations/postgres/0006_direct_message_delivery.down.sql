@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS messages_undelivered_idx;
+ALTER TABLE messages DROP COLUMN IF EXISTS delivered_at;
@@ -0,0 +1,28 @@
+-- a conversation between two users, user1_id is always the lower ID
+CREATE TABLE IF NOT EXISTS conversations (
+    id SERIAL PRIMARY KEY,
+    user1_id INTEGER NOT NULL REFERENCES users(id),
+    user2_id INTEGER NOT NULL REFERENCES users(id),
+    created_at BIGINT NOT NULL,
+    UNIQUE (user1_id, user2_id)
+);
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS conversation_id INTEGER REFERENCES conversations(id);
+-- NULL until the recipient has read a direct message
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS read_at BIGINT;
+CREATE INDEX IF NOT EXISTS messages_conversation_id_idx ON messages (conversation_id, id);
+
+-- group direct messages sent so far into conversations, treating them as read
+INSERT INTO conversations (user1_id, user2_id, created_at)
+SELECT LEAST(user_id, recipient_id), GREATEST(user_id, recipient_id), MIN(created_at)
+FROM messages
+WHERE is_direct AND user_id IS NOT NULL AND recipient_id IS NOT NULL
+GROUP BY LEAST(user_id, recipient_id), GREATEST(user_id, recipient_id)
+ON CONFLICT DO NOTHING;
+
+UPDATE messages m
+SET conversation_id = c.id, read_at = m.created_at
+FROM conversations c
+WHERE m.is_direct
+    AND c.user1_id = LEAST(m.user_id, m.recipient_id)
+    AND c.user2_id = GREATEST(m.user_id, m.recipient_id);
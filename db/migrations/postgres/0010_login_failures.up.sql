@@ -0,0 +1,7 @@
+-- failed login attempts, keyed by "user:<username>" or "ip:<address>"
+CREATE TABLE IF NOT EXISTS login_failures (
+    subject TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    last_failure_at BIGINT NOT NULL,
+    locked_until BIGINT NOT NULL DEFAULT 0
+);
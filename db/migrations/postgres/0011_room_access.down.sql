@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS room_join_requests;
+DROP TABLE IF EXISTS room_invites;
+ALTER TABLE rooms DROP COLUMN IF EXISTS password_hash;
+ALTER TABLE rooms DROP COLUMN IF EXISTS visibility;
@@ -0,0 +1,22 @@
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS visibility TEXT NOT NULL DEFAULT 'public';
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS password_hash TEXT NOT NULL DEFAULT '';
+
+-- invite codes are stored as SHA-256 hashes, 0 leaves expiry and uses open
+CREATE TABLE IF NOT EXISTS room_invites (
+    code_hash TEXT PRIMARY KEY,
+    room_id INTEGER NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at BIGINT NOT NULL,
+    expires_at BIGINT NOT NULL DEFAULT 0,
+    max_uses INTEGER NOT NULL DEFAULT 0,
+    uses INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS room_invites_room_id_idx ON room_invites (room_id);
+
+CREATE TABLE IF NOT EXISTS room_join_requests (
+    room_id INTEGER NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    requested_at BIGINT NOT NULL,
+    PRIMARY KEY (room_id, user_id)
+);
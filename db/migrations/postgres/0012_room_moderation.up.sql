@@ -0,0 +1,18 @@
+ALTER TABLE room_members ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'member';
+
+-- creators own their rooms, including rooms they never joined
+INSERT INTO room_members (room_id, user_id, joined_at, role)
+SELECT id, created_by, created_at, 'owner' FROM rooms WHERE created_by IS NOT NULL
+ON CONFLICT (room_id, user_id) DO UPDATE SET role = 'owner';
+
+-- bans and mutes, expires_at 0 lasts until lifted
+CREATE TABLE IF NOT EXISTS room_restrictions (
+    room_id INTEGER NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at BIGINT NOT NULL,
+    expires_at BIGINT NOT NULL DEFAULT 0,
+    reason TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (room_id, user_id, kind)
+);
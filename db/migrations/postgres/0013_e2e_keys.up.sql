@@ -0,0 +1,9 @@
+-- X25519 public keys for end-to-end encrypted direct messages, one per user
+CREATE TABLE IF NOT EXISTS user_keys (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    public_key BYTEA NOT NULL,
+    published_at BIGINT NOT NULL
+);
+
+-- encrypted messages hold base64 ciphertext the server cannot read
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS encrypted BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,6 @@
+-- words the message filter acts on in a room, stored normalized
+CREATE TABLE IF NOT EXISTS room_blocked_words (
+    room_id INTEGER NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+    word TEXT NOT NULL,
+    PRIMARY KEY (room_id, word)
+);
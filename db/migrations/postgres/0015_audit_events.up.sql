@@ -0,0 +1,17 @@
+-- append-only log of administrative actions. IDs are plain columns and
+-- names are copied, so entries outlive renames and deleted rows.
+CREATE TABLE IF NOT EXISTS audit_events (
+    id SERIAL PRIMARY KEY,
+    action TEXT NOT NULL,
+    actor_id INTEGER,
+    actor_name TEXT NOT NULL DEFAULT '',
+    target_id INTEGER,
+    target_name TEXT NOT NULL DEFAULT '',
+    room_id INTEGER,
+    reason TEXT NOT NULL DEFAULT '',
+    details TEXT NOT NULL DEFAULT '',
+    created_at BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS audit_events_actor_id_idx ON audit_events (actor_id, id);
+CREATE INDEX IF NOT EXISTS audit_events_target_id_idx ON audit_events (target_id, id);
+CREATE INDEX IF NOT EXISTS audit_events_room_id_idx ON audit_events (room_id, id);
@@ -0,0 +1,3 @@
+-- the first message of the thread a reply belongs to
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS reply_to_id INTEGER REFERENCES messages(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS messages_reply_to_id_idx ON messages (reply_to_id, id);
@@ -0,0 +1,9 @@
+-- one row per user and emoji, counted when messages are read
+CREATE TABLE IF NOT EXISTS message_reactions (
+    id SERIAL PRIMARY KEY,
+    message_id INTEGER NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    emoji TEXT NOT NULL,
+    created_at BIGINT NOT NULL,
+    UNIQUE (message_id, user_id, emoji)
+);
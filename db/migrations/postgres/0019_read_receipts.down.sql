@@ -0,0 +1 @@
+ALTER TABLE room_members DROP COLUMN IF EXISTS last_read_id;
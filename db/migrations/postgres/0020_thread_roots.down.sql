@@ -0,0 +1,4 @@
+-- replies go back to naming the first message of their thread
+UPDATE messages SET reply_to_id = thread_root_id WHERE thread_root_id IS NOT NULL;
+DROP INDEX IF EXISTS messages_thread_root_id_idx;
+ALTER TABLE messages DROP COLUMN IF EXISTS thread_root_id;
@@ -0,0 +1,6 @@
+-- reply_to_id now names the message a reply answers, and thread_root_id the
+-- first message of its thread. Replies so far all answered the thread's
+-- first message.
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS thread_root_id INTEGER REFERENCES messages(id) ON DELETE SET NULL;
+UPDATE messages SET thread_root_id = reply_to_id WHERE reply_to_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS messages_thread_root_id_idx ON messages (thread_root_id, id);
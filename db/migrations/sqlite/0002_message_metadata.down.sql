@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS messages_room_id_idx;
+ALTER TABLE messages DROP COLUMN is_direct;
+ALTER TABLE messages DROP COLUMN is_system;
+ALTER TABLE messages DROP COLUMN created_at;
+ALTER TABLE messages DROP COLUMN recipient_id;
@@ -0,0 +1,7 @@
+-- SQLite cannot drop a column that is part of a foreign key, so
+-- recipient_id is a plain column to keep this migration reversible
+ALTER TABLE messages ADD COLUMN recipient_id INTEGER;
+ALTER TABLE messages ADD COLUMN created_at BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE messages ADD COLUMN is_system BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE messages ADD COLUMN is_direct BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE INDEX IF NOT EXISTS messages_room_id_idx ON messages (room_id, id);
@@ -0,0 +1,3 @@
+ALTER TABLE rooms DROP COLUMN metadata;
+ALTER TABLE rooms DROP COLUMN created_by;
+ALTER TABLE rooms DROP COLUMN created_at;
@@ -0,0 +1,4 @@
+-- created_by is a plain column for the same reason as messages.recipient_id
+ALTER TABLE rooms ADD COLUMN created_at BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE rooms ADD COLUMN created_by INTEGER;
+ALTER TABLE rooms ADD COLUMN metadata TEXT NOT NULL DEFAULT '{}';
@@ -0,0 +1,3 @@
+-- NULL until a direct message has reached the recipient
+ALTER TABLE messages ADD COLUMN delivered_at BIGINT;
+CREATE INDEX IF NOT EXISTS messages_undelivered_idx ON messages (recipient_id, id) WHERE delivered_at IS NULL;
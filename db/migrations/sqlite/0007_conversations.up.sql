@@ -0,0 +1,30 @@
+-- a conversation between two users, user1_id is always the lower ID
+CREATE TABLE IF NOT EXISTS conversations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user1_id INTEGER NOT NULL REFERENCES users(id),
+    user2_id INTEGER NOT NULL REFERENCES users(id),
+    created_at BIGINT NOT NULL,
+    UNIQUE (user1_id, user2_id)
+);
+
+-- conversation_id is a plain column so the migration stays reversible
+ALTER TABLE messages ADD COLUMN conversation_id INTEGER;
+-- NULL until the recipient has read a direct message
+ALTER TABLE messages ADD COLUMN read_at BIGINT;
+CREATE INDEX IF NOT EXISTS messages_conversation_id_idx ON messages (conversation_id, id);
+
+-- group direct messages sent so far into conversations, treating them as read
+INSERT OR IGNORE INTO conversations (user1_id, user2_id, created_at)
+SELECT MIN(user_id, recipient_id), MAX(user_id, recipient_id), MIN(created_at)
+FROM messages
+WHERE is_direct AND user_id IS NOT NULL AND recipient_id IS NOT NULL
+GROUP BY MIN(user_id, recipient_id), MAX(user_id, recipient_id);
+
+UPDATE messages
+SET conversation_id = (
+        SELECT c.id FROM conversations c
+        WHERE c.user1_id = MIN(messages.user_id, messages.recipient_id)
+            AND c.user2_id = MAX(messages.user_id, messages.recipient_id)
+    ),
+    read_at = created_at
+WHERE is_direct;
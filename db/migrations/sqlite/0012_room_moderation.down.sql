@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS room_restrictions;
+ALTER TABLE room_members DROP COLUMN role;
@@ -0,0 +1,14 @@
+-- NULL until a message is edited or deleted. Deleted messages keep their
+-- row, with the text removed, so history can show where they were.
+ALTER TABLE messages ADD COLUMN edited_at BIGINT;
+ALTER TABLE messages ADD COLUMN deleted_at BIGINT;
+
+-- earlier versions of edited messages, removed along with the message text
+CREATE TABLE IF NOT EXISTS message_edits (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    message_id INTEGER NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    message TEXT NOT NULL,
+    edited_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    edited_at BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS message_edits_message_id_idx ON message_edits (message_id, id);
@@ -0,0 +1,4 @@
+-- the first message of the thread a reply belongs to. reply_to_id is a
+-- plain column so the migration stays reversible.
+ALTER TABLE messages ADD COLUMN reply_to_id INTEGER;
+CREATE INDEX IF NOT EXISTS messages_reply_to_id_idx ON messages (reply_to_id, id);
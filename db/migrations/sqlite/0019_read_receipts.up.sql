@@ -0,0 +1,9 @@
+-- the newest room message the member has read, 0 before they read any
+ALTER TABLE room_members ADD COLUMN last_read_id INTEGER NOT NULL DEFAULT 0;
+
+-- members have read everything sent before read positions were tracked
+UPDATE room_members
+SET last_read_id = COALESCE(
+    (SELECT MAX(m.id) FROM messages m WHERE m.room_id = room_members.room_id AND NOT m.is_direct),
+    0
+);
@@ -0,0 +1,43 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+var postgresDialect = &dialect{
+	name:   "postgres",
+	rebind: func(query string) string { return query },
+	isUniqueViolation: func(err error) bool {
+		var pqErr *pq.Error
+		return errors.As(err, &pqErr) && pqErr.Code == "23505"
+	},
+	searchBase: `SELECT m.id, u.username, m.message, m.created_at, m.room_id, r.name,
+			ts_rank(to_tsvector('english', m.message), q) AS rank
+		FROM messages m
+		JOIN rooms r ON r.id = m.room_id
+		JOIN users u ON u.id = m.user_id,
+			websearch_to_tsquery('english', $1) q
+		WHERE NOT m.is_direct AND NOT m.is_system
+			AND to_tsvector('english', m.message) @@ q`,
+	searchText: func(text string) string { return text },
+	// the advisory lock is released when the transaction ends
+	lockMigrations: []string{"BEGIN", fmt.Sprintf("SELECT pg_advisory_xact_lock(%d)", migrationLockKey)},
+}
+
+func openPostgres(connStr string) (*sqlStore, error) {
+	database, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %v", err)
+	}
+
+	if err := database.Ping(); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("failed to ping database: %v", err)
+	}
+
+	return &sqlStore{db: database, dialect: postgresDialect}, nil
+}
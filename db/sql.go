@@ -0,0 +1,1545 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// dialect describes what differs between the SQL databases sqlStore runs on.
+// Queries are written with Postgres-style $N placeholders.
+type dialect struct {
+	// name also selects the migrations/<name> directory
+	name string
+	// rebind rewrites $N placeholders into the driver's syntax
+	rebind func(query string) string
+	// isUniqueViolation reports whether err was caused by a UNIQUE constraint
+	isUniqueViolation func(err error) bool
+	// searchBase selects id, username, message, created_at, room_id,
+	// room name and rank of room messages matching the search text in $1
+	searchBase string
+	// searchText turns user input into the dialect's search syntax
+	searchText func(text string) string
+	// lockMigrations begins the transaction migrations run in and takes a
+	// lock that keeps other connections from migrating until it ends
+	lockMigrations []string
+}
+
+// sqlStore implements Store on top of database/sql
+type sqlStore struct {
+	db      *sql.DB
+	dialect *dialect
+}
+
+func (s *sqlStore) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return s.db.ExecContext(ctx, s.dialect.rebind(query), args...)
+}
+
+func (s *sqlStore) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return s.db.QueryContext(ctx, s.dialect.rebind(query), args...)
+}
+
+func (s *sqlStore) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return s.db.QueryRowContext(ctx, s.dialect.rebind(query), args...)
+}
+
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *sqlStore) Migrate() ([]Migration, error) {
+	return migrate(s.db, s.dialect)
+}
+
+func (s *sqlStore) MigrateDown(steps int) ([]Migration, error) {
+	return migrateDown(s.db, s.dialect, steps)
+}
+
+func (s *sqlStore) MigrationStatus() ([]MigrationStatus, error) {
+	return migrationStatus(s.db, s.dialect)
+}
+
+// prepareSchema applies pending migrations, or with autoMigrate off makes
+// sure none are pending
+func (s *sqlStore) prepareSchema(autoMigrate bool) error {
+	if autoMigrate {
+		_, err := s.Migrate()
+		return err
+	}
+
+	statuses, err := s.MigrationStatus()
+	if err != nil {
+		return err
+	}
+	for _, st := range statuses {
+		if !st.Applied {
+			return fmt.Errorf("migration %04d_%s is pending, run \"zenith-server migrate up\"", st.Version, st.Name)
+		}
+	}
+	return nil
+}
+
+// nullID maps the zero ID to NULL so optional references stay empty
+func nullID(id int32) sql.NullInt32 {
+	return sql.NullInt32{Int32: id, Valid: id != 0}
+}
+
+// nullTime maps a zero Unix time to NULL
+func nullTime(t int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: t, Valid: t != 0}
+}
+
+func (s *sqlStore) CreateUser(ctx context.Context, username, passwordHash string) (*User, error) {
+	user := &User{Username: username, PasswordHash: passwordHash}
+	err := s.queryRow(ctx,
+		"INSERT INTO users (username, password) VALUES ($1, $2) RETURNING id",
+		username, passwordHash,
+	).Scan(&user.ID)
+	if err != nil {
+		if s.dialect.isUniqueViolation(err) {
+			return nil, ErrConflict
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *sqlStore) getUser(ctx context.Context, where string, arg interface{}) (*User, error) {
+	var user User
+	err := s.queryRow(ctx, "SELECT id, username, password, is_admin FROM users WHERE "+where, arg).
+		Scan(&user.ID, &user.Username, &user.PasswordHash, &user.IsAdmin)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *sqlStore) GetUser(ctx context.Context, id int32) (*User, error) {
+	return s.getUser(ctx, "id = $1", id)
+}
+
+func (s *sqlStore) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	return s.getUser(ctx, "username = $1", username)
+}
+
+func (s *sqlStore) UpdateUsername(ctx context.Context, id int32, username string) error {
+	res, err := s.exec(ctx, "UPDATE users SET username = $1 WHERE id = $2", username, id)
+	if err != nil {
+		if s.dialect.isUniqueViolation(err) {
+			return ErrConflict
+		}
+		return err
+	}
+	return expectRow(res)
+}
+
+func (s *sqlStore) UpdatePassword(ctx context.Context, id int32, passwordHash string) error {
+	res, err := s.exec(ctx, "UPDATE users SET password = $1 WHERE id = $2", passwordHash, id)
+	if err != nil {
+		return err
+	}
+	return expectRow(res)
+}
+
+func (s *sqlStore) SetAdmin(ctx context.Context, id int32, admin bool) error {
+	res, err := s.exec(ctx, "UPDATE users SET is_admin = $1 WHERE id = $2", admin, id)
+	if err != nil {
+		return err
+	}
+	return expectRow(res)
+}
+
+func (s *sqlStore) DeleteUser(ctx context.Context, id int32, placeholder string, deleteMessages bool) (map[int32]int32, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		s.dialect.rebind("UPDATE users SET username = $1, password = '', is_admin = FALSE WHERE id = $2"),
+		placeholder, id,
+	)
+	if err != nil {
+		if s.dialect.isUniqueViolation(err) {
+			return nil, ErrConflict
+		}
+		return nil, err
+	}
+	if err := expectRow(res); err != nil {
+		return nil, err
+	}
+
+	owners, err := s.handOverRooms(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	tables := []string{"sessions", "password_resets", "user_keys", "room_members", "room_restrictions", "room_join_requests", "message_reactions"}
+	if deleteMessages {
+		tables = append(tables, "messages")
+		// threads started by the deleted messages come apart, and replies
+		// to them no longer answer anything
+		for _, query := range []string{
+			"UPDATE messages SET thread_root_id = NULL, reply_to_id = NULL WHERE thread_root_id IN (SELECT id FROM messages WHERE user_id = $1)",
+			"UPDATE messages SET reply_to_id = NULL WHERE reply_to_id IN (SELECT id FROM messages WHERE user_id = $1)",
+		} {
+			if _, err := tx.ExecContext(ctx, s.dialect.rebind(query), id); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, table := range tables {
+		if _, err := tx.ExecContext(ctx, s.dialect.rebind("DELETE FROM "+table+" WHERE user_id = $1"), id); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return owners, nil
+}
+
+// handOverRooms passes the rooms a user created to their most senior other
+// member: the earliest to join among those of the highest role
+func (s *sqlStore) handOverRooms(ctx context.Context, tx *sql.Tx, userID int32) (map[int32]int32, error) {
+	rows, err := tx.QueryContext(ctx, s.dialect.rebind("SELECT id FROM rooms WHERE created_by = $1"), userID)
+	if err != nil {
+		return nil, err
+	}
+	var roomIDs []int32
+	for rows.Next() {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		roomIDs = append(roomIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	owners := make(map[int32]int32, len(roomIDs))
+	for _, roomID := range roomIDs {
+		var owner int32
+		err := tx.QueryRowContext(ctx, s.dialect.rebind(
+			"SELECT user_id FROM room_members WHERE room_id = $1 AND user_id <> $2 "+
+				"ORDER BY CASE role WHEN $3 THEN 0 WHEN $4 THEN 1 ELSE 2 END, joined_at, user_id LIMIT 1"),
+			roomID, userID, RoleOwner, RoleModerator,
+		).Scan(&owner)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+
+		if _, err := tx.ExecContext(ctx, s.dialect.rebind("UPDATE rooms SET created_by = $1 WHERE id = $2"), nullID(owner), roomID); err != nil {
+			return nil, err
+		}
+		if owner != 0 {
+			if _, err := tx.ExecContext(ctx, s.dialect.rebind(
+				"UPDATE room_members SET role = $1 WHERE room_id = $2 AND user_id = $3"), RoleOwner, roomID, owner,
+			); err != nil {
+				return nil, err
+			}
+		}
+		owners[roomID] = owner
+	}
+	return owners, nil
+}
+
+// expectRow turns an update that matched nothing into ErrNotFound
+func expectRow(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *sqlStore) CreateSession(ctx context.Context, session *Session) error {
+	_, err := s.exec(ctx,
+		"DELETE FROM sessions WHERE user_id = $1 AND expires_at <= $2",
+		session.UserID, session.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.exec(ctx,
+		"INSERT INTO sessions (id, user_id, created_at, expires_at) VALUES ($1, $2, $3, $4)",
+		session.ID, session.UserID, session.CreatedAt, session.ExpiresAt,
+	)
+	if err != nil && s.dialect.isUniqueViolation(err) {
+		return ErrConflict
+	}
+	return err
+}
+
+func (s *sqlStore) GetSession(ctx context.Context, id string) (*Session, error) {
+	var session Session
+	err := s.queryRow(ctx,
+		"SELECT id, user_id, created_at, expires_at FROM sessions WHERE id = $1",
+		id,
+	).Scan(&session.ID, &session.UserID, &session.CreatedAt, &session.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (s *sqlStore) DeleteSessions(ctx context.Context, userID int32, keepID string) (int, error) {
+	res, err := s.exec(ctx, "DELETE FROM sessions WHERE user_id = $1 AND id <> $2", userID, keepID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (s *sqlStore) GetLoginFailure(ctx context.Context, subject string) (*LoginFailure, error) {
+	f := LoginFailure{Subject: subject}
+	err := s.queryRow(ctx,
+		"SELECT failures, last_failure_at, locked_until FROM login_failures WHERE subject = $1",
+		subject,
+	).Scan(&f.Failures, &f.LastFailureAt, &f.LockedUntil)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (s *sqlStore) SaveLoginFailure(ctx context.Context, f *LoginFailure) error {
+	_, err := s.exec(ctx,
+		`INSERT INTO login_failures (subject, failures, last_failure_at, locked_until) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (subject) DO UPDATE SET
+			failures = excluded.failures,
+			last_failure_at = excluded.last_failure_at,
+			locked_until = excluded.locked_until`,
+		f.Subject, f.Failures, f.LastFailureAt, f.LockedUntil,
+	)
+	return err
+}
+
+func (s *sqlStore) ClearLoginFailure(ctx context.Context, subject string) error {
+	_, err := s.exec(ctx, "DELETE FROM login_failures WHERE subject = $1", subject)
+	return err
+}
+
+func (s *sqlStore) SavePublicKey(ctx context.Context, key *PublicKey) error {
+	_, err := s.exec(ctx,
+		`INSERT INTO user_keys (user_id, public_key, published_at) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET
+			public_key = excluded.public_key,
+			published_at = excluded.published_at`,
+		key.UserID, key.Key, key.PublishedAt,
+	)
+	return err
+}
+
+func (s *sqlStore) GetPublicKey(ctx context.Context, userID int32) (*PublicKey, error) {
+	key := PublicKey{UserID: userID}
+	err := s.queryRow(ctx, "SELECT public_key, published_at FROM user_keys WHERE user_id = $1", userID).
+		Scan(&key.Key, &key.PublishedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (s *sqlStore) CreatePasswordReset(ctx context.Context, reset *PasswordReset) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, s.dialect.rebind("DELETE FROM password_resets WHERE user_id = $1"), reset.UserID); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		s.dialect.rebind("INSERT INTO password_resets (code_hash, user_id, created_at, expires_at) VALUES ($1, $2, $3, $4)"),
+		reset.CodeHash, reset.UserID, reset.CreatedAt, reset.ExpiresAt,
+	)
+	if err != nil {
+		if s.dialect.isUniqueViolation(err) {
+			return ErrConflict
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) TakePasswordReset(ctx context.Context, codeHash string) (*PasswordReset, error) {
+	reset := PasswordReset{CodeHash: codeHash}
+	err := s.queryRow(ctx,
+		"DELETE FROM password_resets WHERE code_hash = $1 RETURNING user_id, created_at, expires_at",
+		codeHash,
+	).Scan(&reset.UserID, &reset.CreatedAt, &reset.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &reset, nil
+}
+
+func (s *sqlStore) CreateRoom(ctx context.Context, room *Room) error {
+	metadata := room.Metadata
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	err = s.queryRow(ctx,
+		"INSERT INTO rooms (name, created_at, created_by, metadata, visibility, password_hash) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
+		room.Name, room.CreatedAt, nullID(room.CreatedBy), string(metadataJSON), room.Visibility, room.PasswordHash,
+	).Scan(&room.ID)
+	if err != nil && s.dialect.isUniqueViolation(err) {
+		return ErrConflict
+	}
+	return err
+}
+
+func (s *sqlStore) ListRooms(ctx context.Context) ([]*Room, error) {
+	rows, err := s.query(ctx, "SELECT id, name, created_at, created_by, metadata, visibility, password_hash FROM rooms ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rooms []*Room
+	for rows.Next() {
+		var (
+			room      Room
+			createdBy sql.NullInt32
+			metadata  []byte
+		)
+		if err := rows.Scan(&room.ID, &room.Name, &room.CreatedAt, &createdBy, &metadata, &room.Visibility, &room.PasswordHash); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(metadata, &room.Metadata); err != nil {
+			return nil, fmt.Errorf("invalid metadata for room %d: %v", room.ID, err)
+		}
+		room.CreatedBy = createdBy.Int32
+		rooms = append(rooms, &room)
+	}
+	return rooms, rows.Err()
+}
+
+func (s *sqlStore) UpdateRoomAccess(ctx context.Context, roomID int32, visibility, passwordHash string) error {
+	res, err := s.exec(ctx,
+		"UPDATE rooms SET visibility = $1, password_hash = $2 WHERE id = $3",
+		visibility, passwordHash, roomID,
+	)
+	if err != nil {
+		return err
+	}
+	return expectRow(res)
+}
+
+func (s *sqlStore) AddMembership(ctx context.Context, roomID, userID int32) error {
+	_, err := s.exec(ctx,
+		"INSERT INTO room_members (room_id, user_id, joined_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+		roomID, userID, time.Now().Unix(),
+	)
+	return err
+}
+
+func (s *sqlStore) RemoveMembership(ctx context.Context, roomID, userID int32) error {
+	_, err := s.exec(ctx, "DELETE FROM room_members WHERE room_id = $1 AND user_id = $2", roomID, userID)
+	return err
+}
+
+func (s *sqlStore) SetMemberRole(ctx context.Context, roomID, userID int32, role string) error {
+	res, err := s.exec(ctx,
+		"UPDATE room_members SET role = $1 WHERE room_id = $2 AND user_id = $3",
+		role, roomID, userID,
+	)
+	if err != nil {
+		return err
+	}
+	return expectRow(res)
+}
+
+func (s *sqlStore) GetMembership(ctx context.Context, roomID, userID int32) (*Membership, error) {
+	m := Membership{RoomID: roomID, UserID: userID}
+	err := s.queryRow(ctx,
+		"SELECT joined_at, role, last_read_id FROM room_members WHERE room_id = $1 AND user_id = $2",
+		roomID, userID,
+	).Scan(&m.JoinedAt, &m.Role, &m.LastReadID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (s *sqlStore) ListMemberships(ctx context.Context, userID int32) ([]*Membership, error) {
+	rows, err := s.query(ctx,
+		"SELECT room_id, user_id, joined_at, role, last_read_id FROM room_members WHERE user_id = $1 ORDER BY joined_at, room_id",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var memberships []*Membership
+	for rows.Next() {
+		var m Membership
+		if err := rows.Scan(&m.RoomID, &m.UserID, &m.JoinedAt, &m.Role, &m.LastReadID); err != nil {
+			return nil, err
+		}
+		memberships = append(memberships, &m)
+	}
+	return memberships, rows.Err()
+}
+
+func (s *sqlStore) MarkRoomRead(ctx context.Context, roomID, userID, messageID int32) (bool, error) {
+	res, err := s.exec(ctx,
+		"UPDATE room_members SET last_read_id = $1 WHERE room_id = $2 AND user_id = $3 AND last_read_id < $1",
+		messageID, roomID, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n > 0 {
+		return n > 0, err
+	}
+
+	// nothing moved, either because the member read further already or
+	// because there is no such member
+	if _, err := s.GetMembership(ctx, roomID, userID); err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+func (s *sqlStore) RoomUnreadCounts(ctx context.Context, userID int32) (map[int32]int32, error) {
+	rows, err := s.query(ctx,
+		`SELECT rm.room_id, COUNT(*)
+		FROM room_members rm
+		JOIN messages m ON m.room_id = rm.room_id AND m.id > rm.last_read_id
+		WHERE rm.user_id = $1 AND NOT m.is_direct AND NOT m.is_system
+			AND m.user_id <> rm.user_id AND m.deleted_at IS NULL
+		GROUP BY rm.room_id`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int32]int32)
+	for rows.Next() {
+		var roomID, count int32
+		if err := rows.Scan(&roomID, &count); err != nil {
+			return nil, err
+		}
+		counts[roomID] = count
+	}
+	return counts, rows.Err()
+}
+
+func (s *sqlStore) RoomUnreadCount(ctx context.Context, roomID, userID int32) (int32, error) {
+	var count int32
+	err := s.queryRow(ctx,
+		`SELECT COUNT(*)
+		FROM room_members rm
+		JOIN messages m ON m.room_id = rm.room_id AND m.id > rm.last_read_id
+		WHERE rm.room_id = $1 AND rm.user_id = $2 AND NOT m.is_direct AND NOT m.is_system
+			AND m.user_id <> rm.user_id AND m.deleted_at IS NULL`,
+		roomID, userID,
+	).Scan(&count)
+	return count, err
+}
+
+func (s *sqlStore) RoomReaders(ctx context.Context, roomID, messageID int32) ([]*ReadReceipt, error) {
+	rows, err := s.query(ctx,
+		`SELECT rm.user_id, u.username, rm.last_read_id
+		FROM room_members rm
+		JOIN users u ON u.id = rm.user_id
+		WHERE rm.room_id = $1 AND rm.last_read_id >= $2
+		ORDER BY u.username`,
+		roomID, messageID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var readers []*ReadReceipt
+	for rows.Next() {
+		var r ReadReceipt
+		if err := rows.Scan(&r.UserID, &r.Username, &r.LastReadID); err != nil {
+			return nil, err
+		}
+		readers = append(readers, &r)
+	}
+	return readers, rows.Err()
+}
+
+func (s *sqlStore) SaveRestriction(ctx context.Context, r *Restriction) error {
+	_, err := s.exec(ctx,
+		`INSERT INTO room_restrictions (room_id, user_id, kind, created_by, created_at, expires_at, reason)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (room_id, user_id, kind) DO UPDATE SET
+			created_by = excluded.created_by,
+			created_at = excluded.created_at,
+			expires_at = excluded.expires_at,
+			reason = excluded.reason`,
+		r.RoomID, r.UserID, r.Kind, nullID(r.CreatedBy), r.CreatedAt, r.ExpiresAt, r.Reason,
+	)
+	return err
+}
+
+func (s *sqlStore) GetRestriction(ctx context.Context, roomID, userID int32, kind string) (*Restriction, error) {
+	var (
+		r         = Restriction{RoomID: roomID, UserID: userID, Kind: kind}
+		createdBy sql.NullInt32
+	)
+	err := s.queryRow(ctx,
+		`SELECT created_by, created_at, expires_at, reason FROM room_restrictions
+		WHERE room_id = $1 AND user_id = $2 AND kind = $3`,
+		roomID, userID, kind,
+	).Scan(&createdBy, &r.CreatedAt, &r.ExpiresAt, &r.Reason)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.CreatedBy = createdBy.Int32
+	return &r, nil
+}
+
+func (s *sqlStore) DeleteRestriction(ctx context.Context, roomID, userID int32, kind string) error {
+	res, err := s.exec(ctx,
+		"DELETE FROM room_restrictions WHERE room_id = $1 AND user_id = $2 AND kind = $3",
+		roomID, userID, kind,
+	)
+	if err != nil {
+		return err
+	}
+	return expectRow(res)
+}
+
+func (s *sqlStore) UserRestrictions(ctx context.Context, userID int32) ([]*Restriction, error) {
+	rows, err := s.query(ctx,
+		`SELECT room_id, kind, created_by, created_at, expires_at, reason FROM room_restrictions
+		WHERE user_id = $1 ORDER BY created_at, room_id, kind`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var restrictions []*Restriction
+	for rows.Next() {
+		var (
+			r         = Restriction{UserID: userID}
+			createdBy sql.NullInt32
+		)
+		if err := rows.Scan(&r.RoomID, &r.Kind, &createdBy, &r.CreatedAt, &r.ExpiresAt, &r.Reason); err != nil {
+			return nil, err
+		}
+		r.CreatedBy = createdBy.Int32
+		restrictions = append(restrictions, &r)
+	}
+	return restrictions, rows.Err()
+}
+
+func (s *sqlStore) SetBlockedWords(ctx context.Context, roomID int32, words []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, s.dialect.rebind("DELETE FROM room_blocked_words WHERE room_id = $1"), roomID); err != nil {
+		return err
+	}
+	for _, word := range words {
+		_, err := tx.ExecContext(ctx,
+			s.dialect.rebind("INSERT INTO room_blocked_words (room_id, word) VALUES ($1, $2) ON CONFLICT DO NOTHING"),
+			roomID, word,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) ListBlockedWords(ctx context.Context, roomID int32) ([]string, error) {
+	rows, err := s.query(ctx, "SELECT word FROM room_blocked_words WHERE room_id = $1 ORDER BY word", roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var words []string
+	for rows.Next() {
+		var word string
+		if err := rows.Scan(&word); err != nil {
+			return nil, err
+		}
+		words = append(words, word)
+	}
+	return words, rows.Err()
+}
+
+func (s *sqlStore) CreateRoomInvite(ctx context.Context, invite *RoomInvite) error {
+	_, err := s.exec(ctx,
+		`INSERT INTO room_invites (code_hash, room_id, created_by, created_at, expires_at, max_uses, uses)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		invite.CodeHash, invite.RoomID, nullID(invite.CreatedBy), invite.CreatedAt, invite.ExpiresAt, invite.MaxUses, invite.Uses,
+	)
+	if err != nil && s.dialect.isUniqueViolation(err) {
+		return ErrConflict
+	}
+	return err
+}
+
+func (s *sqlStore) UseRoomInvite(ctx context.Context, codeHash string, now int64) (*RoomInvite, error) {
+	var (
+		invite    = RoomInvite{CodeHash: codeHash}
+		createdBy sql.NullInt32
+	)
+	// checking and counting in one statement keeps concurrent redemptions
+	// from going over max_uses
+	err := s.queryRow(ctx,
+		`UPDATE room_invites SET uses = uses + 1
+		WHERE code_hash = $1 AND (expires_at = 0 OR expires_at > $2) AND (max_uses = 0 OR uses < max_uses)
+		RETURNING room_id, created_by, created_at, expires_at, max_uses, uses`,
+		codeHash, now,
+	).Scan(&invite.RoomID, &createdBy, &invite.CreatedAt, &invite.ExpiresAt, &invite.MaxUses, &invite.Uses)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	invite.CreatedBy = createdBy.Int32
+	return &invite, nil
+}
+
+func (s *sqlStore) CreateJoinRequest(ctx context.Context, roomID, userID int32, requestedAt int64) error {
+	_, err := s.exec(ctx,
+		"INSERT INTO room_join_requests (room_id, user_id, requested_at) VALUES ($1, $2, $3)",
+		roomID, userID, requestedAt,
+	)
+	if err != nil && s.dialect.isUniqueViolation(err) {
+		return ErrConflict
+	}
+	return err
+}
+
+func (s *sqlStore) ListJoinRequests(ctx context.Context, roomID int32) ([]*JoinRequest, error) {
+	rows, err := s.query(ctx,
+		`SELECT r.user_id, u.username, r.requested_at FROM room_join_requests r
+		JOIN users u ON u.id = r.user_id
+		WHERE r.room_id = $1 ORDER BY r.requested_at, r.user_id`,
+		roomID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []*JoinRequest
+	for rows.Next() {
+		r := JoinRequest{RoomID: roomID}
+		if err := rows.Scan(&r.UserID, &r.Username, &r.RequestedAt); err != nil {
+			return nil, err
+		}
+		requests = append(requests, &r)
+	}
+	return requests, rows.Err()
+}
+
+func (s *sqlStore) DeleteJoinRequest(ctx context.Context, roomID, userID int32) error {
+	res, err := s.exec(ctx, "DELETE FROM room_join_requests WHERE room_id = $1 AND user_id = $2", roomID, userID)
+	if err != nil {
+		return err
+	}
+	return expectRow(res)
+}
+
+func (s *sqlStore) UserJoinRequests(ctx context.Context, userID int32) ([]*JoinRequest, error) {
+	rows, err := s.query(ctx,
+		`SELECT r.room_id, u.username, r.requested_at FROM room_join_requests r
+		JOIN users u ON u.id = r.user_id
+		WHERE r.user_id = $1 ORDER BY r.requested_at, r.room_id`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []*JoinRequest
+	for rows.Next() {
+		r := JoinRequest{UserID: userID}
+		if err := rows.Scan(&r.RoomID, &r.Username, &r.RequestedAt); err != nil {
+			return nil, err
+		}
+		requests = append(requests, &r)
+	}
+	return requests, rows.Err()
+}
+
+func (s *sqlStore) SaveMessage(ctx context.Context, msg *Message) error {
+	return s.queryRow(ctx,
+		`INSERT INTO messages (user_id, room_id, recipient_id, conversation_id, message, created_at, is_system, is_direct, delivered_at, encrypted, reply_to_id, thread_root_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id`,
+		nullID(msg.UserID), nullID(msg.RoomID), nullID(msg.RecipientID), nullID(msg.ConversationID),
+		msg.Content, msg.Timestamp, msg.IsSystem, msg.IsDirect, nullTime(msg.DeliveredAt), msg.Encrypted, nullID(msg.ReplyToID),
+		nullID(msg.ThreadRootID),
+	).Scan(&msg.ID)
+}
+
+func (s *sqlStore) GetMessage(ctx context.Context, id int32) (*Message, error) {
+	var (
+		msg                                          Message
+		userID, roomID, recipientID, convID, replyTo sql.NullInt32
+		threadRoot                                   sql.NullInt32
+		deliveredAt, editedAt, deletedAt             sql.NullInt64
+	)
+	err := s.queryRow(ctx,
+		`SELECT m.id, m.user_id, COALESCE(u.username, 'SYSTEM'), m.message, m.room_id, m.recipient_id,
+			m.conversation_id, m.created_at, m.is_system, m.is_direct, m.delivered_at, m.encrypted,
+			m.edited_at, m.deleted_at, m.reply_to_id, m.thread_root_id,
+			(SELECT COUNT(*) FROM messages r WHERE r.thread_root_id = m.id)
+		FROM messages m
+		LEFT JOIN users u ON u.id = m.user_id
+		WHERE m.id = $1`,
+		id,
+	).Scan(&msg.ID, &userID, &msg.Username, &msg.Content, &roomID, &recipientID,
+		&convID, &msg.Timestamp, &msg.IsSystem, &msg.IsDirect, &deliveredAt, &msg.Encrypted,
+		&editedAt, &deletedAt, &replyTo, &threadRoot, &msg.ReplyCount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	msg.UserID = userID.Int32
+	msg.RoomID = roomID.Int32
+	msg.RecipientID = recipientID.Int32
+	msg.ConversationID = convID.Int32
+	msg.DeliveredAt = deliveredAt.Int64
+	msg.EditedAt = editedAt.Int64
+	msg.DeletedAt = deletedAt.Int64
+	msg.ReplyToID = replyTo.Int32
+	msg.ThreadRootID = threadRoot.Int32
+	return &msg, nil
+}
+
+func (s *sqlStore) EditMessage(ctx context.Context, id int32, content string, editedBy int32, editedAt int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// the old text is copied before it is replaced, in one transaction
+	res, err := tx.ExecContext(ctx, s.dialect.rebind(
+		`INSERT INTO message_edits (message_id, message, edited_by, edited_at)
+		SELECT id, message, $1, $2 FROM messages WHERE id = $3 AND deleted_at IS NULL`),
+		nullID(editedBy), editedAt, id,
+	)
+	if err != nil {
+		return err
+	}
+	if err := expectRow(res); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		s.dialect.rebind("UPDATE messages SET message = $1, edited_at = $2 WHERE id = $3"),
+		content, editedAt, id,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) DeleteMessage(ctx context.Context, id int32, deletedAt int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		s.dialect.rebind("UPDATE messages SET message = '', deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL"),
+		deletedAt, id,
+	)
+	if err != nil {
+		return err
+	}
+	if err := expectRow(res); err != nil {
+		return err
+	}
+	for _, table := range []string{"message_edits", "message_reactions"} {
+		if _, err := tx.ExecContext(ctx, s.dialect.rebind("DELETE FROM "+table+" WHERE message_id = $1"), id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) MessageEdits(ctx context.Context, messageID int32) ([]*MessageEdit, error) {
+	rows, err := s.query(ctx,
+		`SELECT e.message, e.edited_by, COALESCE(u.username, ''), e.edited_at
+		FROM message_edits e
+		LEFT JOIN users u ON u.id = e.edited_by
+		WHERE e.message_id = $1
+		ORDER BY e.id`,
+		messageID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var edits []*MessageEdit
+	for rows.Next() {
+		var (
+			edit     = MessageEdit{MessageID: messageID}
+			editedBy sql.NullInt32
+		)
+		if err := rows.Scan(&edit.Content, &editedBy, &edit.EditedByName, &edit.EditedAt); err != nil {
+			return nil, err
+		}
+		edit.EditedBy = editedBy.Int32
+		edits = append(edits, &edit)
+	}
+	return edits, rows.Err()
+}
+
+func (s *sqlStore) ThreadReplies(ctx context.Context, messageID, afterID int32, limit int) ([]*Message, bool, error) {
+	// fetch one extra row to find out whether another page exists
+	rows, err := s.query(ctx,
+		`SELECT m.id, m.user_id, COALESCE(u.username, 'SYSTEM'), m.message, m.room_id, m.created_at,
+			m.edited_at, m.deleted_at, m.reply_to_id
+		FROM messages m
+		LEFT JOIN users u ON u.id = m.user_id
+		WHERE m.thread_root_id = $1 AND m.id > $2
+		ORDER BY m.id ASC LIMIT $3`,
+		messageID, afterID, limit+1,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	var messages []*Message
+	for rows.Next() {
+		var (
+			msg                     = Message{ThreadRootID: messageID}
+			userID, roomID, replyTo sql.NullInt32
+			editedAt, deletedAt     sql.NullInt64
+		)
+		if err := rows.Scan(&msg.ID, &userID, &msg.Username, &msg.Content, &roomID, &msg.Timestamp,
+			&editedAt, &deletedAt, &replyTo); err != nil {
+			return nil, false, err
+		}
+		msg.UserID = userID.Int32
+		msg.ReplyToID = replyTo.Int32
+		msg.RoomID = roomID.Int32
+		msg.EditedAt = editedAt.Int64
+		msg.DeletedAt = deletedAt.Int64
+		messages = append(messages, &msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	messages, hasMore := trimPage(messages, limit)
+	return messages, hasMore, nil
+}
+
+func (s *sqlStore) AddReaction(ctx context.Context, messageID, userID int32, emoji string, createdAt int64) error {
+	_, err := s.exec(ctx,
+		"INSERT INTO message_reactions (message_id, user_id, emoji, created_at) VALUES ($1, $2, $3, $4)",
+		messageID, userID, emoji, createdAt,
+	)
+	if err != nil && s.dialect.isUniqueViolation(err) {
+		return ErrConflict
+	}
+	return err
+}
+
+func (s *sqlStore) RemoveReaction(ctx context.Context, messageID, userID int32, emoji string) error {
+	res, err := s.exec(ctx,
+		"DELETE FROM message_reactions WHERE message_id = $1 AND user_id = $2 AND emoji = $3",
+		messageID, userID, emoji,
+	)
+	if err != nil {
+		return err
+	}
+	return expectRow(res)
+}
+
+func (s *sqlStore) MessageReactions(ctx context.Context, messageIDs []int32) (map[int32][]*Reaction, error) {
+	reactions := make(map[int32][]*Reaction)
+	if len(messageIDs) == 0 {
+		return reactions, nil
+	}
+
+	args := make([]interface{}, len(messageIDs))
+	placeholders := make([]string, len(messageIDs))
+	for i, id := range messageIDs {
+		args[i] = id
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	rows, err := s.query(ctx,
+		`SELECT message_id, emoji, COUNT(*)
+		FROM message_reactions
+		WHERE message_id IN (`+strings.Join(placeholders, ", ")+`)
+		GROUP BY message_id, emoji
+		ORDER BY message_id, MIN(id)`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			messageID int32
+			reaction  Reaction
+		)
+		if err := rows.Scan(&messageID, &reaction.Emoji, &reaction.Count); err != nil {
+			return nil, err
+		}
+		reactions[messageID] = append(reactions[messageID], &reaction)
+	}
+	return reactions, rows.Err()
+}
+
+func (s *sqlStore) UserReactions(ctx context.Context, userID int32) ([]*UserReaction, error) {
+	rows, err := s.query(ctx,
+		`SELECT r.message_id, m.room_id, r.emoji, r.created_at FROM message_reactions r
+		JOIN messages m ON m.id = r.message_id
+		WHERE r.user_id = $1 ORDER BY r.created_at, r.message_id, r.emoji`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reactions []*UserReaction
+	for rows.Next() {
+		var (
+			r      UserReaction
+			roomID sql.NullInt32
+		)
+		if err := rows.Scan(&r.MessageID, &roomID, &r.Emoji, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		r.RoomID = roomID.Int32
+		reactions = append(reactions, &r)
+	}
+	return reactions, rows.Err()
+}
+
+func (s *sqlStore) UserMessages(ctx context.Context, userID, afterID int32, limit int) ([]*Message, bool, error) {
+	// fetch one extra row to find out whether another page exists
+	rows, err := s.query(ctx,
+		`SELECT m.id, m.room_id, u.username, m.message, m.created_at
+		FROM messages m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.user_id = $1 AND NOT m.is_direct AND m.deleted_at IS NULL AND m.id > $2
+		ORDER BY m.id ASC LIMIT $3`,
+		userID, afterID, limit+1,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	var messages []*Message
+	for rows.Next() {
+		var (
+			msg    = Message{UserID: userID}
+			roomID sql.NullInt32
+		)
+		if err := rows.Scan(&msg.ID, &roomID, &msg.Username, &msg.Content, &msg.Timestamp); err != nil {
+			return nil, false, err
+		}
+		msg.RoomID = roomID.Int32
+		messages = append(messages, &msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	messages, hasMore := trimPage(messages, limit)
+	return messages, hasMore, nil
+}
+
+func (s *sqlStore) PendingDirectMessages(ctx context.Context, recipientID int32) ([]*Message, error) {
+	rows, err := s.query(ctx,
+		`SELECT m.id, m.user_id, u.username, m.message, m.created_at, m.recipient_id, m.conversation_id, m.encrypted
+		FROM messages m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.recipient_id = $1 AND m.is_direct AND m.delivered_at IS NULL
+		ORDER BY m.id`,
+		recipientID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanDirectMessages(rows)
+}
+
+// scanDirectMessages reads rows of id, user_id, username, message,
+// created_at, recipient_id, conversation_id and encrypted
+func scanDirectMessages(rows *sql.Rows) ([]*Message, error) {
+	var messages []*Message
+	for rows.Next() {
+		var (
+			msg            = Message{IsDirect: true}
+			conversationID sql.NullInt32
+		)
+		if err := rows.Scan(&msg.ID, &msg.UserID, &msg.Username, &msg.Content, &msg.Timestamp, &msg.RecipientID, &conversationID, &msg.Encrypted); err != nil {
+			return nil, err
+		}
+		msg.ConversationID = conversationID.Int32
+		messages = append(messages, &msg)
+	}
+	return messages, rows.Err()
+}
+
+func (s *sqlStore) MarkDelivered(ctx context.Context, messageID int32, deliveredAt int64) error {
+	_, err := s.exec(ctx,
+		"UPDATE messages SET delivered_at = $1 WHERE id = $2 AND delivered_at IS NULL",
+		deliveredAt, messageID,
+	)
+	return err
+}
+
+func (s *sqlStore) RoomHistory(ctx context.Context, roomID, beforeID, afterID int32, limit int) ([]*Message, bool, error) {
+	query := `SELECT m.id, m.user_id, COALESCE(u.username, 'SYSTEM'), m.message, m.created_at, m.is_system,
+			m.edited_at, m.deleted_at, m.reply_to_id, m.thread_root_id,
+			(SELECT COUNT(*) FROM messages r WHERE r.thread_root_id = m.id)
+		FROM messages m
+		LEFT JOIN users u ON u.id = m.user_id
+		WHERE m.room_id = $1 AND NOT m.is_direct`
+	args := []interface{}{roomID}
+
+	forward := afterID > 0
+	switch {
+	case forward:
+		query += " AND m.id > $2 ORDER BY m.id ASC"
+		args = append(args, afterID)
+	case beforeID > 0:
+		query += " AND m.id < $2 ORDER BY m.id DESC"
+		args = append(args, beforeID)
+	default:
+		query += " ORDER BY m.id DESC"
+	}
+	// fetch one extra row to find out whether another page exists
+	query += fmt.Sprintf(" LIMIT $%d", len(args)+1)
+	args = append(args, limit+1)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	var messages []*Message
+	for rows.Next() {
+		var (
+			msg                         Message
+			userID, replyTo, threadRoot sql.NullInt32
+			editedAt, deletedAt         sql.NullInt64
+		)
+		if err := rows.Scan(&msg.ID, &userID, &msg.Username, &msg.Content, &msg.Timestamp, &msg.IsSystem,
+			&editedAt, &deletedAt, &replyTo, &threadRoot, &msg.ReplyCount); err != nil {
+			return nil, false, err
+		}
+		msg.UserID = userID.Int32
+		msg.EditedAt = editedAt.Int64
+		msg.DeletedAt = deletedAt.Int64
+		msg.ReplyToID = replyTo.Int32
+		msg.ThreadRootID = threadRoot.Int32
+		msg.RoomID = roomID
+		messages = append(messages, &msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	messages, hasMore := trimPage(messages, limit)
+	if !forward {
+		reverse(messages)
+	}
+	return messages, hasMore, nil
+}
+
+func (s *sqlStore) RoomTranscript(ctx context.Context, roomID int32, since, until int64, afterID int32, limit int) ([]*Message, bool, error) {
+	query := `SELECT m.id, m.user_id, COALESCE(u.username, 'SYSTEM'), m.message, m.created_at, m.is_system,
+			m.edited_at, m.deleted_at, m.reply_to_id, m.thread_root_id,
+			(SELECT COUNT(*) FROM messages r WHERE r.thread_root_id = m.id)
+		FROM messages m
+		LEFT JOIN users u ON u.id = m.user_id
+		WHERE m.room_id = $1 AND NOT m.is_direct AND m.id > $2`
+	args := []interface{}{roomID, afterID}
+	if since > 0 {
+		args = append(args, since)
+		query += fmt.Sprintf(" AND m.created_at >= $%d", len(args))
+	}
+	if until > 0 {
+		args = append(args, until)
+		query += fmt.Sprintf(" AND m.created_at < $%d", len(args))
+	}
+	// fetch one extra row to find out whether another page exists
+	query += fmt.Sprintf(" ORDER BY m.id ASC LIMIT $%d", len(args)+1)
+	args = append(args, limit+1)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	var messages []*Message
+	for rows.Next() {
+		var (
+			msg                         Message
+			userID, replyTo, threadRoot sql.NullInt32
+			editedAt, deletedAt         sql.NullInt64
+		)
+		if err := rows.Scan(&msg.ID, &userID, &msg.Username, &msg.Content, &msg.Timestamp, &msg.IsSystem,
+			&editedAt, &deletedAt, &replyTo, &threadRoot, &msg.ReplyCount); err != nil {
+			return nil, false, err
+		}
+		msg.UserID = userID.Int32
+		msg.EditedAt = editedAt.Int64
+		msg.DeletedAt = deletedAt.Int64
+		msg.ReplyToID = replyTo.Int32
+		msg.ThreadRootID = threadRoot.Int32
+		msg.RoomID = roomID
+		messages = append(messages, &msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	messages, hasMore := trimPage(messages, limit)
+	return messages, hasMore, nil
+}
+
+func (s *sqlStore) ConversationID(ctx context.Context, userA, userB int32) (int32, error) {
+	if userA > userB {
+		userA, userB = userB, userA
+	}
+
+	_, err := s.exec(ctx,
+		"INSERT INTO conversations (user1_id, user2_id, created_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+		userA, userB, time.Now().Unix(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return s.FindConversation(ctx, userA, userB)
+}
+
+func (s *sqlStore) FindConversation(ctx context.Context, userA, userB int32) (int32, error) {
+	if userA > userB {
+		userA, userB = userB, userA
+	}
+
+	var id int32
+	err := s.queryRow(ctx,
+		"SELECT id FROM conversations WHERE user1_id = $1 AND user2_id = $2",
+		userA, userB,
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	return id, err
+}
+
+func (s *sqlStore) ListConversations(ctx context.Context, userID int32) ([]*Conversation, error) {
+	rows, err := s.query(ctx,
+		`SELECT c.id, c.created_at, p.id, p.username,
+			m.id, m.user_id, su.username, m.message, m.created_at, m.recipient_id, m.encrypted,
+			(SELECT COUNT(*) FROM messages um
+				WHERE um.conversation_id = c.id AND um.recipient_id = $1 AND um.read_at IS NULL)
+		FROM conversations c
+		JOIN users p ON p.id = CASE WHEN c.user1_id = $1 THEN c.user2_id ELSE c.user1_id END
+		LEFT JOIN messages m ON m.id = (SELECT MAX(lm.id) FROM messages lm WHERE lm.conversation_id = c.id)
+		LEFT JOIN users su ON su.id = m.user_id
+		WHERE c.user1_id = $1 OR c.user2_id = $1
+		ORDER BY m.id DESC NULLS LAST, c.id DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var conversations []*Conversation
+	for rows.Next() {
+		var (
+			c           Conversation
+			msgID       sql.NullInt32
+			msgUserID   sql.NullInt32
+			msgUsername sql.NullString
+			msgContent  sql.NullString
+			msgTime     sql.NullInt64
+			msgTo       sql.NullInt32
+			msgSecret   sql.NullBool
+		)
+		err := rows.Scan(&c.ID, &c.CreatedAt, &c.PeerID, &c.PeerUsername,
+			&msgID, &msgUserID, &msgUsername, &msgContent, &msgTime, &msgTo, &msgSecret, &c.UnreadCount)
+		if err != nil {
+			return nil, err
+		}
+		if msgID.Valid {
+			c.LastMessage = &Message{
+				ID:             msgID.Int32,
+				UserID:         msgUserID.Int32,
+				Username:       msgUsername.String,
+				Content:        msgContent.String,
+				Timestamp:      msgTime.Int64,
+				RecipientID:    msgTo.Int32,
+				ConversationID: c.ID,
+				IsDirect:       true,
+				Encrypted:      msgSecret.Bool,
+			}
+		}
+		conversations = append(conversations, &c)
+	}
+	return conversations, rows.Err()
+}
+
+func (s *sqlStore) ConversationHistory(ctx context.Context, conversationID, beforeID int32, limit int) ([]*Message, bool, error) {
+	query := `SELECT m.id, m.user_id, u.username, m.message, m.created_at, m.recipient_id, m.conversation_id, m.encrypted
+		FROM messages m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.conversation_id = $1`
+	args := []interface{}{conversationID}
+	if beforeID > 0 {
+		query += " AND m.id < $2"
+		args = append(args, beforeID)
+	}
+	// fetch one extra row to find out whether another page exists
+	query += fmt.Sprintf(" ORDER BY m.id DESC LIMIT $%d", len(args)+1)
+	args = append(args, limit+1)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	messages, err := scanDirectMessages(rows)
+	if err != nil {
+		return nil, false, err
+	}
+
+	messages, hasMore := trimPage(messages, limit)
+	reverse(messages)
+	return messages, hasMore, nil
+}
+
+func (s *sqlStore) MarkConversationRead(ctx context.Context, conversationID, userID int32, readAt int64) error {
+	_, err := s.exec(ctx,
+		"UPDATE messages SET read_at = $1 WHERE conversation_id = $2 AND recipient_id = $3 AND read_at IS NULL",
+		readAt, conversationID, userID,
+	)
+	return err
+}
+
+func (s *sqlStore) SearchMessages(ctx context.Context, q SearchQuery) ([]*SearchResult, bool, error) {
+	query := s.dialect.searchBase
+	args := []interface{}{s.dialect.searchText(q.Text)}
+
+	addFilter := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		query += fmt.Sprintf(" AND "+cond, len(args))
+	}
+	if q.RoomID != 0 {
+		addFilter("m.room_id = $%d", q.RoomID)
+	}
+	if q.RoomIDs != nil {
+		if len(q.RoomIDs) == 0 {
+			return nil, false, nil
+		}
+		placeholders := make([]string, len(q.RoomIDs))
+		for i, id := range q.RoomIDs {
+			args = append(args, id)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		query += " AND m.room_id IN (" + strings.Join(placeholders, ", ") + ")"
+	}
+	if q.Username != "" {
+		addFilter("u.username = $%d", q.Username)
+	}
+	if q.Since != 0 {
+		addFilter("m.created_at >= $%d", q.Since)
+	}
+	if q.Until != 0 {
+		addFilter("m.created_at < $%d", q.Until)
+	}
+
+	// fetch one extra row to find out whether another page exists
+	query += fmt.Sprintf(" ORDER BY rank DESC, m.id DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	args = append(args, q.Limit+1, q.Offset)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	var results []*SearchResult
+	for rows.Next() {
+		var (
+			msg    Message
+			result SearchResult
+		)
+		if err := rows.Scan(&msg.ID, &msg.Username, &msg.Content, &msg.Timestamp, &msg.RoomID, &result.RoomName, &result.Rank); err != nil {
+			return nil, false, err
+		}
+		result.Message = &msg
+		results = append(results, &result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	results, hasMore := trimPage(results, q.Limit)
+	return results, hasMore, nil
+}
+
+func (s *sqlStore) AppendAuditEvent(ctx context.Context, event *AuditEvent) error {
+	return s.queryRow(ctx,
+		`INSERT INTO audit_events (action, actor_id, actor_name, target_id, target_name, room_id, reason, details, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`,
+		event.Action, nullID(event.ActorID), event.ActorName, nullID(event.TargetID), event.TargetName,
+		nullID(event.RoomID), event.Reason, event.Details, event.CreatedAt,
+	).Scan(&event.ID)
+}
+
+func (s *sqlStore) ListAuditEvents(ctx context.Context, q AuditQuery) ([]*AuditEvent, bool, error) {
+	query := `SELECT id, action, actor_id, actor_name, target_id, target_name, room_id, reason, details, created_at
+		FROM audit_events
+		WHERE TRUE`
+	var args []interface{}
+
+	addFilter := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		query += fmt.Sprintf(" AND "+cond, len(args))
+	}
+	switch {
+	case q.Action == "":
+	case strings.Contains(q.Action, "."):
+		addFilter("action = $%d", q.Action)
+	default:
+		addFilter("action LIKE $%d", q.Action+".%")
+	}
+	if q.ActorID != 0 {
+		addFilter("actor_id = $%d", q.ActorID)
+	}
+	if q.TargetID != 0 {
+		addFilter("target_id = $%d", q.TargetID)
+	}
+	if q.RoomID != 0 {
+		addFilter("room_id = $%d", q.RoomID)
+	}
+	if q.Since != 0 {
+		addFilter("created_at >= $%d", q.Since)
+	}
+	if q.Until != 0 {
+		addFilter("created_at < $%d", q.Until)
+	}
+	if q.BeforeID != 0 {
+		addFilter("id < $%d", q.BeforeID)
+	}
+
+	// fetch one extra row to find out whether another page exists
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args)+1)
+	args = append(args, q.Limit+1)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	var events []*AuditEvent
+	for rows.Next() {
+		var (
+			event                     AuditEvent
+			actorID, targetID, roomID sql.NullInt32
+		)
+		if err := rows.Scan(&event.ID, &event.Action, &actorID, &event.ActorName, &targetID, &event.TargetName,
+			&roomID, &event.Reason, &event.Details, &event.CreatedAt); err != nil {
+			return nil, false, err
+		}
+		event.ActorID = actorID.Int32
+		event.TargetID = targetID.Int32
+		event.RoomID = roomID.Int32
+		events = append(events, &event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	events, hasMore := trimPage(events, q.Limit)
+	return events, hasMore, nil
+}
+
+// trimPage cuts a page fetched with one extra row back to limit and reports
+// whether the extra row was there
+func trimPage[T any](items []T, limit int) ([]T, bool) {
+	if len(items) > limit {
+		return items[:limit], true
+	}
+	return items, false
+}
+
+func reverse[T any](items []T) {
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+}
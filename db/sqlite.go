@@ -0,0 +1,68 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+var placeholderRe = regexp.MustCompile(`\$(\d+)`)
+
+var sqliteDialect = &dialect{
+	name: "sqlite",
+	// ?N binds the Nth argument just like $N does in Postgres
+	rebind: func(query string) string { return placeholderRe.ReplaceAllString(query, "?$1") },
+	isUniqueViolation: func(err error) bool {
+		var sqliteErr *sqlite.Error
+		return errors.As(err, &sqliteErr) &&
+			(sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY)
+	},
+	searchBase: `SELECT m.id, u.username, m.message, m.created_at, m.room_id, r.name,
+			-bm25(messages_fts) AS rank
+		FROM messages_fts
+		JOIN messages m ON m.id = messages_fts.rowid
+		JOIN rooms r ON r.id = m.room_id
+		JOIN users u ON u.id = m.user_id
+		WHERE messages_fts MATCH $1
+			AND NOT m.is_direct AND NOT m.is_system`,
+	searchText: sqliteSearchText,
+	// IMMEDIATE takes the write lock up front, a deferred transaction would
+	// let two processes read the same pending migrations
+	lockMigrations: []string{"BEGIN IMMEDIATE"},
+}
+
+// sqliteSearchText quotes every word of the input so FTS5 treats them as
+// plain terms that must all match, rather than as query syntax
+func sqliteSearchText(text string) string {
+	words := strings.Fields(text)
+	for i, word := range words {
+		words[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+	}
+	return strings.Join(words, " ")
+}
+
+func openSQLite(path string) (*sqlStore, error) {
+	dsn := "file:" + path + "?" + url.Values{
+		"_pragma": {"foreign_keys(1)", "busy_timeout(5000)", "journal_mode(WAL)"},
+	}.Encode()
+
+	database, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+	// SQLite allows a single writer, a single connection avoids busy errors
+	database.SetMaxOpenConns(1)
+
+	if err := database.Ping(); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("failed to open database %s: %v", path, err)
+	}
+
+	return &sqlStore{db: database, dialect: sqliteDialect}, nil
+}
@@ -0,0 +1,472 @@
+package db
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrNotFound is returned when the requested record does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a write would violate a uniqueness rule
+	ErrConflict = errors.New("already exists")
+)
+
+type User struct {
+	ID           int32
+	Username     string
+	PasswordHash string
+	IsAdmin      bool
+}
+
+// Room visibilities. Public rooms are listed and open to everyone, unlisted
+// rooms are open to anyone who knows their ID, and private rooms only admit
+// members.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+type Room struct {
+	ID         int32
+	Name       string
+	CreatedAt  int64
+	CreatedBy  int32
+	Metadata   map[string]string
+	Visibility string
+	// PasswordHash is empty for rooms without a password
+	PasswordHash string
+}
+
+// Membership records that a user has joined a room. It outlives the
+// connection, unlike the in-memory presence tracked by the server.
+type Membership struct {
+	RoomID   int32
+	UserID   int32
+	JoinedAt int64
+	Role     string
+	// LastReadID is the newest room message the member has read, zero
+	// before they read any
+	LastReadID int32
+}
+
+// ReadReceipt is how far a member has read in a room
+type ReadReceipt struct {
+	UserID     int32
+	Username   string
+	LastReadID int32
+}
+
+// Room roles, from most to least privileged
+const (
+	RoleOwner     = "owner"
+	RoleModerator = "moderator"
+	RoleMember    = "member"
+)
+
+// Kinds of Restriction
+const (
+	RestrictionBan  = "ban"
+	RestrictionMute = "mute"
+)
+
+// Restriction bans a user from a room or mutes them in it
+type Restriction struct {
+	RoomID    int32
+	UserID    int32
+	Kind      string
+	CreatedBy int32
+	CreatedAt int64
+	// ExpiresAt is zero for restrictions that last until lifted
+	ExpiresAt int64
+	Reason    string
+}
+
+// RoomInvite is an invite code for a room. Only a hash of the code is
+// stored.
+type RoomInvite struct {
+	CodeHash  string
+	RoomID    int32
+	CreatedBy int32
+	CreatedAt int64
+	// ExpiresAt is zero for invites that never expire
+	ExpiresAt int64
+	// MaxUses is zero for invites without a use limit
+	MaxUses int32
+	Uses    int32
+}
+
+// JoinRequest is a pending request by a non-member to join a room
+type JoinRequest struct {
+	RoomID      int32
+	UserID      int32
+	Username    string
+	RequestedAt int64
+}
+
+// Message is a stored chat message. System notices have no UserID and
+// direct messages have a RecipientID instead of a RoomID.
+type Message struct {
+	ID          int32
+	UserID      int32
+	Username    string
+	Content     string
+	RoomID      int32
+	RecipientID int32
+	Timestamp   int64
+	IsSystem    bool
+	IsDirect    bool
+	// ConversationID groups the direct messages between two users
+	ConversationID int32
+	// DeliveredAt is zero while a direct message waits for its recipient
+	DeliveredAt int64
+	// Encrypted direct messages hold ciphertext only their users can read
+	Encrypted bool
+	// EditedAt is zero for messages that were never edited
+	EditedAt int64
+	// DeletedAt is zero for messages that were not deleted. Deleted
+	// messages have no content.
+	DeletedAt int64
+	// ReplyToID is the message a reply answers
+	ReplyToID int32
+	// ThreadRootID is the first message of the thread a reply belongs to
+	ThreadRootID int32
+	// ReplyCount is the number of replies in the thread a message starts,
+	// filled in on reads
+	ReplyCount int32
+}
+
+// Reaction counts the users who reacted to a message with one emoji
+type Reaction struct {
+	Emoji string
+	Count int32
+}
+
+// UserReaction is one emoji a user reacted to a room message with
+type UserReaction struct {
+	MessageID int32
+	RoomID    int32
+	Emoji     string
+	CreatedAt int64
+}
+
+// MessageEdit is an earlier version of an edited message
+type MessageEdit struct {
+	MessageID int32
+	// Content is the text the edit replaced
+	Content      string
+	EditedBy     int32
+	EditedByName string
+	EditedAt     int64
+}
+
+// PublicKey is the X25519 key a user publishes so others can encrypt
+// direct messages to them
+type PublicKey struct {
+	UserID      int32
+	Key         []byte
+	PublishedAt int64
+}
+
+// Conversation is a direct message thread as seen by one of its two users
+type Conversation struct {
+	ID           int32
+	PeerID       int32
+	PeerUsername string
+	CreatedAt    int64
+	// LastMessage is nil for a conversation without messages
+	LastMessage *Message
+	// UnreadCount counts the messages the peer sent that are not read yet
+	UnreadCount int32
+}
+
+// Session is a login session. Clients hold a signed token naming it, so
+// deleting the session revokes the token.
+type Session struct {
+	ID        string
+	UserID    int32
+	CreatedAt int64
+	ExpiresAt int64
+}
+
+// PasswordReset is an outstanding one-time reset code. Only a hash of the
+// code is stored.
+type PasswordReset struct {
+	CodeHash  string
+	UserID    int32
+	CreatedAt int64
+	ExpiresAt int64
+}
+
+// LoginFailure counts the failed logins of a subject, which is either
+// "user:<username>" or "ip:<address>"
+type LoginFailure struct {
+	Subject       string
+	Failures      int32
+	LastFailureAt int64
+	// LockedUntil is the Unix time before which logins are refused
+	LockedUntil int64
+}
+
+// SearchQuery filters a full-text message search. Zero values leave the
+// corresponding filter open.
+type SearchQuery struct {
+	Text   string
+	RoomID int32
+	// RoomIDs restricts results to these rooms when it is not nil
+	RoomIDs  []int32
+	Username string
+	Since    int64
+	Until    int64
+	Limit    int
+	Offset   int
+}
+
+type SearchResult struct {
+	Message  *Message
+	RoomName string
+	Rank     float32
+}
+
+// Audit actions, grouped into categories by the part before the dot
+const (
+	AuditRoomCreate    = "room.create"
+	AuditRoomAccess    = "room.access"
+	AuditRoomInvite    = "room.invite"
+	AuditJoinApprove   = "room.approve"
+	AuditJoinDeny      = "room.deny"
+	AuditBlockedWords  = "room.blocked_words"
+	AuditKick          = "member.kick"
+	AuditBan           = "member.ban"
+	AuditUnban         = "member.unban"
+	AuditMute          = "member.mute"
+	AuditUnmute        = "member.unmute"
+	AuditRole          = "member.role"
+	AuditRename        = "user.rename"
+	AuditPasswordReset = "user.password_reset"
+	AuditUnlock        = "user.unlock"
+	AuditAdminGrant    = "user.admin_grant"
+	AuditAdminRevoke   = "user.admin_revoke"
+	AuditDeleteAccount = "user.delete"
+	AuditMessageEdit   = "message.edit"
+	AuditMessageDelete = "message.delete"
+)
+
+// AuditEvent records an administrative action. Names are copied as they
+// were at the time, so the log still reads the same after renames.
+type AuditEvent struct {
+	ID     int32
+	Action string
+	// ActorID is zero for actions taken from the server host
+	ActorID    int32
+	ActorName  string
+	TargetID   int32
+	TargetName string
+	RoomID     int32
+	Reason     string
+	// Details describe the outcome, such as a new role or a ban expiry
+	Details   string
+	CreatedAt int64
+}
+
+// AuditQuery filters the audit log. Zero values leave the corresponding
+// filter open.
+type AuditQuery struct {
+	// Action matches one action, or every action of a category such as
+	// "member" when it has no dot
+	Action   string
+	ActorID  int32
+	TargetID int32
+	RoomID   int32
+	Since    int64
+	Until    int64
+	// BeforeID pages backwards from that event
+	BeforeID int32
+	Limit    int
+}
+
+// Store persists users, sessions, rooms, memberships, messages and the
+// audit log
+type Store interface {
+	// CreateUser returns ErrConflict if the username is taken
+	CreateUser(ctx context.Context, username, passwordHash string) (*User, error)
+	GetUser(ctx context.Context, id int32) (*User, error)
+	GetUserByUsername(ctx context.Context, username string) (*User, error)
+	// UpdateUsername returns ErrConflict if the username is taken
+	UpdateUsername(ctx context.Context, id int32, username string) error
+	UpdatePassword(ctx context.Context, id int32, passwordHash string) error
+	SetAdmin(ctx context.Context, id int32, admin bool) error
+	// DeleteUser anonymizes a user. The row stays so conversations and kept
+	// messages still have an author, but it takes the placeholder username
+	// and loses its password and admin flag. Sessions, memberships,
+	// restrictions, join requests, reset codes and the public key of the
+	// user are removed, and so are the messages they sent when
+	// deleteMessages is set. Rooms the user created pass to their most
+	// senior remaining member, who becomes an owner, and the new creators
+	// are returned by room ID, zero for rooms nobody else was in.
+	DeleteUser(ctx context.Context, id int32, placeholder string, deleteMessages bool) (map[int32]int32, error)
+
+	// CreateSession stores a new session and drops the user's expired ones
+	CreateSession(ctx context.Context, session *Session) error
+	GetSession(ctx context.Context, id string) (*Session, error)
+	// DeleteSessions revokes every session of the user except keepID and
+	// returns how many were revoked
+	DeleteSessions(ctx context.Context, userID int32, keepID string) (int, error)
+
+	GetLoginFailure(ctx context.Context, subject string) (*LoginFailure, error)
+	// SaveLoginFailure creates or replaces the record for f.Subject
+	SaveLoginFailure(ctx context.Context, f *LoginFailure) error
+	ClearLoginFailure(ctx context.Context, subject string) error
+
+	// SavePublicKey creates or replaces the public key of key.UserID
+	SavePublicKey(ctx context.Context, key *PublicKey) error
+	// GetPublicKey returns ErrNotFound if the user has not published a key
+	GetPublicKey(ctx context.Context, userID int32) (*PublicKey, error)
+
+	// CreatePasswordReset replaces any reset code the user already has
+	CreatePasswordReset(ctx context.Context, reset *PasswordReset) error
+	// TakePasswordReset removes and returns the reset with the given code
+	// hash, so each code works once
+	TakePasswordReset(ctx context.Context, codeHash string) (*PasswordReset, error)
+
+	// CreateRoom fills in room.ID and returns ErrConflict if the name is taken
+	CreateRoom(ctx context.Context, room *Room) error
+	ListRooms(ctx context.Context) ([]*Room, error)
+	// UpdateRoomAccess changes the visibility and password of a room
+	UpdateRoomAccess(ctx context.Context, roomID int32, visibility, passwordHash string) error
+
+	// AddMembership is a no-op if the user already is a member, otherwise
+	// the user joins with RoleMember
+	AddMembership(ctx context.Context, roomID, userID int32) error
+	// SetMemberRole returns ErrNotFound if the user is not a member
+	SetMemberRole(ctx context.Context, roomID, userID int32, role string) error
+	RemoveMembership(ctx context.Context, roomID, userID int32) error
+	// GetMembership returns ErrNotFound if the user is not a member
+	GetMembership(ctx context.Context, roomID, userID int32) (*Membership, error)
+	ListMemberships(ctx context.Context, userID int32) ([]*Membership, error)
+	// MarkRoomRead moves the member's read position forward to messageID and
+	// reports whether it moved. It returns ErrNotFound if the user is not a
+	// member.
+	MarkRoomRead(ctx context.Context, roomID, userID, messageID int32) (bool, error)
+	// RoomUnreadCounts returns by room ID how many messages others sent to
+	// the user's rooms past their read position, leaving out system notices,
+	// deleted messages and rooms with nothing unread
+	RoomUnreadCounts(ctx context.Context, userID int32) (map[int32]int32, error)
+	// RoomUnreadCount counts the same for one room, zero if the user is not
+	// a member
+	RoomUnreadCount(ctx context.Context, roomID, userID int32) (int32, error)
+	// RoomReaders returns the members of a room who have read up to
+	// messageID or further, by username
+	RoomReaders(ctx context.Context, roomID, messageID int32) ([]*ReadReceipt, error)
+
+	// SaveRestriction creates or replaces the restriction of r.Kind
+	SaveRestriction(ctx context.Context, r *Restriction) error
+	// GetRestriction returns ErrNotFound if there is none, expired ones
+	// included
+	GetRestriction(ctx context.Context, roomID, userID int32, kind string) (*Restriction, error)
+	// DeleteRestriction returns ErrNotFound if there is none
+	DeleteRestriction(ctx context.Context, roomID, userID int32, kind string) error
+	// UserRestrictions returns the bans and mutes of a user in every room,
+	// expired ones included, oldest first
+	UserRestrictions(ctx context.Context, userID int32) ([]*Restriction, error)
+
+	// SetBlockedWords replaces the words the message filter acts on in a room
+	SetBlockedWords(ctx context.Context, roomID int32, words []string) error
+	// ListBlockedWords returns the blocked words of a room in sorted order
+	ListBlockedWords(ctx context.Context, roomID int32) ([]string, error)
+
+	CreateRoomInvite(ctx context.Context, invite *RoomInvite) error
+	// UseRoomInvite counts one use of the invite with the given code hash
+	// and returns it, or ErrNotFound if it does not exist, has expired at
+	// now or is used up
+	UseRoomInvite(ctx context.Context, codeHash string, now int64) (*RoomInvite, error)
+
+	// CreateJoinRequest returns ErrConflict if the user already asked
+	CreateJoinRequest(ctx context.Context, roomID, userID int32, requestedAt int64) error
+	// ListJoinRequests returns the pending requests for a room, oldest first
+	ListJoinRequests(ctx context.Context, roomID int32) ([]*JoinRequest, error)
+	// DeleteJoinRequest returns ErrNotFound if there is no such request
+	DeleteJoinRequest(ctx context.Context, roomID, userID int32) error
+	// UserJoinRequests returns the pending requests a user made, oldest first
+	UserJoinRequests(ctx context.Context, userID int32) ([]*JoinRequest, error)
+
+	// SaveMessage fills in msg.ID
+	SaveMessage(ctx context.Context, msg *Message) error
+	// GetMessage returns ErrNotFound if there is no such message
+	GetMessage(ctx context.Context, id int32) (*Message, error)
+	// EditMessage replaces the text of a message, keeping the old text in
+	// its edit history. It returns ErrNotFound if the message does not
+	// exist or was deleted.
+	EditMessage(ctx context.Context, id int32, content string, editedBy int32, editedAt int64) error
+	// DeleteMessage removes the text and edit history of a message but
+	// keeps its place in history. It returns ErrNotFound if the message
+	// does not exist or was already deleted.
+	DeleteMessage(ctx context.Context, id int32, deletedAt int64) error
+	// MessageEdits returns the earlier versions of a message, oldest first
+	MessageEdits(ctx context.Context, messageID int32) ([]*MessageEdit, error)
+	// ThreadReplies pages forwards through the replies in the thread a
+	// message starts, starting after afterID
+	ThreadReplies(ctx context.Context, messageID, afterID int32, limit int) ([]*Message, bool, error)
+	// AddReaction returns ErrConflict if the user already reacted to the
+	// message with that emoji
+	AddReaction(ctx context.Context, messageID, userID int32, emoji string, createdAt int64) error
+	// RemoveReaction returns ErrNotFound if the user had not reacted to the
+	// message with that emoji
+	RemoveReaction(ctx context.Context, messageID, userID int32, emoji string) error
+	// MessageReactions returns the reactions to each of the messages by
+	// message ID, emojis in the order they were first used
+	MessageReactions(ctx context.Context, messageIDs []int32) (map[int32][]*Reaction, error)
+	// UserReactions returns the reactions a user made, oldest first
+	UserReactions(ctx context.Context, userID int32) ([]*UserReaction, error)
+	// RoomHistory returns up to limit room messages, oldest first. With a
+	// beforeID it pages backwards from that message, with an afterID
+	// forwards, and with neither it returns the newest messages. The
+	// boolean reports whether more messages exist in the paging direction.
+	RoomHistory(ctx context.Context, roomID, beforeID, afterID int32, limit int) ([]*Message, bool, error)
+	// RoomTranscript pages forwards through the room messages sent in
+	// [since, until), starting after afterID. Deleted messages are kept,
+	// without their text. Zero bounds are left open.
+	RoomTranscript(ctx context.Context, roomID int32, since, until int64, afterID int32, limit int) ([]*Message, bool, error)
+	// UserMessages pages forwards through the room messages a user sent
+	// and has not deleted, starting after afterID
+	UserMessages(ctx context.Context, userID, afterID int32, limit int) ([]*Message, bool, error)
+	// PendingDirectMessages returns the undelivered direct messages sent to
+	// a user, oldest first
+	PendingDirectMessages(ctx context.Context, recipientID int32) ([]*Message, error)
+	MarkDelivered(ctx context.Context, messageID int32, deliveredAt int64) error
+	// ConversationID returns the conversation between two users, creating it
+	// on first use
+	ConversationID(ctx context.Context, userA, userB int32) (int32, error)
+	// FindConversation returns the conversation between two users, or
+	// ErrNotFound if they never wrote to each other
+	FindConversation(ctx context.Context, userA, userB int32) (int32, error)
+	// ListConversations returns a user's conversations, most recent first
+	ListConversations(ctx context.Context, userID int32) ([]*Conversation, error)
+	// ConversationHistory pages backwards through a conversation like
+	// RoomHistory does
+	ConversationHistory(ctx context.Context, conversationID, beforeID int32, limit int) ([]*Message, bool, error)
+	// MarkConversationRead marks every message sent to userID in the
+	// conversation as read
+	MarkConversationRead(ctx context.Context, conversationID, userID int32, readAt int64) error
+
+	// AppendAuditEvent fills in event.ID. Events are never changed or
+	// removed once written.
+	AppendAuditEvent(ctx context.Context, event *AuditEvent) error
+	// ListAuditEvents returns matching events, newest first. The boolean
+	// reports whether older events match too.
+	ListAuditEvents(ctx context.Context, q AuditQuery) ([]*AuditEvent, bool, error)
+
+	// SearchMessages ranks room messages written by users against q.Text.
+	// The boolean reports whether more results exist past this page.
+	SearchMessages(ctx context.Context, q SearchQuery) ([]*SearchResult, bool, error)
+
+	Close() error
+}
+
+// Migrator is implemented by stores with a versioned schema
+type Migrator interface {
+	Migrate() ([]Migration, error)
+	MigrateDown(steps int) ([]Migration, error)
+	MigrationStatus() ([]MigrationStatus, error)
+}
@@ -1,26 +1,103 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"github.com/ayushsarode/termiXchat/db"
 	pb "github.com/ayushsarode/termiXchat/proto"
 	"github.com/ayushsarode/termiXchat/server"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			runMigrate(os.Args[2:])
+			return
+		case "certs":
+			runCerts(os.Args[2:])
+			return
+		case "admin":
+			runAdmin(os.Args[2:])
+			return
+		}
+	}
+
 	listener, err := net.Listen("tcp", ":50051")
 	if err != nil {
 		log.Fatalf("Failed to listen: %v", err)
 	}
 
-	srv, err := server.NewServer()
+	cfg, err := db.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load database config: %v", err)
+	}
+
+	store, err := db.Open(cfg)
+	if err != nil {
+		log.Fatalf("Failed to open %s database: %v", cfg.Driver, err)
+	}
+	defer store.Close()
+
+	authCfg, err := server.AuthConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load auth config: %v", err)
+	}
+
+	srv, err := server.NewServer(store, authCfg)
 	if err != nil {
 		log.Fatalf("Failed to create server: %v", err)
 	}
 
-	grpcServer := grpc.NewServer()
+	filters, err := server.FiltersFromEnv(store)
+	if err != nil {
+		log.Fatalf("Failed to load message filters: %v", err)
+	}
+	srv.Filters = server.NewFilterPipeline(filters...)
+
+	srv.EditWindow, err = server.EditWindowFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load message edit window: %v", err)
+	}
+
+	tlsCfg, err := server.TLSConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load TLS config: %v", err)
+	}
+
+	rateLimits, err := server.RateLimitsFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load rate limits: %v", err)
+	}
+	limiter := server.NewRateLimiter(rateLimits)
+
+	// every call except signing up and logging in needs a session token,
+	// and the rate limiter runs second so it can key on the caller
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(srv.UnaryAuthInterceptor, limiter.UnaryInterceptor),
+		grpc.ChainStreamInterceptor(srv.StreamAuthInterceptor),
+	}
+	if tlsCfg != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsCfg)))
+		if tlsCfg.ClientCAs != nil {
+			log.Println("TLS enabled, client certificates are required")
+		} else {
+			log.Println("TLS enabled")
+		}
+	} else {
+		log.Println("TLS_CERT_FILE is not set, serving without TLS. Passwords are sent in cleartext.")
+	}
+
+	grpcServer := grpc.NewServer(opts...)
 	pb.RegisterChatServiceServer(grpcServer, srv)
 
 	log.Println("Server is running on port 50051...")
@@ -28,3 +105,162 @@ func main() {
 		log.Fatalf("Failed to serve: %v", err)
 	}
 }
+
+// runMigrate handles "zenith-server migrate [up | down [n] | status]"
+func runMigrate(args []string) {
+	cfg, err := db.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load database config: %v", err)
+	}
+
+	store, err := db.Connect(cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer store.Close()
+
+	migrator, ok := store.(db.Migrator)
+	if !ok {
+		log.Fatalf("The %s database has no migrations", cfg.Driver)
+	}
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		applied, err := migrator.Migrate()
+		for _, m := range applied {
+			log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		if len(applied) == 0 {
+			log.Println("Database schema is up to date")
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				log.Fatalf("Invalid number of steps: %s", args[1])
+			}
+		}
+		rolledBack, err := migrator.MigrateDown(steps)
+		for _, m := range rolledBack {
+			log.Printf("Rolled back migration %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("Rollback failed: %v", err)
+		}
+		if len(rolledBack) == 0 {
+			log.Println("No migrations to roll back")
+		}
+
+	case "status":
+		statuses, err := migrator.MigrationStatus()
+		if err != nil {
+			log.Fatalf("Failed to read migration status: %v", err)
+		}
+		for _, st := range statuses {
+			state := "pending"
+			if st.Applied {
+				state = "applied " + time.Unix(st.AppliedAt, 0).Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%-30s %s\n", st.Version, st.Name, state)
+		}
+
+	default:
+		fmt.Fprintln(os.Stderr, "usage: zenith-server migrate [up | down [n] | status]")
+		os.Exit(2)
+	}
+}
+
+// runCerts handles "zenith-server certs [dir] [host...]", writing a
+// development CA with server and client certificates signed by it
+func runCerts(args []string) {
+	dir := "certs"
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	hosts := []string{"localhost", "127.0.0.1"}
+	if len(args) > 1 {
+		hosts = args[1:]
+	}
+
+	if err := server.GenerateDevCerts(dir, hosts); err != nil {
+		log.Fatalf("Failed to generate certificates: %v", err)
+	}
+	log.Printf("Wrote development certificates for %s to %s", strings.Join(hosts, ", "), dir)
+}
+
+// runAdmin handles "zenith-server admin grant|revoke|unlock <username>"
+func runAdmin(args []string) {
+	if len(args) != 2 || (args[0] != "grant" && args[0] != "revoke" && args[0] != "unlock") {
+		fmt.Fprintln(os.Stderr, "usage: zenith-server admin grant|revoke|unlock <username>")
+		os.Exit(2)
+	}
+
+	cfg, err := db.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load database config: %v", err)
+	}
+
+	store, err := db.Open(cfg)
+	if err != nil {
+		log.Fatalf("Failed to open %s database: %v", cfg.Driver, err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	user, err := store.GetUserByUsername(ctx, args[1])
+	if err != nil {
+		log.Fatalf("Failed to find user %s: %v", args[1], err)
+	}
+
+	// unlocking works without a running server, for when every admin is
+	// locked out too
+	if args[0] == "unlock" {
+		if err := store.ClearLoginFailure(ctx, server.LoginSubject(user.Username)); err != nil {
+			log.Fatalf("Failed to unlock user %s: %v", user.Username, err)
+		}
+		auditHostAction(ctx, store, db.AuditUnlock, user)
+		log.Printf("Cleared failed logins for %s", user.Username)
+		return
+	}
+
+	grant := args[0] == "grant"
+	if err := store.SetAdmin(ctx, user.ID, grant); err != nil {
+		log.Fatalf("Failed to update user %s: %v", user.Username, err)
+	}
+
+	action := db.AuditAdminRevoke
+	if grant {
+		action = db.AuditAdminGrant
+	}
+	auditHostAction(ctx, store, action, user)
+
+	if grant {
+		log.Printf("%s is now an admin", user.Username)
+	} else {
+		log.Printf("%s is no longer an admin", user.Username)
+	}
+}
+
+// auditHostAction records an admin command run on the server host, which
+// has no acting user
+func auditHostAction(ctx context.Context, store db.Store, action string, target *db.User) {
+	event := &db.AuditEvent{
+		Action:     action,
+		TargetID:   target.ID,
+		TargetName: target.Username,
+		CreatedAt:  time.Now().Unix(),
+	}
+	if err := store.AppendAuditEvent(ctx, event); err != nil {
+		log.Printf("Failed to record audit event %s: %v", action, err)
+	}
+}
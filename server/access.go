@@ -0,0 +1,395 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ayushsarode/termiXchat/db"
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func visibilityFromProto(v pb.RoomVisibility) (string, error) {
+	switch v {
+	case pb.RoomVisibility_ROOM_VISIBILITY_PUBLIC:
+		return db.VisibilityPublic, nil
+	case pb.RoomVisibility_ROOM_VISIBILITY_UNLISTED:
+		return db.VisibilityUnlisted, nil
+	case pb.RoomVisibility_ROOM_VISIBILITY_PRIVATE:
+		return db.VisibilityPrivate, nil
+	}
+	return "", status.Error(codes.InvalidArgument, "unknown room visibility")
+}
+
+func visibilityToProto(v string) pb.RoomVisibility {
+	switch v {
+	case db.VisibilityUnlisted:
+		return pb.RoomVisibility_ROOM_VISIBILITY_UNLISTED
+	case db.VisibilityPrivate:
+		return pb.RoomVisibility_ROOM_VISIBILITY_PRIVATE
+	}
+	return pb.RoomVisibility_ROOM_VISIBILITY_PUBLIC
+}
+
+// hashRoomPassword returns the stored form of a room password, which is
+// empty for rooms without one
+func hashRoomPassword(password string) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", status.Error(codes.Internal, "failed to hash room password")
+	}
+	return string(hash), nil
+}
+
+// isOpen reports whether anyone who knows the room ID can read and join the
+// room without becoming a member first. Callers must hold s.Mutex.
+func (r *Room) isOpen() bool {
+	return r.Visibility != db.VisibilityPrivate && r.PasswordHash == ""
+}
+
+// isMember reports whether the user is a member of the room. The owner
+// always is, even in rooms created before memberships were recorded.
+func (s *Server) isMember(ctx context.Context, room *Room, userID int32) (bool, error) {
+	if room.CreatedBy == userID {
+		return true, nil
+	}
+	_, err := s.Store.GetMembership(ctx, room.ID, userID)
+	if errors.Is(err, db.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		log.Printf("Failed to load room membership: %v", err)
+		return false, status.Error(codes.Internal, "failed to check room membership")
+	}
+	return true, nil
+}
+
+// memberRooms returns the IDs of the rooms the user is a member of
+func (s *Server) memberRooms(ctx context.Context, userID int32) (map[int32]bool, error) {
+	memberships, err := s.Store.ListMemberships(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to list room memberships: %v", err)
+		return nil, status.Error(codes.Internal, "failed to check room membership")
+	}
+
+	rooms := make(map[int32]bool, len(memberships))
+	for _, m := range memberships {
+		rooms[m.RoomID] = true
+	}
+	return rooms, nil
+}
+
+// checkReadAccess fails unless the caller may read the room's messages,
+// hiding private rooms from non-members entirely
+func (s *Server) checkReadAccess(ctx context.Context, roomID int32) error {
+	s.Mutex.RLock()
+	defer s.Mutex.RUnlock()
+
+	room, exists := s.Rooms[roomID]
+	if !exists {
+		return status.Error(codes.NotFound, "room not found")
+	}
+	if err := s.checkNotBanned(ctx, roomID, callerID(ctx)); err != nil {
+		return err
+	}
+	if room.isOpen() {
+		return nil
+	}
+
+	member, err := s.isMember(ctx, room, callerID(ctx))
+	if err != nil {
+		return err
+	}
+	if !member {
+		if room.Visibility == db.VisibilityPrivate {
+			return status.Error(codes.NotFound, "room not found")
+		}
+		return status.Error(codes.PermissionDenied, "join the room first")
+	}
+	return nil
+}
+
+// admit checks that a user may join the room, asking non-members for the
+// room password. Callers must hold s.Mutex.
+func (s *Server) admit(ctx context.Context, room *Room, userID int32, password string) error {
+	if err := s.checkNotBanned(ctx, room.ID, userID); err != nil {
+		return err
+	}
+
+	member, err := s.isMember(ctx, room, userID)
+	if err != nil || member {
+		return err
+	}
+
+	if room.Visibility == db.VisibilityPrivate {
+		return status.Error(codes.PermissionDenied, "room is private, ask for an invite or request to join")
+	}
+	if room.PasswordHash == "" {
+		return nil
+	}
+	if password == "" {
+		return status.Error(codes.PermissionDenied, "room requires a password")
+	}
+	if bcrypt.CompareHashAndPassword([]byte(room.PasswordHash), []byte(password)) != nil {
+		return status.Error(codes.PermissionDenied, "incorrect room password")
+	}
+	return nil
+}
+
+// notifyUser sends an unstored system notice to every stream the user has
+// open. Callers must hold s.Mutex.
+func (s *Server) notifyUser(userID int32, text string) {
+	notice := &pb.ReceiveMessageResponse{
+		Username:  "SYSTEM",
+		Message:   text,
+		Timestamp: time.Now().Unix(),
+		IsSystem:  true,
+	}
+	for _, room := range s.Rooms {
+		if client, ok := room.Clients[userID]; ok {
+			if err := client.Send(notice); err != nil {
+				log.Printf("Failed to send notice: %v", err)
+			}
+		}
+	}
+}
+
+// SetRoomAccess changes the visibility and password of a room
+func (s *Server) SetRoomAccess(ctx context.Context, req *pb.SetRoomAccessRequest) (*pb.SetRoomAccessResponse, error) {
+	room, _, err := s.requireRoomRole(ctx, req.RoomId, db.RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	visibility, err := visibilityFromProto(req.Visibility)
+	if err != nil {
+		return nil, err
+	}
+	passwordHash, err := hashRoomPassword(req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	if err := s.Store.UpdateRoomAccess(ctx, room.ID, visibility, passwordHash); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, "room not found")
+		}
+		log.Printf("Failed to update room access: %v", err)
+		return nil, status.Error(codes.Internal, "failed to update room")
+	}
+	room.Visibility = visibility
+	room.PasswordHash = passwordHash
+	s.audit(ctx, &db.AuditEvent{
+		Action:  db.AuditRoomAccess,
+		RoomID:  room.ID,
+		Details: describeAccess(visibility, passwordHash != ""),
+	})
+
+	return &pb.SetRoomAccessResponse{}, nil
+}
+
+// CreateInvite issues an invite code for a room
+func (s *Server) CreateInvite(ctx context.Context, req *pb.CreateInviteRequest) (*pb.CreateInviteResponse, error) {
+	if req.TtlSeconds < 0 || req.MaxUses < 0 {
+		return nil, status.Error(codes.InvalidArgument, "ttl_seconds and max_uses cannot be negative")
+	}
+
+	room, _, err := s.requireRoomRole(ctx, req.RoomId, db.RoleModerator)
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := newCode()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to generate invite code")
+	}
+
+	now := time.Now()
+	invite := &db.RoomInvite{
+		CodeHash:  hashCode(code),
+		RoomID:    room.ID,
+		CreatedBy: callerID(ctx),
+		CreatedAt: now.Unix(),
+		MaxUses:   req.MaxUses,
+	}
+	if req.TtlSeconds > 0 {
+		invite.ExpiresAt = now.Add(time.Duration(req.TtlSeconds) * time.Second).Unix()
+	}
+	if err := s.Store.CreateRoomInvite(ctx, invite); err != nil {
+		log.Printf("Failed to store invite: %v", err)
+		return nil, status.Error(codes.Internal, "failed to create invite")
+	}
+	s.audit(ctx, &db.AuditEvent{
+		Action:  db.AuditRoomInvite,
+		RoomID:  room.ID,
+		Details: describeInvite(invite),
+	})
+
+	return &pb.CreateInviteResponse{
+		InviteCode: code,
+		ExpiresAt:  invite.ExpiresAt,
+	}, nil
+}
+
+// AcceptInvite redeems an invite code, making the caller a member of its room
+func (s *Server) AcceptInvite(ctx context.Context, req *pb.AcceptInviteRequest) (*pb.AcceptInviteResponse, error) {
+	userID := callerID(ctx)
+
+	invite, err := s.Store.UseRoomInvite(ctx, hashCode(req.InviteCode), time.Now().Unix())
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "invalid or expired invite code")
+		}
+		log.Printf("Failed to redeem invite: %v", err)
+		return nil, status.Error(codes.Internal, "failed to accept invite")
+	}
+	// the use still counts, so a banned user cannot probe an invite for free
+	if err := s.checkNotBanned(ctx, invite.RoomID, userID); err != nil {
+		return nil, err
+	}
+
+	s.Mutex.RLock()
+	room, exists := s.Rooms[invite.RoomID]
+	s.Mutex.RUnlock()
+	if !exists {
+		return nil, status.Error(codes.NotFound, "room not found")
+	}
+
+	if err := s.Store.AddMembership(ctx, room.ID, userID); err != nil {
+		log.Printf("Failed to record room membership: %v", err)
+		return nil, status.Error(codes.Internal, "failed to accept invite")
+	}
+	// an invite answers any pending request to join
+	if err := s.Store.DeleteJoinRequest(ctx, room.ID, userID); err != nil && !errors.Is(err, db.ErrNotFound) {
+		log.Printf("Failed to delete join request: %v", err)
+	}
+
+	return &pb.AcceptInviteResponse{
+		RoomId:   room.ID,
+		RoomName: room.Name,
+	}, nil
+}
+
+// RequestJoin asks the owner of a room to let the caller in
+func (s *Server) RequestJoin(ctx context.Context, req *pb.RequestJoinRequest) (*pb.RequestJoinResponse, error) {
+	userID := callerID(ctx)
+
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	user, exists := s.Users[userID]
+	if !exists {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	room, exists := s.Rooms[req.RoomId]
+	if !exists {
+		return nil, status.Error(codes.NotFound, "room not found")
+	}
+	if room.isOpen() {
+		return nil, status.Error(codes.FailedPrecondition, "room is open, join it directly")
+	}
+	if err := s.checkNotBanned(ctx, room.ID, userID); err != nil {
+		return nil, err
+	}
+
+	member, err := s.isMember(ctx, room, userID)
+	if err != nil {
+		return nil, err
+	}
+	if member {
+		return nil, status.Error(codes.AlreadyExists, "already a member of this room")
+	}
+
+	if err := s.Store.CreateJoinRequest(ctx, room.ID, userID, time.Now().Unix()); err != nil {
+		if errors.Is(err, db.ErrConflict) {
+			return nil, status.Error(codes.AlreadyExists, "join request already pending")
+		}
+		log.Printf("Failed to store join request: %v", err)
+		return nil, status.Error(codes.Internal, "failed to request to join")
+	}
+
+	s.notifyUser(room.CreatedBy, fmt.Sprintf("%s asked to join %s (room %d)", user.Username, room.Name, room.ID))
+	return &pb.RequestJoinResponse{}, nil
+}
+
+// ListJoinRequests returns the pending requests to join a room
+func (s *Server) ListJoinRequests(ctx context.Context, req *pb.ListJoinRequestsRequest) (*pb.ListJoinRequestsResponse, error) {
+	room, _, err := s.requireRoomRole(ctx, req.RoomId, db.RoleModerator)
+	if err != nil {
+		return nil, err
+	}
+
+	requests, err := s.Store.ListJoinRequests(ctx, room.ID)
+	if err != nil {
+		log.Printf("Failed to list join requests: %v", err)
+		return nil, status.Error(codes.Internal, "failed to list join requests")
+	}
+
+	resp := &pb.ListJoinRequestsResponse{
+		Requests: make([]*pb.JoinRequestInfo, 0, len(requests)),
+	}
+	for _, r := range requests {
+		resp.Requests = append(resp.Requests, &pb.JoinRequestInfo{
+			Username:    r.Username,
+			RequestedAt: r.RequestedAt,
+		})
+	}
+	return resp, nil
+}
+
+// RespondJoinRequest approves or denies a pending request to join a room
+func (s *Server) RespondJoinRequest(ctx context.Context, req *pb.RespondJoinRequestRequest) (*pb.RespondJoinRequestResponse, error) {
+	room, _, err := s.requireRoomRole(ctx, req.RoomId, db.RoleModerator)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.Store.GetUserByUsername(ctx, req.Username)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		log.Printf("Failed to load user: %v", err)
+		return nil, status.Error(codes.Internal, "failed to respond to join request")
+	}
+
+	if err := s.Store.DeleteJoinRequest(ctx, room.ID, user.ID); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, "no pending join request from that user")
+		}
+		log.Printf("Failed to delete join request: %v", err)
+		return nil, status.Error(codes.Internal, "failed to answer join request")
+	}
+
+	verdict, action := "denied", db.AuditJoinDeny
+	if req.Approve {
+		if err := s.Store.AddMembership(ctx, room.ID, user.ID); err != nil {
+			log.Printf("Failed to record room membership: %v", err)
+			return nil, status.Error(codes.Internal, "failed to answer join request")
+		}
+		verdict, action = "approved", db.AuditJoinApprove
+	}
+	s.audit(ctx, &db.AuditEvent{
+		Action:     action,
+		TargetID:   user.ID,
+		TargetName: user.Username,
+		RoomID:     room.ID,
+	})
+
+	s.Mutex.Lock()
+	s.notifyUser(user.ID, fmt.Sprintf("Your request to join %s (room %d) was %s", room.Name, room.ID, verdict))
+	s.Mutex.Unlock()
+
+	return &pb.RespondJoinRequestResponse{}, nil
+}
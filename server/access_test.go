@@ -0,0 +1,218 @@
+package server
+
+import (
+	"testing"
+
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAdmit(t *testing.T) {
+	s := newTestServer(t)
+	owner := addTestUser(t, s, "alice")
+	bob := addTestUser(t, s, "bob")
+	open := createTestRoom(t, s, owner, &pb.CreateRoomRequest{Name: "open"})
+	locked := createTestRoom(t, s, owner, &pb.CreateRoomRequest{Name: "locked", Password: "secret"})
+	private := createTestRoom(t, s, owner, &pb.CreateRoomRequest{Name: "private", Visibility: pb.RoomVisibility_ROOM_VISIBILITY_PRIVATE})
+
+	tests := []struct {
+		name     string
+		room     int32
+		user     int32
+		password string
+		code     codes.Code
+	}{
+		{name: "open room", room: open, user: bob, code: codes.OK},
+		{name: "no password", room: locked, user: bob, code: codes.PermissionDenied},
+		{name: "wrong password", room: locked, user: bob, password: "guess", code: codes.PermissionDenied},
+		{name: "right password", room: locked, user: bob, password: "secret", code: codes.OK},
+		{name: "owner needs no password", room: locked, user: owner, code: codes.OK},
+		{name: "private", room: private, user: bob, code: codes.PermissionDenied},
+		{name: "owner of private", room: private, user: owner, code: codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.admit(asUser(tt.user), s.Rooms[tt.room], tt.user, tt.password)
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("got code %v (%v), want %v", code, err, tt.code)
+			}
+		})
+	}
+}
+
+func TestJoinRequests(t *testing.T) {
+	s := newTestServer(t)
+	owner := addTestUser(t, s, "alice")
+	bob := addTestUser(t, s, "bob")
+	carol := addTestUser(t, s, "carol")
+	open := createTestRoom(t, s, owner, &pb.CreateRoomRequest{Name: "open"})
+	private := createTestRoom(t, s, owner, &pb.CreateRoomRequest{Name: "private", Visibility: pb.RoomVisibility_ROOM_VISIBILITY_PRIVATE})
+
+	// private rooms look missing to non-members
+	if err := s.checkReadAccess(asUser(bob), private); status.Code(err) != codes.NotFound {
+		t.Fatalf("got %v reading a private room, want NotFound", err)
+	}
+
+	steps := []struct {
+		name string
+		call func() error
+		code codes.Code
+	}{
+		{
+			name: "request to join open room",
+			call: func() error {
+				_, err := s.RequestJoin(asUser(bob), &pb.RequestJoinRequest{RoomId: open})
+				return err
+			},
+			code: codes.FailedPrecondition,
+		},
+		{
+			name: "request to join",
+			call: func() error {
+				_, err := s.RequestJoin(asUser(bob), &pb.RequestJoinRequest{RoomId: private})
+				return err
+			},
+			code: codes.OK,
+		},
+		{
+			name: "request twice",
+			call: func() error {
+				_, err := s.RequestJoin(asUser(bob), &pb.RequestJoinRequest{RoomId: private})
+				return err
+			},
+			code: codes.AlreadyExists,
+		},
+		{
+			name: "list as non-member",
+			call: func() error {
+				_, err := s.ListJoinRequests(asUser(carol), &pb.ListJoinRequestsRequest{RoomId: private})
+				return err
+			},
+			code: codes.PermissionDenied,
+		},
+		{
+			name: "respond as non-member",
+			call: func() error {
+				_, err := s.RespondJoinRequest(asUser(carol), &pb.RespondJoinRequestRequest{RoomId: private, Username: "bob", Approve: true})
+				return err
+			},
+			code: codes.PermissionDenied,
+		},
+		{
+			name: "respond without request",
+			call: func() error {
+				_, err := s.RespondJoinRequest(asUser(owner), &pb.RespondJoinRequestRequest{RoomId: private, Username: "carol", Approve: true})
+				return err
+			},
+			code: codes.NotFound,
+		},
+		{
+			name: "approve",
+			call: func() error {
+				_, err := s.RespondJoinRequest(asUser(owner), &pb.RespondJoinRequestRequest{RoomId: private, Username: "bob", Approve: true})
+				return err
+			},
+			code: codes.OK,
+		},
+		{
+			name: "request as member",
+			call: func() error {
+				_, err := s.RequestJoin(asUser(bob), &pb.RequestJoinRequest{RoomId: private})
+				return err
+			},
+			code: codes.AlreadyExists,
+		},
+		{
+			name: "request to be denied",
+			call: func() error {
+				_, err := s.RequestJoin(asUser(carol), &pb.RequestJoinRequest{RoomId: private})
+				return err
+			},
+			code: codes.OK,
+		},
+		{
+			name: "deny",
+			call: func() error {
+				_, err := s.RespondJoinRequest(asUser(owner), &pb.RespondJoinRequestRequest{RoomId: private, Username: "carol"})
+				return err
+			},
+			code: codes.OK,
+		},
+	}
+	for _, step := range steps {
+		if code := status.Code(step.call()); code != step.code {
+			t.Fatalf("%s: got code %v, want %v", step.name, code, step.code)
+		}
+	}
+
+	requests, err := s.ListJoinRequests(asUser(owner), &pb.ListJoinRequestsRequest{RoomId: private})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests.Requests) != 0 {
+		t.Errorf("got %d pending requests, want none", len(requests.Requests))
+	}
+	if err := s.checkReadAccess(asUser(bob), private); err != nil {
+		t.Errorf("approved user cannot read the room: %v", err)
+	}
+	if err := s.checkReadAccess(asUser(carol), private); status.Code(err) != codes.NotFound {
+		t.Errorf("got %v for a denied user, want NotFound", err)
+	}
+}
+
+func TestInvites(t *testing.T) {
+	s := newTestServer(t)
+	owner := addTestUser(t, s, "alice")
+	bob := addTestUser(t, s, "bob")
+	carol := addTestUser(t, s, "carol")
+	private := createTestRoom(t, s, owner, &pb.CreateRoomRequest{Name: "private", Visibility: pb.RoomVisibility_ROOM_VISIBILITY_PRIVATE})
+
+	createTests := []struct {
+		name string
+		user int32
+		req  *pb.CreateInviteRequest
+		code codes.Code
+	}{
+		{name: "negative ttl", user: owner, req: &pb.CreateInviteRequest{RoomId: private, TtlSeconds: -1}, code: codes.InvalidArgument},
+		{name: "negative uses", user: owner, req: &pb.CreateInviteRequest{RoomId: private, MaxUses: -1}, code: codes.InvalidArgument},
+		{name: "not a moderator", user: bob, req: &pb.CreateInviteRequest{RoomId: private}, code: codes.PermissionDenied},
+		{name: "unknown room", user: owner, req: &pb.CreateInviteRequest{RoomId: private + 1}, code: codes.NotFound},
+	}
+	for _, tt := range createTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.CreateInvite(asUser(tt.user), tt.req)
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("got code %v (%v), want %v", code, err, tt.code)
+			}
+		})
+	}
+
+	invite, err := s.CreateInvite(asUser(owner), &pb.CreateInviteRequest{RoomId: private, TtlSeconds: 60, MaxUses: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invite.ExpiresAt == 0 {
+		t.Error("invite with a ttl has no expiry")
+	}
+
+	resp, err := s.AcceptInvite(asUser(bob), &pb.AcceptInviteRequest{InviteCode: invite.InviteCode})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.RoomId != private || resp.RoomName != "private" {
+		t.Errorf("got room %d %q, want %d \"private\"", resp.RoomId, resp.RoomName, private)
+	}
+	if err := s.checkReadAccess(asUser(bob), private); err != nil {
+		t.Errorf("invited user cannot read the room: %v", err)
+	}
+
+	// the single use is spent
+	if _, err := s.AcceptInvite(asUser(carol), &pb.AcceptInviteRequest{InviteCode: invite.InviteCode}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("got %v reusing the invite, want InvalidArgument", err)
+	}
+	if _, err := s.AcceptInvite(asUser(carol), &pb.AcceptInviteRequest{InviteCode: "bogus"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("got %v for a bogus code, want InvalidArgument", err)
+	}
+}
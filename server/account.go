@@ -0,0 +1,389 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ayushsarode/termiXchat/db"
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// deletedUsernamePrefix starts the placeholder username of deleted accounts,
+// so nobody can register or switch to such a name
+const deletedUsernamePrefix = "deleted-"
+
+// exportChunkSize is the most archive bytes sent in one ExportMyData chunk
+const exportChunkSize = 64 << 10
+
+// isReservedUsername reports whether a username is kept for deleted accounts
+func isReservedUsername(username string) bool {
+	return strings.HasPrefix(username, deletedUsernamePrefix)
+}
+
+// DeleteAccount anonymizes the caller's account after checking their
+// password, disconnecting them from every room
+func (s *Server) DeleteAccount(ctx context.Context, req *pb.DeleteAccountRequest) (*pb.DeleteAccountResponse, error) {
+	userID := callerID(ctx)
+	stored, err := s.Store.GetUser(ctx, userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		log.Printf("Failed to load user: %v", err)
+		return nil, status.Error(codes.Internal, "failed to delete account")
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(stored.PasswordHash), []byte(req.Password)) != nil {
+		return nil, status.Error(codes.PermissionDenied, "password is incorrect")
+	}
+
+	placeholder := fmt.Sprintf("%s%d", deletedUsernamePrefix, userID)
+	owners, err := s.Store.DeleteUser(ctx, userID, placeholder, req.DeleteMessages)
+	if err != nil {
+		log.Printf("Failed to delete user: %v", err)
+		return nil, status.Error(codes.Internal, "failed to delete account")
+	}
+	if err := s.Store.ClearLoginFailure(ctx, LoginSubject(stored.Username)); err != nil {
+		log.Printf("Failed to clear login failures: %v", err)
+	}
+	// the log keeps no trace of the old username
+	s.audit(ctx, &db.AuditEvent{
+		Action:     db.AuditDeleteAccount,
+		ActorName:  placeholder,
+		TargetID:   userID,
+		TargetName: placeholder,
+	})
+	ownerNames := make(map[int32]string, len(owners))
+	for roomID, ownerID := range owners {
+		if ownerID == 0 {
+			continue
+		}
+		owner, err := s.Store.GetUser(ctx, ownerID)
+		if err != nil {
+			log.Printf("Failed to load new room owner: %v", err)
+			continue
+		}
+		ownerNames[roomID] = owner.Username
+		s.audit(ctx, &db.AuditEvent{
+			Action:     db.AuditRole,
+			ActorName:  placeholder,
+			TargetID:   ownerID,
+			TargetName: owner.Username,
+			RoomID:     roomID,
+			Details:    db.RoleOwner,
+		})
+	}
+
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	delete(s.Users, userID)
+	for _, room := range s.Rooms {
+		if _, ok := room.Users[userID]; !ok {
+			continue
+		}
+		s.evict(room, userID, status.Error(codes.Unauthenticated, "your account was deleted"))
+		s.broadcastSystemMessage(ctx, room, fmt.Sprintf("%s deleted their account", stored.Username))
+	}
+	// rooms the account created go to their most senior remaining member
+	for roomID, ownerID := range owners {
+		room, ok := s.Rooms[roomID]
+		if !ok {
+			continue
+		}
+		room.CreatedBy = ownerID
+		if name, ok := ownerNames[roomID]; ok {
+			s.broadcastSystemMessage(ctx, room, fmt.Sprintf("%s now owns the room", name))
+		}
+	}
+
+	log.Printf("%s deleted their account", stored.Username)
+	return &pb.DeleteAccountResponse{}, nil
+}
+
+// dataArchive is the JSON document returned by ExportMyData
+type dataArchive struct {
+	ExportedAt    string                `json:"exported_at"`
+	Profile       archiveProfile        `json:"profile"`
+	Memberships   []archiveMembership   `json:"memberships"`
+	Messages      []archiveMessage      `json:"messages"`
+	Reactions     []archiveReaction     `json:"reactions"`
+	Conversations []archiveConversation `json:"conversations"`
+	JoinRequests  []archiveJoinRequest  `json:"join_requests"`
+	Restrictions  []archiveRestriction  `json:"restrictions"`
+}
+
+type archiveProfile struct {
+	ID       int32  `json:"id"`
+	Username string `json:"username"`
+	IsAdmin  bool   `json:"is_admin"`
+	// PublicKey is nil unless the user published an encryption key
+	PublicKey *archivePublicKey `json:"public_key,omitempty"`
+}
+
+type archivePublicKey struct {
+	// Key is encoded as base64
+	Key         []byte `json:"key"`
+	PublishedAt string `json:"published_at"`
+}
+
+type archiveMembership struct {
+	RoomID   int32  `json:"room_id"`
+	RoomName string `json:"room_name"`
+	Role     string `json:"role"`
+	JoinedAt string `json:"joined_at"`
+	// LastReadID is the newest message read in the room, zero for none
+	LastReadID int32 `json:"last_read_id"`
+}
+
+type archiveReaction struct {
+	MessageID int32  `json:"message_id"`
+	RoomID    int32  `json:"room_id"`
+	RoomName  string `json:"room_name"`
+	Emoji     string `json:"emoji"`
+	CreatedAt string `json:"created_at"`
+}
+
+type archiveJoinRequest struct {
+	RoomID      int32  `json:"room_id"`
+	RoomName    string `json:"room_name"`
+	RequestedAt string `json:"requested_at"`
+}
+
+type archiveRestriction struct {
+	RoomID    int32  `json:"room_id"`
+	RoomName  string `json:"room_name"`
+	Kind      string `json:"kind"`
+	Reason    string `json:"reason,omitempty"`
+	CreatedAt string `json:"created_at"`
+	// ExpiresAt is left out for restrictions that last until lifted
+	ExpiresAt string `json:"expires_at,omitempty"`
+}
+
+type archiveMessage struct {
+	ID        int32  `json:"id"`
+	RoomID    int32  `json:"room_id,omitempty"`
+	RoomName  string `json:"room_name,omitempty"`
+	From      string `json:"from,omitempty"`
+	Message   string `json:"message"`
+	Timestamp string `json:"timestamp"`
+	// Encrypted messages are base64 ciphertext the server cannot decrypt
+	Encrypted bool `json:"encrypted,omitempty"`
+}
+
+type archiveConversation struct {
+	Peer      string           `json:"peer"`
+	CreatedAt string           `json:"created_at"`
+	Messages  []archiveMessage `json:"messages"`
+}
+
+// archiveTime formats a Unix time for the archive
+func archiveTime(t int64) string {
+	return time.Unix(t, 0).UTC().Format(time.RFC3339)
+}
+
+// ExportMyData streams a JSON archive of the caller's profile and public
+// key, room memberships and read positions, room messages, reactions,
+// direct message conversations, join requests, bans and mutes
+func (s *Server) ExportMyData(req *pb.ExportMyDataRequest, stream pb.ChatService_ExportMyDataServer) error {
+	ctx := stream.Context()
+	userID := callerID(ctx)
+
+	archive, err := s.buildDataArchive(ctx, userID)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		log.Printf("Failed to encode data archive: %v", err)
+		return status.Error(codes.Internal, "failed to export data")
+	}
+
+	for len(data) > 0 {
+		n := min(len(data), exportChunkSize)
+		if err := stream.Send(&pb.ExportMyDataChunk{Data: data[:n]}); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+// buildDataArchive collects everything stored about the user
+func (s *Server) buildDataArchive(ctx context.Context, userID int32) (*dataArchive, error) {
+	user, err := s.Store.GetUser(ctx, userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		log.Printf("Failed to load user: %v", err)
+		return nil, status.Error(codes.Internal, "failed to export data")
+	}
+
+	archive := &dataArchive{
+		ExportedAt: archiveTime(time.Now().Unix()),
+		Profile: archiveProfile{
+			ID:       user.ID,
+			Username: user.Username,
+			IsAdmin:  user.IsAdmin,
+		},
+		Memberships:   []archiveMembership{},
+		Messages:      []archiveMessage{},
+		Reactions:     []archiveReaction{},
+		Conversations: []archiveConversation{},
+		JoinRequests:  []archiveJoinRequest{},
+		Restrictions:  []archiveRestriction{},
+	}
+
+	key, err := s.Store.GetPublicKey(ctx, userID)
+	switch {
+	case err == nil:
+		archive.Profile.PublicKey = &archivePublicKey{Key: key.Key, PublishedAt: archiveTime(key.PublishedAt)}
+	case !errors.Is(err, db.ErrNotFound):
+		log.Printf("Failed to load public key: %v", err)
+		return nil, status.Error(codes.Internal, "failed to export data")
+	}
+
+	// every room is loaded in memory, so names need no extra queries
+	roomNames := make(map[int32]string)
+	s.Mutex.RLock()
+	for id, room := range s.Rooms {
+		roomNames[id] = room.Name
+	}
+	s.Mutex.RUnlock()
+
+	memberships, err := s.Store.ListMemberships(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to list memberships: %v", err)
+		return nil, status.Error(codes.Internal, "failed to export data")
+	}
+	for _, m := range memberships {
+		archive.Memberships = append(archive.Memberships, archiveMembership{
+			RoomID:     m.RoomID,
+			RoomName:   roomNames[m.RoomID],
+			Role:       m.Role,
+			JoinedAt:   archiveTime(m.JoinedAt),
+			LastReadID: m.LastReadID,
+		})
+	}
+
+	var afterID int32
+	for {
+		messages, hasMore, err := s.Store.UserMessages(ctx, userID, afterID, exportPageSize)
+		if err != nil {
+			log.Printf("Failed to load user messages: %v", err)
+			return nil, status.Error(codes.Internal, "failed to export data")
+		}
+		for _, msg := range messages {
+			archive.Messages = append(archive.Messages, archiveMessage{
+				ID:        msg.ID,
+				RoomID:    msg.RoomID,
+				RoomName:  roomNames[msg.RoomID],
+				Message:   msg.Content,
+				Timestamp: archiveTime(msg.Timestamp),
+			})
+			afterID = msg.ID
+		}
+		if !hasMore {
+			break
+		}
+	}
+
+	reactions, err := s.Store.UserReactions(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to load user reactions: %v", err)
+		return nil, status.Error(codes.Internal, "failed to export data")
+	}
+	for _, r := range reactions {
+		archive.Reactions = append(archive.Reactions, archiveReaction{
+			MessageID: r.MessageID,
+			RoomID:    r.RoomID,
+			RoomName:  roomNames[r.RoomID],
+			Emoji:     r.Emoji,
+			CreatedAt: archiveTime(r.CreatedAt),
+		})
+	}
+
+	conversations, err := s.Store.ListConversations(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to list conversations: %v", err)
+		return nil, status.Error(codes.Internal, "failed to export data")
+	}
+	for _, c := range conversations {
+		conversation := archiveConversation{
+			Peer:      c.PeerUsername,
+			CreatedAt: archiveTime(c.CreatedAt),
+		}
+
+		// history pages backwards, so pages are prepended
+		var beforeID int32
+		for {
+			messages, hasMore, err := s.Store.ConversationHistory(ctx, c.ID, beforeID, exportPageSize)
+			if err != nil {
+				log.Printf("Failed to load conversation history: %v", err)
+				return nil, status.Error(codes.Internal, "failed to export data")
+			}
+			page := make([]archiveMessage, 0, len(messages)+len(conversation.Messages))
+			for _, msg := range messages {
+				page = append(page, archiveMessage{
+					ID:        msg.ID,
+					From:      msg.Username,
+					Message:   msg.Content,
+					Timestamp: archiveTime(msg.Timestamp),
+					Encrypted: msg.Encrypted,
+				})
+			}
+			conversation.Messages = append(page, conversation.Messages...)
+			if !hasMore || len(messages) == 0 {
+				break
+			}
+			beforeID = messages[0].ID
+		}
+		if conversation.Messages == nil {
+			conversation.Messages = []archiveMessage{}
+		}
+		archive.Conversations = append(archive.Conversations, conversation)
+	}
+
+	requests, err := s.Store.UserJoinRequests(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to list join requests: %v", err)
+		return nil, status.Error(codes.Internal, "failed to export data")
+	}
+	for _, r := range requests {
+		archive.JoinRequests = append(archive.JoinRequests, archiveJoinRequest{
+			RoomID:      r.RoomID,
+			RoomName:    roomNames[r.RoomID],
+			RequestedAt: archiveTime(r.RequestedAt),
+		})
+	}
+
+	restrictions, err := s.Store.UserRestrictions(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to list restrictions: %v", err)
+		return nil, status.Error(codes.Internal, "failed to export data")
+	}
+	for _, r := range restrictions {
+		restriction := archiveRestriction{
+			RoomID:    r.RoomID,
+			RoomName:  roomNames[r.RoomID],
+			Kind:      r.Kind,
+			Reason:    r.Reason,
+			CreatedAt: archiveTime(r.CreatedAt),
+		}
+		if r.ExpiresAt != 0 {
+			restriction.ExpiresAt = archiveTime(r.ExpiresAt)
+		}
+		archive.Restrictions = append(archive.Restrictions, restriction)
+	}
+
+	return archive, nil
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ayushsarode/termiXchat/db"
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// addTestAccount signs a user up with a real password hash
+func addTestAccount(t *testing.T, s *Server, username, password string) int32 {
+	t.Helper()
+	resp, err := s.CreateUser(context.Background(), &pb.CreateUserRequest{Username: username, Password: password})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.UserId
+}
+
+func TestDeleteAccount(t *testing.T) {
+	tests := []struct {
+		name           string
+		deleteMessages bool
+		history        []string
+	}{
+		{name: "keep messages", history: []string{"hello from alice", "hello from bob"}},
+		{name: "delete messages", deleteMessages: true, history: []string{"hello from bob"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			alice := addTestAccount(t, s, "alice", "password")
+			bob := addTestUser(t, s, "bob")
+			room := createTestRoom(t, s, alice, &pb.CreateRoomRequest{Name: "general"})
+			_, done := joinTestRoom(t, s, alice, room)
+			bobStream, _ := joinTestRoom(t, s, bob, room)
+			for _, user := range []int32{alice, bob} {
+				text := "hello from " + s.Users[user].Username
+				if _, err := s.SendMessage(asUser(user), &pb.SendMessageRequest{RoomId: room, Message: text}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, err := s.DeleteAccount(asUser(alice), &pb.DeleteAccountRequest{Password: "wrong"})
+			if status.Code(err) != codes.PermissionDenied {
+				t.Fatalf("got %v with the wrong password, want PermissionDenied", err)
+			}
+
+			if _, err := s.DeleteAccount(asUser(alice), &pb.DeleteAccountRequest{Password: "password", DeleteMessages: tt.deleteMessages}); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case err := <-done:
+				if status.Code(err) != codes.Unauthenticated {
+					t.Errorf("got %v ending the stream, want Unauthenticated", err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("deleting the account did not end the stream")
+			}
+			if _, ok := s.Users[alice]; ok {
+				t.Error("deleted user is still logged in")
+			}
+
+			// the room passes to the remaining member
+			if owner := s.Rooms[room].CreatedBy; owner != bob {
+				t.Errorf("room owned by %d, want %d", owner, bob)
+			}
+			notices := bobStream.messages()
+			if last := notices[len(notices)-1]; last != "bob now owns the room" {
+				t.Errorf("got last notice %q, want the new owner", last)
+			}
+
+			history, err := s.GetMessageHistory(asUser(bob), &pb.GetMessageHistoryRequest{RoomId: room})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, msg := range history.Messages {
+				if !msg.IsSystem {
+					got = append(got, msg.Message)
+					if msg.Message == "hello from alice" && msg.Username == "alice" {
+						t.Error("kept message still shows the old username")
+					}
+				}
+			}
+			if len(got) != len(tt.history) {
+				t.Fatalf("got history %q, want %q", got, tt.history)
+			}
+			for i := range got {
+				if got[i] != tt.history[i] {
+					t.Errorf("got history %q, want %q", got, tt.history)
+				}
+			}
+
+			// the username is free again
+			if _, err := s.CreateUser(context.Background(), &pb.CreateUserRequest{Username: "alice", Password: "password"}); err != nil {
+				t.Errorf("cannot register the freed username: %v", err)
+			}
+		})
+	}
+}
+
+func TestBuildDataArchive(t *testing.T) {
+	s := newTestServer(t)
+	alice := addTestUser(t, s, "alice")
+	bob := addTestUser(t, s, "bob")
+	addTestUser(t, s, "carol")
+	general := createTestRoom(t, s, alice, &pb.CreateRoomRequest{Name: "general"})
+	quiet := createTestRoom(t, s, bob, &pb.CreateRoomRequest{Name: "quiet"})
+	private := createTestRoom(t, s, bob, &pb.CreateRoomRequest{Name: "private", Visibility: pb.RoomVisibility_ROOM_VISIBILITY_PRIVATE})
+	joinTestRoom(t, s, alice, general)
+	joinTestRoom(t, s, alice, quiet)
+
+	key := bytes.Repeat([]byte{7}, 32)
+	if _, err := s.PublishKey(asUser(alice), &pb.PublishKeyRequest{PublicKey: key}); err != nil {
+		t.Fatal(err)
+	}
+	sent, err := s.SendMessage(asUser(alice), &pb.SendMessageRequest{RoomId: general, Message: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.MarkRead(asUser(alice), &pb.MarkReadRequest{RoomId: general, MessageId: sent.MessageId}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddReaction(asUser(alice), &pb.AddReactionRequest{MessageId: sent.MessageId, Emoji: "👍"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SendDirectMessage(asUser(alice), &pb.SendDirectMessageRequest{RecipientUsername: "carol", Message: "psst"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RequestJoin(asUser(alice), &pb.RequestJoinRequest{RoomId: private}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.MuteUser(asUser(bob), &pb.MuteUserRequest{RoomId: quiet, Username: "alice", DurationSeconds: 60}); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := s.buildDataArchive(context.Background(), alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if archive.Profile.Username != "alice" || archive.Profile.PublicKey == nil || !bytes.Equal(archive.Profile.PublicKey.Key, key) {
+		t.Errorf("got profile %+v, want alice with the published key", archive.Profile)
+	}
+	if len(archive.Memberships) != 2 {
+		t.Errorf("got %d memberships, want 2", len(archive.Memberships))
+	}
+	for _, m := range archive.Memberships {
+		if m.RoomID == general && m.LastReadID != sent.MessageId {
+			t.Errorf("got read position %d in general, want %d", m.LastReadID, sent.MessageId)
+		}
+	}
+	if len(archive.Messages) != 1 || archive.Messages[0].Message != "hello" || archive.Messages[0].RoomName != "general" {
+		t.Errorf("got messages %+v, want hello in general", archive.Messages)
+	}
+	if len(archive.Reactions) != 1 || archive.Reactions[0].MessageID != sent.MessageId || archive.Reactions[0].Emoji != "👍" {
+		t.Errorf("got reactions %+v, want 👍 on message %d", archive.Reactions, sent.MessageId)
+	}
+	if len(archive.Conversations) != 1 || archive.Conversations[0].Peer != "carol" || len(archive.Conversations[0].Messages) != 1 {
+		t.Errorf("got conversations %+v, want one message to carol", archive.Conversations)
+	}
+	if len(archive.JoinRequests) != 1 || archive.JoinRequests[0].RoomName != "private" {
+		t.Errorf("got join requests %+v, want one for private", archive.JoinRequests)
+	}
+	if len(archive.Restrictions) != 1 || archive.Restrictions[0].Kind != db.RestrictionMute || archive.Restrictions[0].ExpiresAt == "" {
+		t.Errorf("got restrictions %+v, want a timed mute", archive.Restrictions)
+	}
+
+	// nothing about anyone else leaks into the archive
+	other, err := s.buildDataArchive(context.Background(), bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(other.Messages) != 0 || len(other.Reactions) != 0 || len(other.Conversations) != 0 || len(other.Restrictions) != 0 {
+		t.Errorf("got %+v for bob, want nothing of alice's", other)
+	}
+}
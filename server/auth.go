@@ -0,0 +1,259 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ayushsarode/termiXchat/db"
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// defaultSessionTTL is how long a session token stays valid when
+// SESSION_TTL is not set
+const defaultSessionTTL = 24 * time.Hour
+
+// AuthConfig configures the session tokens issued on login
+type AuthConfig struct {
+	// Secret signs session tokens, changing it invalidates every token
+	Secret []byte
+	// SessionTTL is how long a token stays valid after it is issued
+	SessionTTL time.Duration
+}
+
+// AuthConfigFromEnv builds an AuthConfig from AUTH_SECRET and SESSION_TTL.
+// Without AUTH_SECRET a random secret is generated, so sessions do not
+// survive a restart.
+func AuthConfigFromEnv() (AuthConfig, error) {
+	cfg := AuthConfig{
+		Secret:     []byte(os.Getenv("AUTH_SECRET")),
+		SessionTTL: defaultSessionTTL,
+	}
+
+	if ttl := os.Getenv("SESSION_TTL"); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d <= 0 {
+			return AuthConfig{}, fmt.Errorf("invalid SESSION_TTL %q", ttl)
+		}
+		cfg.SessionTTL = d
+	}
+
+	if len(cfg.Secret) == 0 {
+		log.Println("AUTH_SECRET is not set, using a random secret. Sessions will not survive a restart.")
+		cfg.Secret = make([]byte, 32)
+		if _, err := rand.Read(cfg.Secret); err != nil {
+			return AuthConfig{}, fmt.Errorf("failed to generate auth secret: %v", err)
+		}
+	}
+
+	return cfg, nil
+}
+
+// publicMethods can be called without a session token
+var publicMethods = map[string]bool{
+	pb.ChatService_CreateUser_FullMethodName: true,
+	pb.ChatService_LoginUser_FullMethodName:  true,
+	// redeeming a reset code is how a locked out user gets back in
+	pb.ChatService_CompletePasswordReset_FullMethodName: true,
+}
+
+type callerKey struct{}
+
+// callerID returns the ID of the authenticated user making the call
+func callerID(ctx context.Context) int32 {
+	session, _ := ctx.Value(callerKey{}).(*db.Session)
+	if session == nil {
+		return 0
+	}
+	return session.UserID
+}
+
+// callerSessionID returns the ID of the session the call was made with
+func callerSessionID(ctx context.Context) string {
+	session, _ := ctx.Value(callerKey{}).(*db.Session)
+	if session == nil {
+		return ""
+	}
+	return session.ID
+}
+
+// issueToken starts a session for the user and returns its signed token
+// along with the token's expiry
+func (s *Server) issueToken(ctx context.Context, userID int32) (string, int64, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", 0, err
+	}
+
+	now := time.Now()
+	session := &db.Session{
+		ID:        hex.EncodeToString(id),
+		UserID:    userID,
+		CreatedAt: now.Unix(),
+		ExpiresAt: now.Add(s.Auth.SessionTTL).Unix(),
+	}
+	if err := s.Store.CreateSession(ctx, session); err != nil {
+		return "", 0, err
+	}
+
+	// the payload is "<session ID>:<user ID>:<expiry>", followed by its
+	// HMAC so tampered tokens are rejected without a database lookup
+	payload := fmt.Sprintf("%s:%d:%d", session.ID, session.UserID, session.ExpiresAt)
+	token := base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(s.sign(payload))
+	return token, session.ExpiresAt, nil
+}
+
+func (s *Server) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, s.Auth.Secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+var errInvalidToken = status.Error(codes.Unauthenticated, "invalid session token")
+
+// authenticate verifies the bearer token in the request metadata and
+// returns the session it belongs to
+func (s *Server) authenticate(ctx context.Context) (*db.Session, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "missing session token")
+	}
+	token, ok := strings.CutPrefix(values[0], "Bearer ")
+	if !ok {
+		return nil, errInvalidToken
+	}
+
+	encodedPayload, encodedSig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, errInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, errInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encodedSig)
+	if err != nil || !hmac.Equal(sig, s.sign(string(payload))) {
+		return nil, errInvalidToken
+	}
+
+	fields := strings.Split(string(payload), ":")
+	if len(fields) != 3 {
+		return nil, errInvalidToken
+	}
+	userID, err := strconv.ParseInt(fields[1], 10, 32)
+	if err != nil {
+		return nil, errInvalidToken
+	}
+	expiresAt, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, errInvalidToken
+	}
+	if time.Now().Unix() >= expiresAt {
+		return nil, status.Error(codes.Unauthenticated, "session expired")
+	}
+
+	// the session must still exist, which lets the server revoke tokens
+	session, err := s.Store.GetSession(ctx, fields[0])
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, status.Error(codes.Unauthenticated, "session revoked")
+		}
+		log.Printf("Failed to load session: %v", err)
+		return nil, status.Error(codes.Internal, "failed to verify session")
+	}
+	if session.UserID != int32(userID) {
+		return nil, errInvalidToken
+	}
+
+	if err := s.cacheUser(ctx, session.UserID); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// cacheUser makes sure an authenticated user is in the in-memory user map,
+// which is empty after a restart even though tokens remain valid
+func (s *Server) cacheUser(ctx context.Context, userID int32) error {
+	s.Mutex.RLock()
+	_, cached := s.Users[userID]
+	s.Mutex.RUnlock()
+	if cached {
+		return nil
+	}
+
+	stored, err := s.Store.GetUser(ctx, userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return status.Error(codes.Unauthenticated, "user no longer exists")
+		}
+		log.Printf("Failed to load user: %v", err)
+		return status.Error(codes.Internal, "failed to verify session")
+	}
+
+	s.Mutex.Lock()
+	if _, exists := s.Users[userID]; !exists {
+		s.Users[userID] = &User{
+			ID:       stored.ID,
+			Username: stored.Username,
+			Password: stored.PasswordHash,
+		}
+	}
+	s.Mutex.Unlock()
+	return nil
+}
+
+// UnaryAuthInterceptor rejects unary calls without a valid session token and
+// passes the caller's identity on to the handler
+func (s *Server) UnaryAuthInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if publicMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+
+	session, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(context.WithValue(ctx, callerKey{}, session), req)
+}
+
+// authenticatedStream overrides the stream context to carry the caller
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// StreamAuthInterceptor is the streaming counterpart of UnaryAuthInterceptor
+func (s *Server) StreamAuthInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if publicMethods[info.FullMethod] {
+		return handler(srv, ss)
+	}
+
+	session, err := s.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{
+		ServerStream: ss,
+		ctx:          context.WithValue(ss.Context(), callerKey{}, session),
+	})
+}
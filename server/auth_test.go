@@ -0,0 +1,294 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ayushsarode/termiXchat/db"
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// newTestServer returns a server on an empty in-memory store
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	store, err := db.Open(db.Config{Driver: "memory"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	s, err := NewServer(store, AuthConfig{Secret: []byte("test secret"), SessionTTL: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// withToken returns an incoming context carrying an authorization header
+func withToken(header string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", header))
+}
+
+// asUser returns a context authenticated as the user, as the interceptor
+// leaves it for handlers
+func asUser(userID int32) context.Context {
+	return context.WithValue(context.Background(), callerKey{}, &db.Session{ID: "test", UserID: userID})
+}
+
+// addTestUser stores a user and registers them with the server as logged in
+func addTestUser(t *testing.T, s *Server, username string) int32 {
+	t.Helper()
+	user, err := s.Store.CreateUser(context.Background(), username, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Users[user.ID] = &User{ID: user.ID, Username: user.Username, Password: user.PasswordHash}
+	return user.ID
+}
+
+// createTestRoom creates a room owned by the user
+func createTestRoom(t *testing.T, s *Server, ownerID int32, req *pb.CreateRoomRequest) int32 {
+	t.Helper()
+	resp, err := s.CreateRoom(asUser(ownerID), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.RoomId
+}
+
+// testJoinStream is a JoinRoom stream that records what the server sends
+type testJoinStream struct {
+	grpc.ServerStream
+	ctx context.Context
+
+	mu   sync.Mutex
+	sent []*pb.ReceiveMessageResponse
+}
+
+func (st *testJoinStream) Context() context.Context {
+	return st.ctx
+}
+
+func (st *testJoinStream) Send(msg *pb.ReceiveMessageResponse) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.sent = append(st.sent, msg)
+	return nil
+}
+
+// messages returns the text of everything sent on the stream so far
+func (st *testJoinStream) messages() []string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	texts := make([]string, 0, len(st.sent))
+	for _, msg := range st.sent {
+		texts = append(texts, msg.Message)
+	}
+	return texts
+}
+
+// last returns the newest message sent on the stream
+func (st *testJoinStream) last() *pb.ReceiveMessageResponse {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if len(st.sent) == 0 {
+		return nil
+	}
+	return st.sent[len(st.sent)-1]
+}
+
+// joinTestRoom joins the user to the room on a stream of its own and waits
+// until they are in it. The returned channel gets JoinRoom's result once the
+// stream ends, which the test cleanup forces if nothing else does.
+func joinTestRoom(t *testing.T, s *Server, userID, roomID int32) (*testJoinStream, <-chan error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(asUser(userID))
+	stream := &testJoinStream{ctx: ctx}
+	done := make(chan error, 1)
+	go func() {
+		done <- s.JoinRoom(&pb.JoinRoomRequest{RoomId: roomID}, stream)
+	}()
+	t.Cleanup(cancel)
+
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		s.Mutex.RLock()
+		_, joined := s.Rooms[roomID].Users[userID]
+		s.Mutex.RUnlock()
+		if joined {
+			return stream, done
+		}
+		select {
+		case err := <-done:
+			t.Fatalf("join room: %v", err)
+		default:
+		}
+	}
+	t.Fatal("timed out joining the room")
+	return nil, nil
+}
+
+// signedToken builds a token for any payload, signed with the server secret
+func signedToken(s *Server, payload string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(s.sign(payload))
+}
+
+func TestAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	user, err := s.Store.CreateUser(ctx, "alice", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := s.Store.CreateUser(ctx, "bob", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, expiresAt, err := s.issueToken(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encodedPayload, encodedSig, _ := strings.Cut(token, ".")
+	payload, _ := base64.RawURLEncoding.DecodeString(encodedPayload)
+	sessionID := strings.Split(string(payload), ":")[0]
+
+	revoked, _, err := s.issueToken(ctx, other.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Store.DeleteSessions(ctx, other.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	foreign := &Server{Auth: AuthConfig{Secret: []byte("another secret")}}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		code codes.Code
+		msg  string
+	}{
+		{
+			name: "valid",
+			ctx:  withToken("Bearer " + token),
+			code: codes.OK,
+		},
+		{
+			name: "no metadata",
+			ctx:  ctx,
+			code: codes.Unauthenticated,
+			msg:  "missing session token",
+		},
+		{
+			name: "not a bearer token",
+			ctx:  withToken(token),
+			code: codes.Unauthenticated,
+			msg:  "invalid session token",
+		},
+		{
+			name: "no signature",
+			ctx:  withToken("Bearer " + encodedPayload),
+			code: codes.Unauthenticated,
+			msg:  "invalid session token",
+		},
+		{
+			name: "payload not base64",
+			ctx:  withToken("Bearer !!." + encodedSig),
+			code: codes.Unauthenticated,
+			msg:  "invalid session token",
+		},
+		{
+			name: "tampered payload",
+			ctx: withToken("Bearer " + base64.RawURLEncoding.EncodeToString(
+				[]byte(fmt.Sprintf("%s:%d:%d", sessionID, other.ID, expiresAt))) + "." + encodedSig),
+			code: codes.Unauthenticated,
+			msg:  "invalid session token",
+		},
+		{
+			name: "signed with another secret",
+			ctx:  withToken("Bearer " + signedToken(foreign, string(payload))),
+			code: codes.Unauthenticated,
+			msg:  "invalid session token",
+		},
+		{
+			name: "missing fields",
+			ctx:  withToken("Bearer " + signedToken(s, fmt.Sprintf("%s:%d", sessionID, user.ID))),
+			code: codes.Unauthenticated,
+			msg:  "invalid session token",
+		},
+		{
+			name: "session of another user",
+			ctx:  withToken("Bearer " + signedToken(s, fmt.Sprintf("%s:%d:%d", sessionID, other.ID, expiresAt))),
+			code: codes.Unauthenticated,
+			msg:  "invalid session token",
+		},
+		{
+			name: "expired",
+			ctx:  withToken("Bearer " + signedToken(s, fmt.Sprintf("%s:%d:%d", sessionID, user.ID, time.Now().Unix()-1))),
+			code: codes.Unauthenticated,
+			msg:  "session expired",
+		},
+		{
+			name: "revoked",
+			ctx:  withToken("Bearer " + revoked),
+			code: codes.Unauthenticated,
+			msg:  "session revoked",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, err := s.authenticate(tt.ctx)
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("got code %v (%v), want %v", code, err, tt.code)
+			}
+			if err != nil {
+				if msg := status.Convert(err).Message(); msg != tt.msg {
+					t.Errorf("got message %q, want %q", msg, tt.msg)
+				}
+				return
+			}
+			if session.ID != sessionID || session.UserID != user.ID {
+				t.Errorf("got session %s of user %d, want %s of user %d", session.ID, session.UserID, sessionID, user.ID)
+			}
+		})
+	}
+}
+
+func TestIssueTokenExpiry(t *testing.T) {
+	s := newTestServer(t)
+	user, err := s.Store.CreateUser(context.Background(), "alice", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := time.Now().Add(s.Auth.SessionTTL).Unix()
+	_, expiresAt, err := s.issueToken(context.Background(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after := time.Now().Add(s.Auth.SessionTTL).Unix(); expiresAt < before || expiresAt > after {
+		t.Errorf("token expires at %d, want between %d and %d", expiresAt, before, after)
+	}
+
+	// a token issued with a TTL that already ran out is refused
+	s.Auth.SessionTTL = -time.Second
+	token, _, err := s.issueToken(context.Background(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.authenticate(withToken("Bearer " + token))
+	if status.Code(err) != codes.Unauthenticated || status.Convert(err).Message() != "session expired" {
+		t.Errorf("got %v, want session expired", err)
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/ayushsarode/termiXchat/db"
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ListConversations returns the user's direct message conversations, most
+// recently active first
+func (s *Server) ListConversations(ctx context.Context, req *pb.ListConversationsRequest) (*pb.ListConversationsResponse, error) {
+	userID := callerID(ctx)
+
+	s.Mutex.RLock()
+	_, exists := s.Users[userID]
+	s.Mutex.RUnlock()
+	if !exists {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+
+	conversations, err := s.Store.ListConversations(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to list conversations: %v", err)
+		return nil, status.Error(codes.Internal, "failed to list conversations")
+	}
+
+	resp := &pb.ListConversationsResponse{
+		Conversations: make([]*pb.ConversationInfo, 0, len(conversations)),
+	}
+	for _, c := range conversations {
+		info := &pb.ConversationInfo{
+			ConversationId: c.ID,
+			PeerUsername:   c.PeerUsername,
+			UnreadCount:    c.UnreadCount,
+		}
+		if c.LastMessage != nil {
+			info.LastMessage = messageToProto(c.LastMessage)
+		}
+		resp.Conversations = append(resp.Conversations, info)
+	}
+	return resp, nil
+}
+
+// GetConversationHistory returns one page of the conversation between the
+// user and a peer and marks the conversation read
+func (s *Server) GetConversationHistory(ctx context.Context, req *pb.GetConversationHistoryRequest) (*pb.GetConversationHistoryResponse, error) {
+	if req.Limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit cannot be negative")
+	}
+
+	limit := int(req.Limit)
+	if limit == 0 {
+		limit = defaultHistoryLimit
+	} else if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+
+	userID := callerID(ctx)
+
+	s.Mutex.RLock()
+	_, exists := s.Users[userID]
+	s.Mutex.RUnlock()
+	if !exists {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+
+	peer, err := s.Store.GetUserByUsername(ctx, req.PeerUsername)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, "peer not found")
+		}
+		log.Printf("Failed to look up peer: %v", err)
+		return nil, status.Error(codes.Internal, "failed to load conversation")
+	}
+
+	// looking at a conversation does not start one, only sending does
+	conversationID, err := s.Store.FindConversation(ctx, userID, peer.ID)
+	if errors.Is(err, db.ErrNotFound) {
+		return &pb.GetConversationHistoryResponse{Messages: []*pb.ReceiveMessageResponse{}}, nil
+	}
+	if err != nil {
+		log.Printf("Failed to look up conversation: %v", err)
+		return nil, status.Error(codes.Internal, "failed to load conversation")
+	}
+
+	messages, hasMore, err := s.Store.ConversationHistory(ctx, conversationID, req.BeforeId, limit)
+	if err != nil {
+		log.Printf("Failed to load conversation history: %v", err)
+		return nil, status.Error(codes.Internal, "failed to load conversation")
+	}
+
+	if err := s.Store.MarkConversationRead(ctx, conversationID, userID, time.Now().Unix()); err != nil {
+		log.Printf("Failed to mark conversation read: %v", err)
+	}
+
+	resp := &pb.GetConversationHistoryResponse{
+		Messages: make([]*pb.ReceiveMessageResponse, 0, len(messages)),
+		HasMore:  hasMore,
+	}
+	for _, msg := range messages {
+		resp.Messages = append(resp.Messages, messageToProto(msg))
+	}
+	return resp, nil
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"testing"
+
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSendDirectMessage(t *testing.T) {
+	s := newTestServer(t)
+	alice := addTestUser(t, s, "alice")
+	addTestUser(t, s, "bob")
+
+	tests := []struct {
+		name      string
+		recipient string
+		message   string
+		encrypted bool
+		code      codes.Code
+	}{
+		{name: "queued for offline peer", recipient: "bob", message: "hi", code: codes.OK},
+		{name: "empty", recipient: "bob", code: codes.InvalidArgument},
+		{name: "encrypted not base64", recipient: "bob", message: "not base64!", encrypted: true, code: codes.InvalidArgument},
+		{name: "unknown recipient", recipient: "carol", message: "hi", code: codes.NotFound},
+		{name: "to self", recipient: "alice", message: "hi", code: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := s.SendDirectMessage(asUser(alice), &pb.SendDirectMessageRequest{
+				RecipientUsername: tt.recipient,
+				Message:           tt.message,
+				Encrypted:         tt.encrypted,
+			})
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("got code %v (%v), want %v", code, err, tt.code)
+			}
+			if err == nil && resp.Status != "queued" {
+				t.Errorf("got status %q, want queued", resp.Status)
+			}
+		})
+	}
+}
+
+func TestConversations(t *testing.T) {
+	s := newTestServer(t)
+	alice := addTestUser(t, s, "alice")
+	bob := addTestUser(t, s, "bob")
+	addTestUser(t, s, "carol")
+
+	for _, msg := range []string{"one", "two", "three"} {
+		if _, err := s.SendDirectMessage(asUser(alice), &pb.SendDirectMessageRequest{RecipientUsername: "bob", Message: msg}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, err := s.ListConversations(asUser(bob), &pb.ListConversationsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Conversations) != 1 {
+		t.Fatalf("got %d conversations, want 1", len(list.Conversations))
+	}
+	c := list.Conversations[0]
+	if c.PeerUsername != "alice" || c.UnreadCount != 3 || c.LastMessage.GetMessage() != "three" {
+		t.Errorf("got conversation with %s, %d unread, last %q; want alice, 3 unread, last \"three\"",
+			c.PeerUsername, c.UnreadCount, c.LastMessage.GetMessage())
+	}
+
+	// the sender has nothing to read
+	list, err = s.ListConversations(asUser(alice), &pb.ListConversationsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Conversations) != 1 || list.Conversations[0].UnreadCount != 0 {
+		t.Errorf("got %v for the sender, want one read conversation", list.Conversations)
+	}
+
+	page, err := s.GetConversationHistory(asUser(bob), &pb.GetConversationHistoryRequest{PeerUsername: "alice", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Messages) != 2 || !page.HasMore || page.Messages[1].Message != "three" {
+		t.Errorf("got %d messages (more: %v), want the last 2 with more", len(page.Messages), page.HasMore)
+	}
+
+	// reading the history marks the conversation read
+	list, err = s.ListConversations(asUser(bob), &pb.ListConversationsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unread := list.Conversations[0].UnreadCount; unread != 0 {
+		t.Errorf("got %d unread after reading, want 0", unread)
+	}
+
+	// looking at an empty conversation does not start one
+	page, err = s.GetConversationHistory(asUser(bob), &pb.GetConversationHistoryRequest{PeerUsername: "carol"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Messages) != 0 {
+		t.Errorf("got %d messages with carol, want none", len(page.Messages))
+	}
+	list, err = s.ListConversations(asUser(bob), &pb.ListConversationsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Conversations) != 1 {
+		t.Errorf("got %d conversations after viewing carol, want 1", len(list.Conversations))
+	}
+
+	errTests := []struct {
+		name string
+		req  *pb.GetConversationHistoryRequest
+		code codes.Code
+	}{
+		{name: "negative limit", req: &pb.GetConversationHistoryRequest{PeerUsername: "alice", Limit: -1}, code: codes.InvalidArgument},
+		{name: "unknown peer", req: &pb.GetConversationHistoryRequest{PeerUsername: "dave"}, code: codes.NotFound},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.GetConversationHistory(asUser(bob), tt.req)
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("got code %v (%v), want %v", code, err, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ayushsarode/termiXchat/db"
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// EditWindowFromEnv reads MESSAGE_EDIT_WINDOW, how long after sending a
+// message its author can still edit it. Unset or 0 leaves edits open.
+func EditWindowFromEnv() (time.Duration, error) {
+	value := os.Getenv("MESSAGE_EDIT_WINDOW")
+	if value == "" || value == "0" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid MESSAGE_EDIT_WINDOW %q", value)
+	}
+	return d, nil
+}
+
+// changeableMessage loads a room message the caller wants to change. Authors
+// can change their own messages and moderators those of users they
+// outrank; moderating reports which of the two applies.
+func (s *Server) changeableMessage(ctx context.Context, id int32) (*db.Message, *Room, bool, error) {
+	msg, err := s.Store.GetMessage(ctx, id)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, nil, false, status.Error(codes.NotFound, "message not found")
+		}
+		log.Printf("Failed to load message: %v", err)
+		return nil, nil, false, status.Error(codes.Internal, "failed to load message")
+	}
+	if msg.IsDirect || msg.IsSystem {
+		return nil, nil, false, status.Error(codes.FailedPrecondition, "only room messages can be changed")
+	}
+	if msg.DeletedAt != 0 {
+		return nil, nil, false, status.Error(codes.FailedPrecondition, "message was deleted")
+	}
+
+	if msg.UserID == callerID(ctx) {
+		s.Mutex.RLock()
+		room, exists := s.Rooms[msg.RoomID]
+		s.Mutex.RUnlock()
+		if !exists {
+			return nil, nil, false, status.Error(codes.NotFound, "room not found")
+		}
+		return msg, room, false, nil
+	}
+
+	room, rank, err := s.requireRoomRole(ctx, msg.RoomID, db.RoleModerator)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if _, err := s.moderationTarget(ctx, room, rank, msg.Username); err != nil {
+		return nil, nil, false, err
+	}
+	return msg, room, true, nil
+}
+
+// broadcastMessageEvent tells every client in the room that a message
+// changed. Callers must hold s.Mutex.
+func (s *Server) broadcastMessageEvent(room *Room, msg *db.Message, event pb.MessageEvent) {
+	resp := messageToProto(msg)
+	resp.Event = event
+	for _, client := range room.Clients {
+		if err := client.Send(resp); err != nil {
+			log.Printf("Failed to send message event: %v", err)
+		}
+	}
+}
+
+// EditMessage replaces the text of a room message, keeping the old text in
+// its edit history
+func (s *Server) EditMessage(ctx context.Context, req *pb.EditMessageRequest) (*pb.EditMessageResponse, error) {
+	if req.Message == "" {
+		return nil, status.Error(codes.InvalidArgument, "message cannot be empty")
+	}
+
+	msg, room, moderating, err := s.changeableMessage(ctx, req.MessageId)
+	if err != nil {
+		return nil, err
+	}
+
+	// authors edit under the same rules as sending
+	if !moderating {
+		if s.EditWindow > 0 && time.Since(time.Unix(msg.Timestamp, 0)) > s.EditWindow {
+			return nil, status.Errorf(codes.FailedPrecondition, "messages can only be edited within %s of sending", s.EditWindow)
+		}
+		if err := s.checkNotBanned(ctx, room.ID, msg.UserID); err != nil {
+			return nil, err
+		}
+		mute, err := s.activeRestriction(ctx, room.ID, msg.UserID, db.RestrictionMute)
+		if err != nil {
+			return nil, err
+		}
+		if mute != nil {
+			return nil, status.Errorf(codes.PermissionDenied, "you are muted in this room%s", describeExpiry(mute.ExpiresAt))
+		}
+	}
+
+	filtered := &FilterMessage{Text: req.Message, UserID: msg.UserID, RoomID: room.ID}
+	if err := s.Filters.Run(ctx, filtered); err != nil {
+		return nil, err
+	}
+
+	editedAt := time.Now().Unix()
+	if err := s.Store.EditMessage(ctx, msg.ID, filtered.Text, callerID(ctx), editedAt); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, status.Error(codes.FailedPrecondition, "message was deleted")
+		}
+		log.Printf("Failed to edit message: %v", err)
+		return nil, status.Error(codes.Internal, "failed to edit message")
+	}
+	if moderating {
+		s.audit(ctx, &db.AuditEvent{
+			Action:     db.AuditMessageEdit,
+			TargetID:   msg.UserID,
+			TargetName: msg.Username,
+			RoomID:     room.ID,
+			Details:    fmt.Sprintf("message %d", msg.ID),
+		})
+	}
+
+	msg.Content = filtered.Text
+	msg.EditedAt = editedAt
+
+	s.Mutex.Lock()
+	s.broadcastMessageEvent(room, msg, pb.MessageEvent_MESSAGE_EVENT_EDITED)
+	s.Mutex.Unlock()
+
+	return &pb.EditMessageResponse{EditedAt: editedAt}, nil
+}
+
+// DeleteMessage removes the text and edit history of a room message. The
+// message keeps its place so clients can show where it was.
+func (s *Server) DeleteMessage(ctx context.Context, req *pb.DeleteMessageRequest) (*pb.DeleteMessageResponse, error) {
+	msg, room, moderating, err := s.changeableMessage(ctx, req.MessageId)
+	if err != nil {
+		return nil, err
+	}
+
+	deletedAt := time.Now().Unix()
+	if err := s.Store.DeleteMessage(ctx, msg.ID, deletedAt); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, status.Error(codes.FailedPrecondition, "message was deleted")
+		}
+		log.Printf("Failed to delete message: %v", err)
+		return nil, status.Error(codes.Internal, "failed to delete message")
+	}
+	if moderating {
+		s.audit(ctx, &db.AuditEvent{
+			Action:     db.AuditMessageDelete,
+			TargetID:   msg.UserID,
+			TargetName: msg.Username,
+			RoomID:     room.ID,
+			Details:    fmt.Sprintf("message %d", msg.ID),
+		})
+	}
+
+	msg.Content = ""
+	msg.DeletedAt = deletedAt
+
+	s.Mutex.Lock()
+	s.broadcastMessageEvent(room, msg, pb.MessageEvent_MESSAGE_EVENT_DELETED)
+	s.Mutex.Unlock()
+
+	return &pb.DeleteMessageResponse{}, nil
+}
+
+// GetMessageEdits returns the earlier versions of a room message to its
+// author or a moderator
+func (s *Server) GetMessageEdits(ctx context.Context, req *pb.GetMessageEditsRequest) (*pb.GetMessageEditsResponse, error) {
+	msg, _, _, err := s.changeableMessage(ctx, req.MessageId)
+	if err != nil {
+		return nil, err
+	}
+
+	edits, err := s.Store.MessageEdits(ctx, msg.ID)
+	if err != nil {
+		log.Printf("Failed to load message edits: %v", err)
+		return nil, status.Error(codes.Internal, "failed to load message edits")
+	}
+
+	resp := &pb.GetMessageEditsResponse{
+		Edits: make([]*pb.MessageEdit, 0, len(edits)),
+	}
+	for _, edit := range edits {
+		resp.Edits = append(resp.Edits, &pb.MessageEdit{
+			Message:  edit.Content,
+			EditedBy: edit.EditedByName,
+			EditedAt: edit.EditedAt,
+		})
+	}
+	return resp, nil
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// sendTestMessage sends a room message as the user and returns its ID
+func sendTestMessage(t *testing.T, s *Server, userID, roomID int32, text string) int32 {
+	t.Helper()
+	resp, err := s.SendMessage(asUser(userID), &pb.SendMessageRequest{RoomId: roomID, Message: text})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.MessageId
+}
+
+func TestEditMessage(t *testing.T) {
+	s := newTestServer(t)
+	owner := addTestUser(t, s, "alice")
+	bob := addTestUser(t, s, "bob")
+	carol := addTestUser(t, s, "carol")
+	room := createTestRoom(t, s, owner, &pb.CreateRoomRequest{Name: "general"})
+	joinTestRoom(t, s, owner, room)
+	joinTestRoom(t, s, bob, room)
+	joinTestRoom(t, s, carol, room)
+
+	ownerMsg := sendTestMessage(t, s, owner, room, "from alice")
+	bobMsg := sendTestMessage(t, s, bob, room, "from bob")
+	if _, err := s.SendDirectMessage(asUser(bob), &pb.SendDirectMessageRequest{RecipientUsername: "carol", Message: "psst"}); err != nil {
+		t.Fatal(err)
+	}
+	dm, err := s.GetConversationHistory(asUser(bob), &pb.GetConversationHistoryRequest{PeerUsername: "carol"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dmMsg := dm.Messages[0].MessageId
+
+	tests := []struct {
+		name    string
+		user    int32
+		message int32
+		text    string
+		code    codes.Code
+	}{
+		{name: "empty", user: bob, message: bobMsg, code: codes.InvalidArgument},
+		{name: "unknown message", user: bob, message: dmMsg + 100, text: "x", code: codes.NotFound},
+		{name: "direct message", user: bob, message: dmMsg, text: "x", code: codes.FailedPrecondition},
+		{name: "member edits another member", user: carol, message: bobMsg, text: "x", code: codes.PermissionDenied},
+		{name: "member edits owner", user: bob, message: ownerMsg, text: "x", code: codes.PermissionDenied},
+		{name: "author", user: bob, message: bobMsg, text: "from bob, fixed", code: codes.OK},
+		{name: "owner edits member", user: owner, message: bobMsg, text: "from bob, moderated", code: codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.EditMessage(asUser(tt.user), &pb.EditMessageRequest{MessageId: tt.message, Message: tt.text})
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("got code %v (%v), want %v", code, err, tt.code)
+			}
+		})
+	}
+
+	edits, err := s.GetMessageEdits(asUser(bob), &pb.GetMessageEditsRequest{MessageId: bobMsg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edits.Edits) != 2 || edits.Edits[0].Message != "from bob" || edits.Edits[1].EditedBy != "alice" {
+		t.Errorf("got edits %v, want the original and bob's fix, the last replaced by alice", edits.Edits)
+	}
+	if _, err := s.GetMessageEdits(asUser(carol), &pb.GetMessageEditsRequest{MessageId: bobMsg}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("got %v reading another member's edits, want PermissionDenied", err)
+	}
+}
+
+func TestEditWindow(t *testing.T) {
+	s := newTestServer(t)
+	owner := addTestUser(t, s, "alice")
+	bob := addTestUser(t, s, "bob")
+	room := createTestRoom(t, s, owner, &pb.CreateRoomRequest{Name: "general"})
+	joinTestRoom(t, s, bob, room)
+	msg := sendTestMessage(t, s, bob, room, "hello")
+
+	// every message is already too old for the author, but not a moderator
+	s.EditWindow = time.Nanosecond
+	if _, err := s.EditMessage(asUser(bob), &pb.EditMessageRequest{MessageId: msg, Message: "edited"}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("got %v editing after the window, want FailedPrecondition", err)
+	}
+	if _, err := s.EditMessage(asUser(owner), &pb.EditMessageRequest{MessageId: msg, Message: "moderated"}); err != nil {
+		t.Errorf("got %v moderating after the window", err)
+	}
+
+	// muted authors cannot edit either
+	s.EditWindow = 0
+	if _, err := s.MuteUser(asUser(owner), &pb.MuteUserRequest{RoomId: room, Username: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.EditMessage(asUser(bob), &pb.EditMessageRequest{MessageId: msg, Message: "edited"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("got %v editing while muted, want PermissionDenied", err)
+	}
+}
+
+func TestDeleteMessage(t *testing.T) {
+	s := newTestServer(t)
+	owner := addTestUser(t, s, "alice")
+	bob := addTestUser(t, s, "bob")
+	room := createTestRoom(t, s, owner, &pb.CreateRoomRequest{Name: "general"})
+	ownerStream, _ := joinTestRoom(t, s, owner, room)
+	joinTestRoom(t, s, bob, room)
+	msg := sendTestMessage(t, s, bob, room, "oops")
+	if _, err := s.EditMessage(asUser(bob), &pb.EditMessageRequest{MessageId: msg, Message: "oops, edited"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.DeleteMessage(asUser(owner), &pb.DeleteMessageRequest{MessageId: msg}); err != nil {
+		t.Fatal(err)
+	}
+	event := ownerStream.last()
+	if event.MessageId != msg || event.Event != pb.MessageEvent_MESSAGE_EVENT_DELETED || event.Message != "" {
+		t.Errorf("got event %v, want an empty deleted message %d", event, msg)
+	}
+
+	history, err := s.GetMessageHistory(asUser(bob), &pb.GetMessageHistoryRequest{RoomId: room})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, m := range history.Messages {
+		if m.MessageId == msg {
+			found = true
+			if !m.Deleted || m.Message != "" {
+				t.Errorf("got %q (deleted: %v) in history, want an empty deleted message", m.Message, m.Deleted)
+			}
+		}
+	}
+	if !found {
+		t.Error("deleted message lost its place in history")
+	}
+
+	// the text and its history are gone for good
+	for name, call := range map[string]func() error{
+		"edit": func() error {
+			_, err := s.EditMessage(asUser(bob), &pb.EditMessageRequest{MessageId: msg, Message: "back"})
+			return err
+		},
+		"delete": func() error {
+			_, err := s.DeleteMessage(asUser(bob), &pb.DeleteMessageRequest{MessageId: msg})
+			return err
+		},
+		"edits": func() error {
+			_, err := s.GetMessageEdits(asUser(bob), &pb.GetMessageEditsRequest{MessageId: msg})
+			return err
+		},
+	} {
+		if err := call(); status.Code(err) != codes.FailedPrecondition {
+			t.Errorf("%s: got %v, want FailedPrecondition", name, err)
+		}
+	}
+}
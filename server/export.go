@@ -0,0 +1,49 @@
+package server
+
+import (
+	"log"
+
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// exportPageSize is the number of messages read from the store at a time
+// while streaming a transcript
+const exportPageSize = 200
+
+// ExportRoom streams a room's stored messages sent within the requested
+// time range, oldest first
+func (s *Server) ExportRoom(req *pb.ExportRoomRequest, stream pb.ChatService_ExportRoomServer) error {
+	if req.Since < 0 || req.Until < 0 {
+		return status.Error(codes.InvalidArgument, "time bounds cannot be negative")
+	}
+	if req.Until != 0 && req.Until <= req.Since {
+		return status.Error(codes.InvalidArgument, "until must be later than since")
+	}
+
+	ctx := stream.Context()
+	if err := s.checkReadAccess(ctx, req.RoomId); err != nil {
+		return err
+	}
+
+	var afterID int32
+	for {
+		messages, hasMore, err := s.Store.RoomTranscript(ctx, req.RoomId, req.Since, req.Until, afterID, exportPageSize)
+		if err != nil {
+			log.Printf("Failed to load room transcript: %v", err)
+			return status.Error(codes.Internal, "failed to load room transcript")
+		}
+
+		for _, msg := range messages {
+			if err := stream.Send(messageToProto(msg)); err != nil {
+				return err
+			}
+			afterID = msg.ID
+		}
+
+		if !hasMore {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,466 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ayushsarode/termiXchat/db"
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"golang.org/x/text/unicode/norm"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// FilterAction is what a filter does with a message it matches
+type FilterAction string
+
+const (
+	// FilterReject refuses the message with the filter's reason
+	FilterReject FilterAction = "reject"
+	// FilterRewrite replaces the message text and passes it on
+	FilterRewrite FilterAction = "rewrite"
+	// FilterFlag delivers the message but logs it for moderators
+	FilterFlag FilterAction = "flag"
+)
+
+// FilterMessage is a room message or direct message on its way through the
+// pipeline
+type FilterMessage struct {
+	Text   string
+	UserID int32
+	// RoomID is zero for direct messages
+	RoomID int32
+	// Encrypted messages are ciphertext, so only their size can be checked
+	Encrypted bool
+}
+
+// FilterResult is the decision of one filter. A zero result passes the
+// message on unchanged.
+type FilterResult struct {
+	Action FilterAction
+	// Text replaces the message for FilterRewrite
+	Text string
+	// Reason explains a rejection or flag
+	Reason string
+}
+
+// MessageFilter inspects a message before it is stored and delivered
+type MessageFilter interface {
+	// Name identifies the filter in FILTERS and in logs
+	Name() string
+	Filter(ctx context.Context, msg *FilterMessage) (FilterResult, error)
+}
+
+// FilterPipeline runs filters in order, each seeing the text left by the
+// ones before it. A nil pipeline passes every message.
+type FilterPipeline struct {
+	filters []MessageFilter
+}
+
+func NewFilterPipeline(filters ...MessageFilter) *FilterPipeline {
+	return &FilterPipeline{filters: filters}
+}
+
+// Run filters msg in place, returning InvalidArgument if a filter rejects it
+func (p *FilterPipeline) Run(ctx context.Context, msg *FilterMessage) error {
+	if p == nil {
+		return nil
+	}
+
+	var flags []string
+	for _, f := range p.filters {
+		res, err := f.Filter(ctx, msg)
+		if err != nil {
+			log.Printf("Failed to run %s filter: %v", f.Name(), err)
+			return status.Error(codes.Internal, "failed to filter message")
+		}
+		switch res.Action {
+		case FilterReject:
+			return status.Errorf(codes.InvalidArgument, "message rejected: %s", res.Reason)
+		case FilterRewrite:
+			msg.Text = res.Text
+		case FilterFlag:
+			flags = append(flags, f.Name()+": "+res.Reason)
+		}
+	}
+
+	if strings.TrimSpace(msg.Text) == "" {
+		return status.Error(codes.InvalidArgument, "message cannot be empty")
+	}
+	if len(flags) > 0 {
+		log.Printf("Flagged message from user %d in room %d: %s", msg.UserID, msg.RoomID, strings.Join(flags, "; "))
+	}
+	return nil
+}
+
+// defaultFilters is the pipeline order used when FILTERS is not set.
+// Escapes go first so later filters see the text as it will be shown.
+const defaultFilters = "escapes,normalize,length,words,links"
+
+// defaultMaxMessageLength is the FILTER_MAX_LENGTH default, in characters
+const defaultMaxMessageLength = 2000
+
+// FiltersFromEnv builds the filters named in FILTERS, in order, or none if
+// it is "off". The filters read their settings from:
+//
+//   - FILTER_MAX_LENGTH: most characters in a message (default 2000)
+//   - FILTER_WORD_ACTION: mask, reject or flag room blocked words (default mask)
+//   - FILTER_LINK_ALLOW: comma separated domains links may point to, others are acted on
+//   - FILTER_LINK_DENY: comma separated domains links may not point to
+//   - FILTER_LINK_ACTION: strip, reject or flag links (default reject)
+func FiltersFromEnv(store db.Store) ([]MessageFilter, error) {
+	names := os.Getenv("FILTERS")
+	switch names {
+	case "":
+		names = defaultFilters
+	case "off":
+		return nil, nil
+	}
+
+	var filters []MessageFilter
+	for _, name := range strings.Split(names, ",") {
+		var (
+			f   MessageFilter
+			err error
+		)
+		switch strings.TrimSpace(name) {
+		case "escapes":
+			f = escapeFilter{}
+		case "normalize":
+			f = normalizeFilter{}
+		case "length":
+			f, err = lengthFilterFromEnv()
+		case "words":
+			f, err = wordFilterFromEnv(store)
+		case "links":
+			f, err = linkFilterFromEnv()
+		default:
+			err = fmt.Errorf("unknown filter %q in FILTERS", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+// actionFromEnv reads a filter action, where rewriteName is what the
+// variable calls the rewrite, such as "mask" or "strip"
+func actionFromEnv(key, rewriteName string, def FilterAction) (FilterAction, error) {
+	switch value := os.Getenv(key); value {
+	case "":
+		return def, nil
+	case rewriteName:
+		return FilterRewrite, nil
+	case string(FilterReject), string(FilterFlag):
+		return FilterAction(value), nil
+	default:
+		return "", fmt.Errorf("invalid %s %q, use %s, reject or flag", key, value, rewriteName)
+	}
+}
+
+// escapeSequence matches ANSI CSI and OSC sequences and other two byte
+// escapes, which could move the cursor or recolor a terminal
+var escapeSequence = regexp.MustCompile(`\x1b(?:\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(?:\x07|\x1b\\)?|[@-_])`)
+
+// escapeFilter strips terminal escape sequences, control characters and
+// bidirectional overrides, which could disguise what a message says
+type escapeFilter struct{}
+
+func (escapeFilter) Name() string { return "escapes" }
+
+func (escapeFilter) Filter(ctx context.Context, msg *FilterMessage) (FilterResult, error) {
+	if msg.Encrypted {
+		return FilterResult{}, nil
+	}
+
+	text := escapeSequence.ReplaceAllString(msg.Text, "")
+	text = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t':
+			return ' '
+		case unicode.IsControl(r), unicode.Is(unicode.Bidi_Control, r), r == utf8.RuneError:
+			return -1
+		}
+		return r
+	}, text)
+
+	if text == msg.Text {
+		return FilterResult{}, nil
+	}
+	return FilterResult{Action: FilterRewrite, Text: text}, nil
+}
+
+// normalizeFilter rewrites messages to Unicode NFC, so the same text is
+// always stored and searched the same way
+type normalizeFilter struct{}
+
+func (normalizeFilter) Name() string { return "normalize" }
+
+func (normalizeFilter) Filter(ctx context.Context, msg *FilterMessage) (FilterResult, error) {
+	if msg.Encrypted || norm.NFC.IsNormalString(msg.Text) {
+		return FilterResult{}, nil
+	}
+	return FilterResult{Action: FilterRewrite, Text: norm.NFC.String(msg.Text)}, nil
+}
+
+// lengthFilter rejects messages over a number of characters. Encrypted
+// messages are allowed for the base64 overhead.
+type lengthFilter struct {
+	max int
+}
+
+func lengthFilterFromEnv() (lengthFilter, error) {
+	f := lengthFilter{max: defaultMaxMessageLength}
+	if value := os.Getenv("FILTER_MAX_LENGTH"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return lengthFilter{}, fmt.Errorf("invalid FILTER_MAX_LENGTH %q", value)
+		}
+		f.max = n
+	}
+	return f, nil
+}
+
+func (lengthFilter) Name() string { return "length" }
+
+func (f lengthFilter) Filter(ctx context.Context, msg *FilterMessage) (FilterResult, error) {
+	limit := f.max
+	if msg.Encrypted {
+		// room for the nonce, tag and base64 of max four byte characters
+		limit = (28+f.max*4)*4/3 + 4
+	}
+	if n := utf8.RuneCountInString(msg.Text); n > limit {
+		return FilterResult{Action: FilterReject, Reason: fmt.Sprintf("longer than %d characters", f.max)}, nil
+	}
+	return FilterResult{}, nil
+}
+
+// normalizeWord folds a word the way blocked words are stored and matched,
+// so compatibility forms and case do not slip past the list
+func normalizeWord(word string) string {
+	return strings.ToLower(norm.NFKC.String(word))
+}
+
+// isWordRune reports whether r is part of a word for blocked word matching
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
+
+// wordFilter acts on a room's blocked words, matched as whole words. Masking
+// replaces each letter with an asterisk.
+type wordFilter struct {
+	store  db.Store
+	action FilterAction
+}
+
+func wordFilterFromEnv(store db.Store) (wordFilter, error) {
+	action, err := actionFromEnv("FILTER_WORD_ACTION", "mask", FilterRewrite)
+	if err != nil {
+		return wordFilter{}, err
+	}
+	return wordFilter{store: store, action: action}, nil
+}
+
+func (wordFilter) Name() string { return "words" }
+
+func (f wordFilter) Filter(ctx context.Context, msg *FilterMessage) (FilterResult, error) {
+	if msg.Encrypted || msg.RoomID == 0 {
+		return FilterResult{}, nil
+	}
+
+	words, err := f.store.ListBlockedWords(ctx, msg.RoomID)
+	if err != nil || len(words) == 0 {
+		return FilterResult{}, err
+	}
+	blocked := make(map[string]bool, len(words))
+	for _, word := range words {
+		blocked[word] = true
+	}
+
+	var (
+		out     strings.Builder
+		matched string
+	)
+	text := msg.Text
+	for len(text) > 0 {
+		// copy everything up to the next word as it is
+		start := strings.IndexFunc(text, isWordRune)
+		if start < 0 {
+			out.WriteString(text)
+			break
+		}
+		out.WriteString(text[:start])
+		text = text[start:]
+
+		end := strings.IndexFunc(text, func(r rune) bool { return !isWordRune(r) })
+		if end < 0 {
+			end = len(text)
+		}
+		word := text[:end]
+		text = text[end:]
+
+		if !blocked[normalizeWord(word)] {
+			out.WriteString(word)
+			continue
+		}
+		if matched == "" {
+			matched = word
+		}
+		out.WriteString(strings.Repeat("*", utf8.RuneCountInString(word)))
+	}
+
+	if matched == "" {
+		return FilterResult{}, nil
+	}
+	return FilterResult{Action: f.action, Text: out.String(), Reason: "contains a blocked word"}, nil
+}
+
+// linkPattern matches web links written with a scheme or starting with www.
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"]+`)
+
+// linkFilter acts on links to denied domains, and when an allow list is set,
+// on links to any domain not on it. Stripping replaces the link.
+type linkFilter struct {
+	allow, deny []string
+	action      FilterAction
+}
+
+func linkFilterFromEnv() (linkFilter, error) {
+	action, err := actionFromEnv("FILTER_LINK_ACTION", "strip", FilterReject)
+	if err != nil {
+		return linkFilter{}, err
+	}
+	return linkFilter{
+		allow:  domainList(os.Getenv("FILTER_LINK_ALLOW")),
+		deny:   domainList(os.Getenv("FILTER_LINK_DENY")),
+		action: action,
+	}, nil
+}
+
+func domainList(value string) []string {
+	var domains []string
+	for _, domain := range strings.Split(value, ",") {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			domains = append(domains, strings.TrimPrefix(domain, "."))
+		}
+	}
+	return domains
+}
+
+// matchesDomain reports whether host is one of the domains or a subdomain
+func matchesDomain(host string, domains []string) bool {
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func (linkFilter) Name() string { return "links" }
+
+// blocked reports whether a matched link breaks the allow or deny list.
+// Links that cannot be parsed are treated as blocked.
+func (f linkFilter) blocked(link string) bool {
+	if strings.HasPrefix(strings.ToLower(link), "www.") {
+		link = "http://" + link
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return true
+	}
+	host := strings.ToLower(u.Hostname())
+	if matchesDomain(host, f.deny) {
+		return true
+	}
+	return len(f.allow) > 0 && !matchesDomain(host, f.allow)
+}
+
+func (f linkFilter) Filter(ctx context.Context, msg *FilterMessage) (FilterResult, error) {
+	if msg.Encrypted || (len(f.allow) == 0 && len(f.deny) == 0) {
+		return FilterResult{}, nil
+	}
+
+	var matched string
+	text := linkPattern.ReplaceAllStringFunc(msg.Text, func(link string) string {
+		if !f.blocked(link) {
+			return link
+		}
+		if matched == "" {
+			matched = link
+		}
+		return "[link removed]"
+	})
+
+	if matched == "" {
+		return FilterResult{}, nil
+	}
+	return FilterResult{Action: f.action, Text: text, Reason: "links to " + matched}, nil
+}
+
+// maxBlockedWords caps the blocked word list of a room
+const maxBlockedWords = 200
+
+// SetBlockedWords replaces the blocked word list of a room
+func (s *Server) SetBlockedWords(ctx context.Context, req *pb.SetBlockedWordsRequest) (*pb.SetBlockedWordsResponse, error) {
+	room, _, err := s.requireRoomRole(ctx, req.RoomId, db.RoleModerator)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(req.Words))
+	words := make([]string, 0, len(req.Words))
+	for _, word := range req.Words {
+		word = normalizeWord(strings.TrimSpace(word))
+		if word == "" || seen[word] {
+			continue
+		}
+		if strings.IndexFunc(word, func(r rune) bool { return !isWordRune(r) }) >= 0 {
+			return nil, status.Error(codes.InvalidArgument, "blocked words must be single words of letters and digits")
+		}
+		seen[word] = true
+		words = append(words, word)
+	}
+	if len(words) > maxBlockedWords {
+		return nil, status.Errorf(codes.InvalidArgument, "a room can block at most %d words", maxBlockedWords)
+	}
+
+	if err := s.Store.SetBlockedWords(ctx, room.ID, words); err != nil {
+		log.Printf("Failed to store blocked words: %v", err)
+		return nil, status.Error(codes.Internal, "failed to set blocked words")
+	}
+	moderator := s.callerName(ctx)
+	s.audit(ctx, &db.AuditEvent{
+		Action:    db.AuditBlockedWords,
+		ActorName: moderator,
+		RoomID:    room.ID,
+		Details:   strings.Join(words, ", "),
+	})
+	log.Printf("%s set %d blocked words in room %s", moderator, len(words), room.Name)
+	return &pb.SetBlockedWordsResponse{}, nil
+}
+
+// GetBlockedWords lists the blocked words of a room for its moderators
+func (s *Server) GetBlockedWords(ctx context.Context, req *pb.GetBlockedWordsRequest) (*pb.GetBlockedWordsResponse, error) {
+	room, _, err := s.requireRoomRole(ctx, req.RoomId, db.RoleModerator)
+	if err != nil {
+		return nil, err
+	}
+
+	words, err := s.Store.ListBlockedWords(ctx, room.ID)
+	if err != nil {
+		log.Printf("Failed to list blocked words: %v", err)
+		return nil, status.Error(codes.Internal, "failed to get blocked words")
+	}
+	return &pb.GetBlockedWordsResponse{Words: words}, nil
+}
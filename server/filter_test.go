@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ayushsarode/termiXchat/db"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestFilterPipeline(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	room := &db.Room{Name: "lobby", Visibility: db.VisibilityPublic}
+	if err := s.Store.CreateRoom(ctx, room); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Store.SetBlockedWords(ctx, room.ID, []string{"darn"}); err != nil {
+		t.Fatal(err)
+	}
+
+	pipeline := NewFilterPipeline(
+		escapeFilter{},
+		normalizeFilter{},
+		lengthFilter{max: 40},
+		wordFilter{store: s.Store, action: FilterRewrite},
+		linkFilter{deny: []string{"example.com"}, action: FilterReject},
+	)
+
+	tests := []struct {
+		name string
+		msg  FilterMessage
+		want string
+		// err is the message of an InvalidArgument rejection
+		err string
+	}{
+		{name: "plain", msg: FilterMessage{Text: "hello"}, want: "hello"},
+		{name: "ansi color", msg: FilterMessage{Text: "\x1b[31mred\x1b[0m"}, want: "red"},
+		{name: "osc title", msg: FilterMessage{Text: "\x1b]0;pwned\x07hi"}, want: "hi"},
+		{name: "tab and control", msg: FilterMessage{Text: "a\tb\x07c"}, want: "a bc"},
+		{name: "bidi override", msg: FilterMessage{Text: "abc\u202edef"}, want: "abcdef"},
+		{name: "decomposed accent", msg: FilterMessage{Text: "cafe\u0301"}, want: "caf\u00e9"},
+		{name: "too long", msg: FilterMessage{Text: "this message is far longer than forty characters"}, err: "message rejected: longer than 40 characters"},
+		{name: "blocked word masked", msg: FilterMessage{Text: "oh Darn it", RoomID: room.ID}, want: "oh **** it"},
+		{name: "blocked word inside a word", msg: FilterMessage{Text: "darnation", RoomID: room.ID}, want: "darnation"},
+		{name: "blocked words only apply to rooms", msg: FilterMessage{Text: "darn"}, want: "darn"},
+		{name: "denied link", msg: FilterMessage{Text: "see https://www.example.com/x"}, err: "message rejected: links to https://www.example.com/x"},
+		{name: "other link", msg: FilterMessage{Text: "see https://golang.org"}, want: "see https://golang.org"},
+		{name: "nothing left", msg: FilterMessage{Text: "\x1b[2J"}, err: "message cannot be empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := tt.msg
+			err := pipeline.Run(ctx, &msg)
+			if tt.err != "" {
+				if status.Code(err) != codes.InvalidArgument || status.Convert(err).Message() != tt.err {
+					t.Fatalf("got %v, want InvalidArgument %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.Text != tt.want {
+				t.Errorf("got %q, want %q", msg.Text, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinkFilterAllowList(t *testing.T) {
+	f := linkFilter{allow: []string{"golang.org"}, action: FilterRewrite}
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"https://golang.org/doc", ""},
+		{"https://pkg.golang.org", ""},
+		{"https://notgolang.org", "[link removed]"},
+		{"www.example.com and https://golang.org", "[link removed] and https://golang.org"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			res, err := f.Filter(context.Background(), &FilterMessage{Text: tt.text})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Text != tt.want {
+				t.Errorf("got %q, want %q", res.Text, tt.want)
+			}
+		})
+	}
+}
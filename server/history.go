@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+	"log"
+
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GetMessageHistory returns one page of a room's stored messages
+func (s *Server) GetMessageHistory(ctx context.Context, req *pb.GetMessageHistoryRequest) (*pb.GetMessageHistoryResponse, error) {
+	if req.BeforeId != 0 && req.AfterId != 0 {
+		return nil, status.Error(codes.InvalidArgument, "before_id and after_id cannot be combined")
+	}
+	if req.Limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit cannot be negative")
+	}
+
+	limit := int(req.Limit)
+	if limit == 0 {
+		limit = defaultHistoryLimit
+	} else if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+
+	if err := s.checkReadAccess(ctx, req.RoomId); err != nil {
+		return nil, err
+	}
+
+	messages, hasMore, err := s.Store.RoomHistory(ctx, req.RoomId, req.BeforeId, req.AfterId, limit)
+	if err != nil {
+		log.Printf("Failed to load room history: %v", err)
+		return nil, status.Error(codes.Internal, "failed to load room history")
+	}
+
+	resp := &pb.GetMessageHistoryResponse{
+		Messages: make([]*pb.ReceiveMessageResponse, 0, len(messages)),
+		HasMore:  hasMore,
+	}
+	for _, msg := range messages {
+		resp.Messages = append(resp.Messages, messageToProto(msg))
+	}
+	if err := s.attachReactions(ctx, resp.Messages); err != nil {
+		log.Printf("Failed to load reactions: %v", err)
+		return nil, status.Error(codes.Internal, "failed to load room history")
+	}
+	return resp, nil
+}
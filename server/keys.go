@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdh"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/ayushsarode/termiXchat/db"
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// PublishKey stores the caller's X25519 public key for end-to-end encrypted
+// direct messages. The private key never leaves the client.
+func (s *Server) PublishKey(ctx context.Context, req *pb.PublishKeyRequest) (*pb.PublishKeyResponse, error) {
+	if _, err := ecdh.X25519().NewPublicKey(req.PublicKey); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "public key must be a 32 byte X25519 key")
+	}
+
+	key := &db.PublicKey{
+		UserID:      callerID(ctx),
+		Key:         req.PublicKey,
+		PublishedAt: time.Now().Unix(),
+	}
+	if err := s.Store.SavePublicKey(ctx, key); err != nil {
+		log.Printf("Failed to store public key: %v", err)
+		return nil, status.Error(codes.Internal, "failed to publish key")
+	}
+	return &pb.PublishKeyResponse{}, nil
+}
+
+// GetPublicKey returns the public key a user published. Clients compare it
+// with the key they saw before, since the server could hand out its own.
+func (s *Server) GetPublicKey(ctx context.Context, req *pb.GetPublicKeyRequest) (*pb.GetPublicKeyResponse, error) {
+	user, err := s.Store.GetUserByUsername(ctx, req.Username)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		log.Printf("Failed to load user: %v", err)
+		return nil, status.Error(codes.Internal, "failed to load public key")
+	}
+
+	key, err := s.Store.GetPublicKey(ctx, user.ID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, status.Errorf(codes.NotFound, "%s has not published a key", user.Username)
+		}
+		log.Printf("Failed to load public key: %v", err)
+		return nil, status.Error(codes.Internal, "failed to load public key")
+	}
+
+	return &pb.GetPublicKeyResponse{
+		PublicKey:   key.Key,
+		PublishedAt: key.PublishedAt,
+		UserId:      user.ID,
+	}, nil
+}
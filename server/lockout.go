@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/ayushsarode/termiXchat/db"
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// lockoutPolicy decides how failed logins slow down further attempts
+type lockoutPolicy struct {
+	// freeFailures are allowed before any backoff applies
+	freeFailures int32
+	// lockoutFailures in a row lock the subject for lockoutDuration
+	lockoutFailures int32
+}
+
+var (
+	// an address can be shared by many users, so it gets more slack
+	userLockoutPolicy = lockoutPolicy{freeFailures: 3, lockoutFailures: 10}
+	ipLockoutPolicy   = lockoutPolicy{freeFailures: 10, lockoutFailures: 50}
+)
+
+const (
+	// loginBaseBackoff is the wait after the first failure past the free
+	// ones, doubling with each further failure up to loginMaxBackoff
+	loginBaseBackoff = time.Second
+	loginMaxBackoff  = 5 * time.Minute
+	// loginLockoutDuration is how long a subject stays locked
+	loginLockoutDuration = 30 * time.Minute
+	// loginFailureWindow is how long a failure is remembered, counted from
+	// the most recent one
+	loginFailureWindow = time.Hour
+)
+
+// LoginSubject names the record counting a username's failed logins
+func LoginSubject(username string) string {
+	return "user:" + username
+}
+
+// loginSubjects lists the records a login attempt counts against, with the
+// policy for each
+func loginSubjects(ctx context.Context, username string) map[string]lockoutPolicy {
+	subjects := map[string]lockoutPolicy{LoginSubject(username): userLockoutPolicy}
+	if p, ok := peer.FromContext(ctx); ok {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		subjects["ip:"+host] = ipLockoutPolicy
+	}
+	return subjects
+}
+
+// checkLoginAllowed refuses the attempt with ResourceExhausted and a
+// retry-after trailer while any of the subjects is backing off or locked
+func (s *Server) checkLoginAllowed(ctx context.Context, subjects map[string]lockoutPolicy) error {
+	now := time.Now().Unix()
+	var lockedUntil int64
+	for subject := range subjects {
+		f, err := s.Store.GetLoginFailure(ctx, subject)
+		if errors.Is(err, db.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Failed to load login failures: %v", err)
+			return status.Error(codes.Internal, "failed to check login attempts")
+		}
+		if f.LockedUntil > lockedUntil {
+			lockedUntil = f.LockedUntil
+		}
+	}
+
+	if lockedUntil <= now {
+		return nil
+	}
+	retryAfter := lockedUntil - now
+	grpc.SetTrailer(ctx, metadata.Pairs("retry-after", strconv.FormatInt(retryAfter, 10)))
+	return status.Errorf(codes.ResourceExhausted, "too many failed logins, retry in %ds", retryAfter)
+}
+
+// recordLoginFailure counts a failed login against every subject and
+// extends their backoff or lockout
+func (s *Server) recordLoginFailure(ctx context.Context, subjects map[string]lockoutPolicy) {
+	now := time.Now()
+	for subject, policy := range subjects {
+		f, err := s.Store.GetLoginFailure(ctx, subject)
+		if errors.Is(err, db.ErrNotFound) || (err == nil && now.Unix()-f.LastFailureAt > int64(loginFailureWindow.Seconds())) {
+			f, err = &db.LoginFailure{Subject: subject}, nil
+		}
+		if err != nil {
+			log.Printf("Failed to load login failures: %v", err)
+			continue
+		}
+
+		f.Failures++
+		f.LastFailureAt = now.Unix()
+		switch {
+		case f.Failures >= policy.lockoutFailures:
+			f.LockedUntil = now.Add(loginLockoutDuration).Unix()
+			log.Printf("Locked logins for %s after %d failed attempts", subject, f.Failures)
+		case f.Failures > policy.freeFailures:
+			backoff := loginMaxBackoff
+			if shift := f.Failures - policy.freeFailures - 1; shift < 16 {
+				backoff = min(loginBaseBackoff<<shift, loginMaxBackoff)
+			}
+			f.LockedUntil = now.Add(backoff).Unix()
+		}
+
+		if err := s.Store.SaveLoginFailure(ctx, f); err != nil {
+			log.Printf("Failed to record login failure: %v", err)
+		}
+	}
+}
+
+// requireAdmin loads the caller and fails with PermissionDenied unless they
+// are an admin
+func (s *Server) requireAdmin(ctx context.Context) (*db.User, error) {
+	user, err := s.Store.GetUser(ctx, callerID(ctx))
+	if err != nil {
+		log.Printf("Failed to load user: %v", err)
+		return nil, status.Error(codes.Internal, "failed to check admin privileges")
+	}
+	if !user.IsAdmin {
+		return nil, status.Error(codes.PermissionDenied, "admin privileges required")
+	}
+	return user, nil
+}
+
+// UnlockAccount lets an admin clear the failed logins of a username
+func (s *Server) UnlockAccount(ctx context.Context, req *pb.UnlockAccountRequest) (*pb.UnlockAccountResponse, error) {
+	admin, err := s.requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := s.Store.GetUserByUsername(ctx, req.Username)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		log.Printf("Failed to load user: %v", err)
+		return nil, status.Error(codes.Internal, "failed to unlock account")
+	}
+
+	if err := s.Store.ClearLoginFailure(ctx, LoginSubject(req.Username)); err != nil {
+		log.Printf("Failed to clear login failures: %v", err)
+		return nil, status.Error(codes.Internal, "failed to unlock account")
+	}
+
+	s.audit(ctx, &db.AuditEvent{
+		Action:     db.AuditUnlock,
+		ActorName:  admin.Username,
+		TargetID:   target.ID,
+		TargetName: target.Username,
+	})
+	log.Printf("%s unlocked the account of %s", admin.Username, req.Username)
+	return &pb.UnlockAccountResponse{}, nil
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ayushsarode/termiXchat/db"
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRecordLoginFailure(t *testing.T) {
+	subject := LoginSubject("alice")
+
+	tests := []struct {
+		failures int32
+		// backoff is how long logins stay refused after the last failure
+		backoff time.Duration
+	}{
+		{failures: 1},
+		{failures: 3},
+		{failures: 4, backoff: time.Second},
+		{failures: 5, backoff: 2 * time.Second},
+		{failures: 9, backoff: 32 * time.Second},
+		{failures: 10, backoff: loginLockoutDuration},
+		{failures: 12, backoff: loginLockoutDuration},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d failures", tt.failures), func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestServer(t)
+			subjects := map[string]lockoutPolicy{subject: userLockoutPolicy}
+			for i := int32(0); i < tt.failures; i++ {
+				s.recordLoginFailure(ctx, subjects)
+			}
+
+			f, err := s.Store.GetLoginFailure(ctx, subject)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.Failures != tt.failures {
+				t.Errorf("got %d failures, want %d", f.Failures, tt.failures)
+			}
+			var backoff time.Duration
+			// LockedUntil stays zero until the free failures run out
+			if f.LockedUntil != 0 {
+				backoff = time.Duration(f.LockedUntil-f.LastFailureAt) * time.Second
+			}
+			if backoff != tt.backoff {
+				t.Errorf("got a backoff of %v, want %v", backoff, tt.backoff)
+			}
+
+			want := codes.OK
+			if tt.backoff > 0 {
+				want = codes.ResourceExhausted
+			}
+			if err := s.checkLoginAllowed(ctx, subjects); status.Code(err) != want {
+				t.Errorf("got %v checking the next login, want %v", err, want)
+			}
+		})
+	}
+}
+
+func TestRecordLoginFailureWindow(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	subject := LoginSubject("alice")
+
+	// failures older than the window are forgotten
+	stale := time.Now().Add(-loginFailureWindow - time.Minute).Unix()
+	if err := s.Store.SaveLoginFailure(ctx, &db.LoginFailure{Subject: subject, Failures: 9, LastFailureAt: stale}); err != nil {
+		t.Fatal(err)
+	}
+	s.recordLoginFailure(ctx, map[string]lockoutPolicy{subject: userLockoutPolicy})
+
+	f, err := s.Store.GetLoginFailure(ctx, subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Failures != 1 || f.LockedUntil > f.LastFailureAt {
+		t.Errorf("got %d failures locked until %d, want a fresh count", f.Failures, f.LockedUntil)
+	}
+}
+
+func TestCompletePasswordResetClearsLockout(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	user, err := s.Store.CreateUser(ctx, "alice", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subjects := map[string]lockoutPolicy{LoginSubject(user.Username): userLockoutPolicy}
+	for i := 0; i < 10; i++ {
+		s.recordLoginFailure(ctx, subjects)
+	}
+	if err := s.checkLoginAllowed(ctx, subjects); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("got %v before the reset, want ResourceExhausted", err)
+	}
+
+	now := time.Now()
+	reset := &db.PasswordReset{CodeHash: hashCode("code"), UserID: user.ID, CreatedAt: now.Unix(), ExpiresAt: now.Add(time.Hour).Unix()}
+	if err := s.Store.CreatePasswordReset(ctx, reset); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CompletePasswordReset(ctx, &pb.CompletePasswordResetRequest{ResetCode: "code", NewPassword: "new password"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.checkLoginAllowed(ctx, subjects); err != nil {
+		t.Errorf("got %v after the reset, want the lockout cleared", err)
+	}
+}
@@ -2,23 +2,100 @@ package server
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"log"
 	"time"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"github.com/ayushsarode/termiXchat/db"
 	pb "github.com/ayushsarode/termiXchat/proto"
 )
 
-type Message struct {
-	ID        int32
-	UserID    int32
-	Username  string
-	Content   string
-	RoomID    int32
-	Timestamp int64
-	IsSystem  bool
-	IsDirect  bool
+// defaultHistoryLimit is the number of past messages replayed on JoinRoom
+// when the client does not ask for a specific amount.
+const defaultHistoryLimit = 50
+
+// maxHistoryLimit caps how many messages a single replay can return.
+const maxHistoryLimit = 500
+
+func messageToProto(m *db.Message) *pb.ReceiveMessageResponse {
+	return &pb.ReceiveMessageResponse{
+		MessageId:           m.ID,
+		Username:            m.Username,
+		Message:             m.Content,
+		Timestamp:           m.Timestamp,
+		IsSystem:            m.IsSystem,
+		IsDirect:            m.IsDirect,
+		ConversationId:      m.ConversationID,
+		Encrypted:           m.Encrypted,
+		EditedAt:            m.EditedAt,
+		Deleted:             m.DeletedAt != 0,
+		ReplyToMessageId:    m.ReplyToID,
+		ThreadRootMessageId: m.ThreadRootID,
+		ReplyCount:          m.ReplyCount,
+	}
+}
+
+// broadcastSystemMessage stores a system notice for the room and sends it to
+// every connected client. Callers must hold s.Mutex.
+func (s *Server) broadcastSystemMessage(ctx context.Context, room *Room, text string) {
+	msg := &db.Message{
+		Username:  "SYSTEM",
+		Content:   text,
+		RoomID:    room.ID,
+		Timestamp: time.Now().Unix(),
+		IsSystem:  true,
+	}
+	if err := s.Store.SaveMessage(ctx, msg); err != nil {
+		log.Printf("Failed to store system message: %v", err)
+	}
+
+	resp := messageToProto(msg)
+	for _, client := range room.Clients {
+		if err := client.Send(resp); err != nil {
+			log.Printf("Failed to send system message: %v", err)
+		}
+	}
+}
+
+// deliverPendingDirectMessages sends the direct messages queued for a user
+// down a newly opened stream, oldest first, marking each one delivered.
+// Callers must hold s.Mutex.
+func (s *Server) deliverPendingDirectMessages(ctx context.Context, userID int32, stream pb.ChatService_JoinRoomServer) {
+	pending, err := s.Store.PendingDirectMessages(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to load queued direct messages: %v", err)
+		return
+	}
+
+	for _, dm := range pending {
+		if err := stream.Send(messageToProto(dm)); err != nil {
+			log.Printf("Failed to send queued direct message: %v", err)
+			return
+		}
+		if err := s.Store.MarkDelivered(ctx, dm.ID, time.Now().Unix()); err != nil {
+			log.Printf("Failed to mark direct message delivered: %v", err)
+		}
+	}
+}
+
+// checkCanSpeak fails unless the user is in the room and not muted there.
+// Callers must hold s.Mutex.
+func (s *Server) checkCanSpeak(ctx context.Context, room *Room, userID int32) error {
+	if _, ok := room.Users[userID]; !ok {
+		return status.Error(codes.PermissionDenied, "user is not in the room")
+	}
+
+	mute, err := s.activeRestriction(ctx, room.ID, userID, db.RestrictionMute)
+	if err != nil {
+		return err
+	}
+	if mute != nil {
+		return status.Errorf(codes.PermissionDenied, "you are muted in this room%s", describeExpiry(mute.ExpiresAt))
+	}
+	return nil
 }
 
 func (s *Server) SendMessage(ctx context.Context, req *pb.SendMessageRequest) (*pb.SendMessageResponse, error) {
@@ -26,10 +103,12 @@ func (s *Server) SendMessage(ctx context.Context, req *pb.SendMessageRequest) (*
 		return nil, status.Error(codes.InvalidArgument, "message cannot be empty")
 	}
 
+	userID := callerID(ctx)
+
 	s.Mutex.Lock()
 	defer s.Mutex.Unlock()
 
-	user, userExists := s.Users[req.UserId]
+	user, userExists := s.Users[userID]
 	if !userExists {
 		return nil, status.Error(codes.NotFound, "user not found")
 	}
@@ -39,30 +118,52 @@ func (s *Server) SendMessage(ctx context.Context, req *pb.SendMessageRequest) (*
 		return nil, status.Error(codes.NotFound, "room not found")
 	}
 
-	if _, ok := room.Users[req.UserId]; !ok {
-		return nil, status.Error(codes.PermissionDenied, "user is not in the room")
+	if err := s.checkCanSpeak(ctx, room, userID); err != nil {
+		return nil, err
+	}
+
+	var threadRoot int32
+	if req.ReplyToMessageId != 0 {
+		root, err := s.threadRoot(ctx, room.ID, req.ReplyToMessageId)
+		if err != nil {
+			return nil, err
+		}
+		threadRoot = root
+	}
+
+	filtered := &FilterMessage{Text: req.Message, UserID: user.ID, RoomID: room.ID}
+	if err := s.Filters.Run(ctx, filtered); err != nil {
+		return nil, err
 	}
 
-	timestamp := time.Now().Unix()
-	msg := &pb.ReceiveMessageResponse{
-		MessageId:  s.NextMsgID,
-		Username:   user.Username,
-		Message:    req.Message,
-		Timestamp:  timestamp,
-		IsSystem:   false,
-		IsDirect:   false,
+	msg := &db.Message{
+		UserID:       user.ID,
+		Username:     user.Username,
+		Content:      filtered.Text,
+		RoomID:       room.ID,
+		Timestamp:    time.Now().Unix(),
+		ReplyToID:    req.ReplyToMessageId,
+		ThreadRootID: threadRoot,
 	}
-	s.NextMsgID++
+	if err := s.Store.SaveMessage(ctx, msg); err != nil {
+		log.Printf("Failed to store message: %v", err)
+		return nil, status.Error(codes.Internal, "failed to store message")
+	}
+
+	// the message is out, so the sender is no longer typing it
+	s.stopTyping(room, user.ID)
 
+	resp := messageToProto(msg)
 	for _, client := range room.Clients {
-		if err := client.Send(msg); err != nil {
+		if err := client.Send(resp); err != nil {
 			log.Printf("Failed to send message: %v", err)
 		}
 	}
 
 	return &pb.SendMessageResponse{
 		Status:    "sent",
-		Timestamp: timestamp,
+		Timestamp: msg.Timestamp,
+		MessageId: msg.ID,
 	}, nil
 }
 
@@ -70,59 +171,92 @@ func (s *Server) SendDirectMessage(ctx context.Context, req *pb.SendDirectMessag
 	if req.Message == "" {
 		return nil, status.Error(codes.InvalidArgument, "message cannot be empty")
 	}
+	// the server cannot read encrypted messages, but it can refuse garbage
+	if req.Encrypted {
+		if _, err := base64.StdEncoding.DecodeString(req.Message); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "encrypted message must be base64")
+		}
+	}
 	
 	s.Mutex.Lock()
 	defer s.Mutex.Unlock()
 	
-	sender, senderExists := s.Users[req.SenderId]
+	sender, senderExists := s.Users[callerID(ctx)]
 	if !senderExists {
 		return nil, status.Error(codes.NotFound, "sender not found")
 	}
 	
-	// find receiver by username
-	var recipientID int32
-	var recipientFound bool
+	filtered := &FilterMessage{Text: req.Message, UserID: sender.ID, Encrypted: req.Encrypted}
+	if err := s.Filters.Run(ctx, filtered); err != nil {
+		return nil, err
+	}
 	
-	for id, user := range s.Users {
-		if user.Username == req.RecipientUsername {
-			recipientID = id
-			recipientFound = true
-			break
+	// find receiver by username, they may not have logged in since the server started
+	recipient, err := s.Store.GetUserByUsername(ctx, req.RecipientUsername)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, "recipient not found")
 		}
+		log.Printf("Failed to look up recipient: %v", err)
+		return nil, status.Error(codes.Internal, "failed to send direct message")
 	}
-	
-	if !recipientFound {
-		return nil, status.Error(codes.NotFound, "recipient not found")
+	if recipient.ID == sender.ID {
+		return nil, status.Error(codes.InvalidArgument, "cannot send a direct message to yourself")
 	}
 	
-	timestamp := time.Now().Unix()
-	dmMsg := &pb.ReceiveMessageResponse{
-		MessageId:  s.NextMsgID,
-		Username:   sender.Username,
-		Message:    req.Message,
-		Timestamp:  timestamp,
-		IsSystem:   false,
-		IsDirect:   true,
-	}
-	s.NextMsgID++
-	
-	// find recipient in any room and send the message
-	recipientFound = false
+	// find recipient in any room
+	var clients []pb.ChatService_JoinRoomServer
 	for _, room := range s.Rooms {
-		if client, ok := room.Clients[recipientID]; ok {
-			recipientFound = true
-			if err := client.Send(dmMsg); err != nil {
-				log.Printf("Failed to send direct message: %v", err)
-			}
+		if client, ok := room.Clients[recipient.ID]; ok {
+			clients = append(clients, client)
 		}
 	}
 	
-	if !recipientFound {
-		return nil, status.Error(codes.Unavailable, "recipient is not connected to any room")
+	conversationID, err := s.Store.ConversationID(ctx, sender.ID, recipient.ID)
+	if err != nil {
+		log.Printf("Failed to open conversation: %v", err)
+		return nil, status.Error(codes.Internal, "failed to store message")
+	}
+	
+	dm := &db.Message{
+		UserID:         sender.ID,
+		Username:       sender.Username,
+		Content:        filtered.Text,
+		RecipientID:    recipient.ID,
+		ConversationID: conversationID,
+		Timestamp:      time.Now().Unix(),
+		IsDirect:       true,
+		Encrypted:      req.Encrypted,
+	}
+	// offline recipients get the message queued until their next JoinRoom
+	if len(clients) > 0 {
+		dm.DeliveredAt = dm.Timestamp
+	}
+	if err := s.Store.SaveMessage(ctx, dm); err != nil {
+		log.Printf("Failed to store direct message: %v", err)
+		return nil, status.Error(codes.Internal, "failed to store message")
+	}
+	// replying to a conversation implies the sender has read it
+	if err := s.Store.MarkConversationRead(ctx, conversationID, sender.ID, dm.Timestamp); err != nil {
+		log.Printf("Failed to mark conversation read: %v", err)
+	}
+	
+	if len(clients) == 0 {
+		return &pb.SendDirectMessageResponse{
+			Status:    "queued",
+			Timestamp: dm.Timestamp,
+		}, nil
+	}
+	
+	dmMsg := messageToProto(dm)
+	for _, client := range clients {
+		if err := client.Send(dmMsg); err != nil {
+			log.Printf("Failed to send direct message: %v", err)
+		}
 	}
 	
 	return &pb.SendDirectMessageResponse{
 		Status:    "sent",
-		Timestamp: timestamp,
+		Timestamp: dm.Timestamp,
 	}, nil
 }
@@ -0,0 +1,409 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ayushsarode/termiXchat/db"
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// roleRanks orders room roles so a user can only act on lower ranks.
+// Non-members rank 0 and server admins outrank every owner.
+var roleRanks = map[string]int{
+	db.RoleMember:    1,
+	db.RoleModerator: 2,
+	db.RoleOwner:     3,
+}
+
+const adminRank = 4
+
+func roleToProto(role string) pb.RoomRole {
+	switch role {
+	case db.RoleOwner:
+		return pb.RoomRole_ROOM_ROLE_OWNER
+	case db.RoleModerator:
+		return pb.RoomRole_ROOM_ROLE_MODERATOR
+	}
+	return pb.RoomRole_ROOM_ROLE_MEMBER
+}
+
+// roomRole returns the user's role in the room, empty for non-members
+func (s *Server) roomRole(ctx context.Context, room *Room, userID int32) (string, error) {
+	// rooms created before roles existed may not list their creator
+	if room.CreatedBy == userID {
+		return db.RoleOwner, nil
+	}
+	m, err := s.Store.GetMembership(ctx, room.ID, userID)
+	if errors.Is(err, db.ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		log.Printf("Failed to load room membership: %v", err)
+		return "", status.Error(codes.Internal, "failed to check room role")
+	}
+	return m.Role, nil
+}
+
+// requireRoomRole fails with PermissionDenied unless the caller holds at
+// least the given role in the room or is an admin, and returns the room
+// with the caller's rank
+func (s *Server) requireRoomRole(ctx context.Context, roomID int32, role string) (*Room, int, error) {
+	userID := callerID(ctx)
+
+	s.Mutex.RLock()
+	room, exists := s.Rooms[roomID]
+	s.Mutex.RUnlock()
+	if !exists {
+		return nil, 0, status.Error(codes.NotFound, "room not found")
+	}
+
+	callerRole, err := s.roomRole(ctx, room, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if rank := roleRanks[callerRole]; rank >= roleRanks[role] {
+		return room, rank, nil
+	}
+
+	user, err := s.Store.GetUser(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to load user: %v", err)
+		return nil, 0, status.Error(codes.Internal, "failed to check room role")
+	}
+	if !user.IsAdmin {
+		return nil, 0, status.Errorf(codes.PermissionDenied, "only a room %s can do that", role)
+	}
+	return room, adminRank, nil
+}
+
+// moderationTarget resolves the user a moderation call acts on and checks
+// that the caller outranks them
+func (s *Server) moderationTarget(ctx context.Context, room *Room, callerRank int, username string) (*db.User, error) {
+	target, err := s.Store.GetUserByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		log.Printf("Failed to load user: %v", err)
+		return nil, status.Error(codes.Internal, "failed to load user")
+	}
+	if target.ID == callerID(ctx) {
+		return nil, status.Error(codes.InvalidArgument, "you cannot moderate yourself")
+	}
+
+	role, err := s.roomRole(ctx, room, target.ID)
+	if err != nil {
+		return nil, err
+	}
+	if roleRanks[role] >= callerRank {
+		return nil, status.Error(codes.PermissionDenied, "you cannot moderate a user of equal or higher role")
+	}
+	return target, nil
+}
+
+// activeRestriction returns the user's unexpired restriction of the given
+// kind in the room, or nil
+func (s *Server) activeRestriction(ctx context.Context, roomID, userID int32, kind string) (*db.Restriction, error) {
+	r, err := s.Store.GetRestriction(ctx, roomID, userID, kind)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Printf("Failed to load room %s: %v", kind, err)
+		return nil, status.Error(codes.Internal, "failed to check room restrictions")
+	}
+	if r.ExpiresAt != 0 && r.ExpiresAt <= time.Now().Unix() {
+		return nil, nil
+	}
+	return r, nil
+}
+
+// checkNotBanned fails with PermissionDenied while the user is banned from
+// the room
+func (s *Server) checkNotBanned(ctx context.Context, roomID, userID int32) error {
+	ban, err := s.activeRestriction(ctx, roomID, userID, db.RestrictionBan)
+	if err != nil || ban == nil {
+		return err
+	}
+	if ban.ExpiresAt == 0 {
+		return status.Error(codes.PermissionDenied, "you are banned from this room")
+	}
+	return status.Errorf(codes.PermissionDenied, "you are banned from this room until %s",
+		time.Unix(ban.ExpiresAt, 0).UTC().Format(time.RFC3339))
+}
+
+// expiry turns a duration in seconds into a Unix time, 0 meaning never
+func expiry(durationSeconds int64) int64 {
+	if durationSeconds == 0 {
+		return 0
+	}
+	return time.Now().Add(time.Duration(durationSeconds) * time.Second).Unix()
+}
+
+// describeExpiry completes a notice such as "bob was muted" with how long
+// it lasts
+func describeExpiry(expiresAt int64) string {
+	if expiresAt == 0 {
+		return ""
+	}
+	return " until " + time.Unix(expiresAt, 0).UTC().Format(time.RFC3339)
+}
+
+// evict disconnects the user from the room, ending their JoinRoom stream
+// with err. Callers must hold s.Mutex.
+func (s *Server) evict(room *Room, userID int32, err error) {
+	if kick, ok := room.Kicks[userID]; ok {
+		select {
+		case kick <- err:
+		default:
+		}
+	}
+	delete(room.Users, userID)
+	delete(room.Clients, userID)
+	delete(room.Kicks, userID)
+	delete(room.TypingStreams, userID)
+	s.stopTyping(room, userID)
+}
+
+// KickUser disconnects a user from a room and revokes their membership, so
+// rejoining takes the same invite, approval or password as a first join
+func (s *Server) KickUser(ctx context.Context, req *pb.KickUserRequest) (*pb.KickUserResponse, error) {
+	room, rank, err := s.requireRoomRole(ctx, req.RoomId, db.RoleModerator)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.moderationTarget(ctx, room, rank, req.Username)
+	if err != nil {
+		return nil, err
+	}
+	moderator := s.callerName(ctx)
+
+	if err := s.Store.RemoveMembership(ctx, room.ID, target.ID); err != nil {
+		log.Printf("Failed to remove room membership: %v", err)
+		return nil, status.Error(codes.Internal, "failed to kick user")
+	}
+	s.audit(ctx, &db.AuditEvent{
+		Action:     db.AuditKick,
+		ActorName:  moderator,
+		TargetID:   target.ID,
+		TargetName: target.Username,
+		RoomID:     room.ID,
+		Reason:     req.Reason,
+	})
+
+	notice := fmt.Sprintf("%s was kicked by %s", target.Username, moderator)
+	if req.Reason != "" {
+		notice += ": " + req.Reason
+	}
+
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	s.evict(room, target.ID, status.Error(codes.PermissionDenied, "you were kicked from the room"))
+	s.broadcastSystemMessage(ctx, room, notice)
+
+	return &pb.KickUserResponse{}, nil
+}
+
+// BanUser keeps a user out of a room, kicking them if they are in it
+func (s *Server) BanUser(ctx context.Context, req *pb.BanUserRequest) (*pb.BanUserResponse, error) {
+	if req.DurationSeconds < 0 {
+		return nil, status.Error(codes.InvalidArgument, "duration cannot be negative")
+	}
+
+	room, rank, err := s.requireRoomRole(ctx, req.RoomId, db.RoleModerator)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.moderationTarget(ctx, room, rank, req.Username)
+	if err != nil {
+		return nil, err
+	}
+	moderator := s.callerName(ctx)
+
+	if req.Lift {
+		if err := s.Store.DeleteRestriction(ctx, room.ID, target.ID, db.RestrictionBan); err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				return nil, status.Error(codes.NotFound, "user is not banned")
+			}
+			log.Printf("Failed to lift ban: %v", err)
+			return nil, status.Error(codes.Internal, "failed to lift ban")
+		}
+		s.audit(ctx, &db.AuditEvent{
+			Action:     db.AuditUnban,
+			ActorName:  moderator,
+			TargetID:   target.ID,
+			TargetName: target.Username,
+			RoomID:     room.ID,
+		})
+		s.Mutex.Lock()
+		s.broadcastSystemMessage(ctx, room, fmt.Sprintf("%s was unbanned by %s", target.Username, moderator))
+		s.Mutex.Unlock()
+		return &pb.BanUserResponse{}, nil
+	}
+
+	ban := &db.Restriction{
+		RoomID:    room.ID,
+		UserID:    target.ID,
+		Kind:      db.RestrictionBan,
+		CreatedBy: callerID(ctx),
+		CreatedAt: time.Now().Unix(),
+		ExpiresAt: expiry(req.DurationSeconds),
+		Reason:    req.Reason,
+	}
+	if err := s.Store.SaveRestriction(ctx, ban); err != nil {
+		log.Printf("Failed to store ban: %v", err)
+		return nil, status.Error(codes.Internal, "failed to ban user")
+	}
+	if err := s.Store.RemoveMembership(ctx, room.ID, target.ID); err != nil {
+		log.Printf("Failed to remove room membership: %v", err)
+	}
+	s.audit(ctx, &db.AuditEvent{
+		Action:     db.AuditBan,
+		ActorName:  moderator,
+		TargetID:   target.ID,
+		TargetName: target.Username,
+		RoomID:     room.ID,
+		Reason:     req.Reason,
+		Details:    auditExpiry(ban.ExpiresAt),
+	})
+
+	notice := fmt.Sprintf("%s was banned by %s%s", target.Username, moderator, describeExpiry(ban.ExpiresAt))
+	if req.Reason != "" {
+		notice += ": " + req.Reason
+	}
+
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	s.evict(room, target.ID, status.Error(codes.PermissionDenied, "you were banned from the room"))
+	s.broadcastSystemMessage(ctx, room, notice)
+
+	return &pb.BanUserResponse{ExpiresAt: ban.ExpiresAt}, nil
+}
+
+// MuteUser stops a user from sending messages to a room
+func (s *Server) MuteUser(ctx context.Context, req *pb.MuteUserRequest) (*pb.MuteUserResponse, error) {
+	if req.DurationSeconds < 0 {
+		return nil, status.Error(codes.InvalidArgument, "duration cannot be negative")
+	}
+
+	room, rank, err := s.requireRoomRole(ctx, req.RoomId, db.RoleModerator)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.moderationTarget(ctx, room, rank, req.Username)
+	if err != nil {
+		return nil, err
+	}
+	moderator := s.callerName(ctx)
+
+	if req.Lift {
+		if err := s.Store.DeleteRestriction(ctx, room.ID, target.ID, db.RestrictionMute); err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				return nil, status.Error(codes.NotFound, "user is not muted")
+			}
+			log.Printf("Failed to lift mute: %v", err)
+			return nil, status.Error(codes.Internal, "failed to lift mute")
+		}
+		s.audit(ctx, &db.AuditEvent{
+			Action:     db.AuditUnmute,
+			ActorName:  moderator,
+			TargetID:   target.ID,
+			TargetName: target.Username,
+			RoomID:     room.ID,
+		})
+		s.Mutex.Lock()
+		s.broadcastSystemMessage(ctx, room, fmt.Sprintf("%s was unmuted by %s", target.Username, moderator))
+		s.Mutex.Unlock()
+		return &pb.MuteUserResponse{}, nil
+	}
+
+	mute := &db.Restriction{
+		RoomID:    room.ID,
+		UserID:    target.ID,
+		Kind:      db.RestrictionMute,
+		CreatedBy: callerID(ctx),
+		CreatedAt: time.Now().Unix(),
+		ExpiresAt: expiry(req.DurationSeconds),
+	}
+	if err := s.Store.SaveRestriction(ctx, mute); err != nil {
+		log.Printf("Failed to store mute: %v", err)
+		return nil, status.Error(codes.Internal, "failed to mute user")
+	}
+	s.audit(ctx, &db.AuditEvent{
+		Action:     db.AuditMute,
+		ActorName:  moderator,
+		TargetID:   target.ID,
+		TargetName: target.Username,
+		RoomID:     room.ID,
+		Details:    auditExpiry(mute.ExpiresAt),
+	})
+
+	s.Mutex.Lock()
+	s.broadcastSystemMessage(ctx, room, fmt.Sprintf("%s was muted by %s%s", target.Username, moderator, describeExpiry(mute.ExpiresAt)))
+	s.Mutex.Unlock()
+
+	return &pb.MuteUserResponse{ExpiresAt: mute.ExpiresAt}, nil
+}
+
+// SetRole promotes a member to moderator or demotes them back
+func (s *Server) SetRole(ctx context.Context, req *pb.SetRoleRequest) (*pb.SetRoleResponse, error) {
+	var role string
+	switch req.Role {
+	case pb.RoomRole_ROOM_ROLE_MEMBER:
+		role = db.RoleMember
+	case pb.RoomRole_ROOM_ROLE_MODERATOR:
+		role = db.RoleModerator
+	default:
+		return nil, status.Error(codes.InvalidArgument, "role must be moderator or member")
+	}
+
+	room, rank, err := s.requireRoomRole(ctx, req.RoomId, db.RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.moderationTarget(ctx, room, rank, req.Username)
+	if err != nil {
+		return nil, err
+	}
+	moderator := s.callerName(ctx)
+
+	if err := s.Store.SetMemberRole(ctx, room.ID, target.ID, role); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, status.Error(codes.FailedPrecondition, "user is not a member of this room")
+		}
+		log.Printf("Failed to update room role: %v", err)
+		return nil, status.Error(codes.Internal, "failed to set role")
+	}
+	s.audit(ctx, &db.AuditEvent{
+		Action:     db.AuditRole,
+		ActorName:  moderator,
+		TargetID:   target.ID,
+		TargetName: target.Username,
+		RoomID:     room.ID,
+		Details:    role,
+	})
+
+	s.Mutex.Lock()
+	s.broadcastSystemMessage(ctx, room, fmt.Sprintf("%s made %s a %s of the room", moderator, target.Username, role))
+	s.Mutex.Unlock()
+
+	return &pb.SetRoleResponse{}, nil
+}
+
+// callerName returns the username of the caller for notices. Callers must
+// not hold s.Mutex.
+func (s *Server) callerName(ctx context.Context) string {
+	s.Mutex.RLock()
+	defer s.Mutex.RUnlock()
+
+	if user, ok := s.Users[callerID(ctx)]; ok {
+		return user.Username
+	}
+	return "a moderator"
+}
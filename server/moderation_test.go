@@ -0,0 +1,219 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestModerationRanks(t *testing.T) {
+	s := newTestServer(t)
+	owner := addTestUser(t, s, "alice")
+	mod := addTestUser(t, s, "bob")
+	member := addTestUser(t, s, "carol")
+	addTestUser(t, s, "dave")
+	admin := addTestUser(t, s, "root")
+	if err := s.Store.SetAdmin(context.Background(), admin, true); err != nil {
+		t.Fatal(err)
+	}
+	room := createTestRoom(t, s, owner, &pb.CreateRoomRequest{Name: "general"})
+	joinTestRoom(t, s, mod, room)
+	joinTestRoom(t, s, member, room)
+	if _, err := s.SetRole(asUser(owner), &pb.SetRoleRequest{RoomId: room, Username: "bob", Role: pb.RoomRole_ROOM_ROLE_MODERATOR}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+		code codes.Code
+	}{
+		{
+			name: "member mutes member",
+			call: func() error {
+				_, err := s.MuteUser(asUser(member), &pb.MuteUserRequest{RoomId: room, Username: "bob"})
+				return err
+			},
+			code: codes.PermissionDenied,
+		},
+		{
+			name: "moderator mutes owner",
+			call: func() error {
+				_, err := s.MuteUser(asUser(mod), &pb.MuteUserRequest{RoomId: room, Username: "alice"})
+				return err
+			},
+			code: codes.PermissionDenied,
+		},
+		{
+			name: "moderator mutes self",
+			call: func() error {
+				_, err := s.MuteUser(asUser(mod), &pb.MuteUserRequest{RoomId: room, Username: "bob"})
+				return err
+			},
+			code: codes.InvalidArgument,
+		},
+		{
+			name: "unknown user",
+			call: func() error {
+				_, err := s.MuteUser(asUser(mod), &pb.MuteUserRequest{RoomId: room, Username: "eve"})
+				return err
+			},
+			code: codes.NotFound,
+		},
+		{
+			name: "negative duration",
+			call: func() error {
+				_, err := s.BanUser(asUser(mod), &pb.BanUserRequest{RoomId: room, Username: "carol", DurationSeconds: -1})
+				return err
+			},
+			code: codes.InvalidArgument,
+		},
+		{
+			name: "moderator sets role",
+			call: func() error {
+				_, err := s.SetRole(asUser(mod), &pb.SetRoleRequest{RoomId: room, Username: "carol", Role: pb.RoomRole_ROOM_ROLE_MODERATOR})
+				return err
+			},
+			code: codes.PermissionDenied,
+		},
+		{
+			name: "make owner",
+			call: func() error {
+				_, err := s.SetRole(asUser(owner), &pb.SetRoleRequest{RoomId: room, Username: "carol", Role: pb.RoomRole_ROOM_ROLE_OWNER})
+				return err
+			},
+			code: codes.InvalidArgument,
+		},
+		{
+			name: "promote non-member",
+			call: func() error {
+				_, err := s.SetRole(asUser(owner), &pb.SetRoleRequest{RoomId: room, Username: "dave", Role: pb.RoomRole_ROOM_ROLE_MODERATOR})
+				return err
+			},
+			code: codes.FailedPrecondition,
+		},
+		{
+			name: "moderator mutes member",
+			call: func() error {
+				_, err := s.MuteUser(asUser(mod), &pb.MuteUserRequest{RoomId: room, Username: "carol"})
+				return err
+			},
+			code: codes.OK,
+		},
+		{
+			name: "admin mutes owner",
+			call: func() error {
+				_, err := s.MuteUser(asUser(admin), &pb.MuteUserRequest{RoomId: room, Username: "alice"})
+				return err
+			},
+			code: codes.OK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("got code %v (%v), want %v", code, err, tt.code)
+			}
+		})
+	}
+}
+
+func TestKickUser(t *testing.T) {
+	s := newTestServer(t)
+	owner := addTestUser(t, s, "alice")
+	bob := addTestUser(t, s, "bob")
+	room := createTestRoom(t, s, owner, &pb.CreateRoomRequest{Name: "locked", Password: "secret"})
+
+	if err := s.Store.AddMembership(context.Background(), room, bob); err != nil {
+		t.Fatal(err)
+	}
+	_, done := joinTestRoom(t, s, bob, room)
+
+	if _, err := s.KickUser(asUser(owner), &pb.KickUserRequest{RoomId: room, Username: "bob", Reason: "spam"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if status.Code(err) != codes.PermissionDenied {
+			t.Errorf("got %v ending the stream, want PermissionDenied", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("kick did not end the stream")
+	}
+
+	// the kick revoked the membership, so the password is needed again
+	if err := s.admit(asUser(bob), s.Rooms[room], bob, ""); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("got %v rejoining without the password, want PermissionDenied", err)
+	}
+}
+
+func TestBanUser(t *testing.T) {
+	s := newTestServer(t)
+	owner := addTestUser(t, s, "alice")
+	bob := addTestUser(t, s, "bob")
+	room := createTestRoom(t, s, owner, &pb.CreateRoomRequest{Name: "general"})
+	_, done := joinTestRoom(t, s, bob, room)
+
+	resp, err := s.BanUser(asUser(owner), &pb.BanUserRequest{RoomId: room, Username: "bob", DurationSeconds: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ExpiresAt == 0 {
+		t.Error("timed ban has no expiry")
+	}
+	select {
+	case err := <-done:
+		if status.Code(err) != codes.PermissionDenied {
+			t.Errorf("got %v ending the stream, want PermissionDenied", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ban did not end the stream")
+	}
+	if err := s.admit(asUser(bob), s.Rooms[room], bob, ""); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("got %v rejoining while banned, want PermissionDenied", err)
+	}
+	if err := s.checkReadAccess(asUser(bob), room); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("got %v reading while banned, want PermissionDenied", err)
+	}
+
+	if _, err := s.BanUser(asUser(owner), &pb.BanUserRequest{RoomId: room, Username: "bob", Lift: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.admit(asUser(bob), s.Rooms[room], bob, ""); err != nil {
+		t.Errorf("got %v rejoining after the ban was lifted", err)
+	}
+	if _, err := s.BanUser(asUser(owner), &pb.BanUserRequest{RoomId: room, Username: "bob", Lift: true}); status.Code(err) != codes.NotFound {
+		t.Errorf("got %v lifting a lifted ban, want NotFound", err)
+	}
+}
+
+func TestMuteUser(t *testing.T) {
+	s := newTestServer(t)
+	owner := addTestUser(t, s, "alice")
+	bob := addTestUser(t, s, "bob")
+	room := createTestRoom(t, s, owner, &pb.CreateRoomRequest{Name: "general"})
+	joinTestRoom(t, s, bob, room)
+
+	if _, err := s.MuteUser(asUser(owner), &pb.MuteUserRequest{RoomId: room, Username: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SendMessage(asUser(bob), &pb.SendMessageRequest{RoomId: room, Message: "hello"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("got %v sending while muted, want PermissionDenied", err)
+	}
+
+	if _, err := s.MuteUser(asUser(owner), &pb.MuteUserRequest{RoomId: room, Username: "bob", Lift: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SendMessage(asUser(bob), &pb.SendMessageRequest{RoomId: room, Message: "hello"}); err != nil {
+		t.Errorf("got %v sending after the mute was lifted", err)
+	}
+	if _, err := s.MuteUser(asUser(owner), &pb.MuteUserRequest{RoomId: room, Username: "bob", Lift: true}); status.Code(err) != codes.NotFound {
+		t.Errorf("got %v lifting a lifted mute, want NotFound", err)
+	}
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ayushsarode/termiXchat/db"
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// resetCodeTTL is how long an admin-issued reset code can be redeemed
+const resetCodeTTL = time.Hour
+
+// ChangePassword replaces the caller's password after checking the current
+// one and signs out every other session of the caller
+func (s *Server) ChangePassword(ctx context.Context, req *pb.ChangePasswordRequest) (*pb.ChangePasswordResponse, error) {
+	if req.NewPassword == "" {
+		return nil, status.Error(codes.InvalidArgument, "new password cannot be empty")
+	}
+
+	userID := callerID(ctx)
+	stored, err := s.Store.GetUser(ctx, userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		log.Printf("Failed to load user: %v", err)
+		return nil, status.Error(codes.Internal, "failed to change password")
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(stored.PasswordHash), []byte(req.CurrentPassword)) != nil {
+		return nil, status.Error(codes.PermissionDenied, "current password is incorrect")
+	}
+
+	if err := s.setPassword(ctx, userID, req.NewPassword); err != nil {
+		return nil, err
+	}
+
+	revoked, err := s.Store.DeleteSessions(ctx, userID, callerSessionID(ctx))
+	if err != nil {
+		log.Printf("Failed to revoke sessions: %v", err)
+		return nil, status.Error(codes.Internal, "password changed but other sessions could not be signed out")
+	}
+
+	return &pb.ChangePasswordResponse{
+		SessionsRevoked: int32(revoked),
+	}, nil
+}
+
+// ResetPassword lets an admin issue a one-time reset code for a user
+func (s *Server) ResetPassword(ctx context.Context, req *pb.ResetPasswordRequest) (*pb.ResetPasswordResponse, error) {
+	admin, err := s.requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := s.Store.GetUserByUsername(ctx, req.Username)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		log.Printf("Failed to load user: %v", err)
+		return nil, status.Error(codes.Internal, "failed to reset password")
+	}
+
+	code, err := newCode()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to generate reset code")
+	}
+
+	now := time.Now()
+	reset := &db.PasswordReset{
+		CodeHash:  hashCode(code),
+		UserID:    target.ID,
+		CreatedAt: now.Unix(),
+		ExpiresAt: now.Add(resetCodeTTL).Unix(),
+	}
+	if err := s.Store.CreatePasswordReset(ctx, reset); err != nil {
+		log.Printf("Failed to store reset code: %v", err)
+		return nil, status.Error(codes.Internal, "failed to reset password")
+	}
+
+	s.audit(ctx, &db.AuditEvent{
+		Action:     db.AuditPasswordReset,
+		ActorName:  admin.Username,
+		TargetID:   target.ID,
+		TargetName: target.Username,
+	})
+	log.Printf("%s issued a password reset code for %s", admin.Username, target.Username)
+
+	return &pb.ResetPasswordResponse{
+		ResetCode: code,
+		ExpiresAt: reset.ExpiresAt,
+	}, nil
+}
+
+// CompletePasswordReset redeems a reset code, setting a new password and
+// signing out every session of the user
+func (s *Server) CompletePasswordReset(ctx context.Context, req *pb.CompletePasswordResetRequest) (*pb.CompletePasswordResetResponse, error) {
+	if req.NewPassword == "" {
+		return nil, status.Error(codes.InvalidArgument, "new password cannot be empty")
+	}
+
+	reset, err := s.Store.TakePasswordReset(ctx, hashCode(req.ResetCode))
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "invalid or expired reset code")
+		}
+		log.Printf("Failed to load reset code: %v", err)
+		return nil, status.Error(codes.Internal, "failed to reset password")
+	}
+	if time.Now().Unix() >= reset.ExpiresAt {
+		return nil, status.Error(codes.InvalidArgument, "invalid or expired reset code")
+	}
+
+	if err := s.setPassword(ctx, reset.UserID, req.NewPassword); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.Store.DeleteSessions(ctx, reset.UserID, ""); err != nil {
+		log.Printf("Failed to revoke sessions: %v", err)
+	}
+
+	user, err := s.Store.GetUser(ctx, reset.UserID)
+	if err != nil {
+		log.Printf("Failed to load user: %v", err)
+		return &pb.CompletePasswordResetResponse{}, nil
+	}
+	// the reset proves who the user is, so a lockout from the forgotten
+	// password should not keep them out
+	if err := s.Store.ClearLoginFailure(ctx, LoginSubject(user.Username)); err != nil {
+		log.Printf("Failed to clear login failures: %v", err)
+	}
+	return &pb.CompletePasswordResetResponse{
+		Username: user.Username,
+	}, nil
+}
+
+// setPassword hashes and stores a new password for the user
+func (s *Server) setPassword(ctx context.Context, userID int32, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return status.Error(codes.Internal, "failed to hash password")
+	}
+
+	if err := s.Store.UpdatePassword(ctx, userID, string(hashedPassword)); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return status.Error(codes.NotFound, "user not found")
+		}
+		log.Printf("Failed to update password: %v", err)
+		return status.Error(codes.Internal, "failed to update password")
+	}
+
+	s.Mutex.Lock()
+	if user, ok := s.Users[userID]; ok {
+		user.Password = string(hashedPassword)
+	}
+	s.Mutex.Unlock()
+	return nil
+}
+
+// newCode generates a random one-time code such as a reset or invite code,
+// formatted as four groups of four characters
+func newCode() (string, error) {
+	// 10 random bytes give a 16 character base32 code
+	raw := make([]byte, 10)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	code := base32.StdEncoding.EncodeToString(raw)
+	return code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16], nil
+}
+
+// hashCode normalises a code from newCode as typed by a user and hashes it,
+// so the database never holds usable codes
+func hashCode(code string) string {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// RateLimit is a token bucket that allows Burst calls at once and refills
+// at Rate calls per second
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// rateLimitedMethods groups the limited RPCs into the categories configured
+// by RATE_LIMIT_<CATEGORY>
+var rateLimitedMethods = map[string]string{
+	pb.ChatService_SendMessage_FullMethodName:           "messages",
+	pb.ChatService_SendDirectMessage_FullMethodName:     "dms",
+	pb.ChatService_CreateRoom_FullMethodName:            "rooms",
+	pb.ChatService_LoginUser_FullMethodName:             "logins",
+	pb.ChatService_CreateUser_FullMethodName:            "logins",
+	pb.ChatService_CompletePasswordReset_FullMethodName: "logins",
+}
+
+// defaultRateLimits apply to categories without a RATE_LIMIT_* variable
+var defaultRateLimits = map[string]RateLimit{
+	"messages": {Rate: 1, Burst: 10},
+	"dms":      {Rate: 0.5, Burst: 5},
+	"rooms":    {Rate: 10.0 / 3600, Burst: 3},
+	"logins":   {Rate: 10.0 / 60, Burst: 5},
+}
+
+// RateLimitsFromEnv reads a limit for each category from RATE_LIMIT_MESSAGES,
+// RATE_LIMIT_DMS, RATE_LIMIT_ROOMS and RATE_LIMIT_LOGINS. A limit is written
+// as "<calls>/<s|m|h>:<burst>", such as "60/m:10", or "off" to disable it.
+func RateLimitsFromEnv() (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit, len(defaultRateLimits))
+	for category, limit := range defaultRateLimits {
+		value := os.Getenv("RATE_LIMIT_" + strings.ToUpper(category))
+		switch value {
+		case "":
+			limits[category] = limit
+		case "off":
+		default:
+			parsed, err := parseRateLimit(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid RATE_LIMIT_%s %q: %v", strings.ToUpper(category), value, err)
+			}
+			limits[category] = parsed
+		}
+	}
+	return limits, nil
+}
+
+func parseRateLimit(value string) (RateLimit, error) {
+	rate, burst, ok := strings.Cut(value, ":")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("missing burst")
+	}
+	calls, unit, ok := strings.Cut(rate, "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("missing time unit")
+	}
+
+	n, err := strconv.ParseFloat(calls, 64)
+	if err != nil || n <= 0 {
+		return RateLimit{}, fmt.Errorf("calls must be a positive number")
+	}
+	b, err := strconv.Atoi(burst)
+	if err != nil || b <= 0 {
+		return RateLimit{}, fmt.Errorf("burst must be a positive integer")
+	}
+
+	var per time.Duration
+	switch unit {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		return RateLimit{}, fmt.Errorf("unit must be s, m or h")
+	}
+
+	return RateLimit{Rate: n / per.Seconds(), Burst: b}, nil
+}
+
+// bucketSweepInterval is how many calls pass between sweeps of idle buckets
+const bucketSweepInterval = 1024
+
+type bucketKey struct {
+	category string
+	// key is "user:<id>" or "ip:<address>"
+	key string
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter throttles calls per method category, keeping one bucket for
+// the authenticated user and one for the peer's IP address. A call needs a
+// token from both.
+type RateLimiter struct {
+	limits  map[string]RateLimit
+	mu      sync.Mutex
+	buckets map[bucketKey]*bucket
+	calls   int
+}
+
+func NewRateLimiter(limits map[string]RateLimit) *RateLimiter {
+	return &RateLimiter{
+		limits:  limits,
+		buckets: make(map[bucketKey]*bucket),
+	}
+}
+
+// take removes a token from each of the keys' buckets. If any bucket is
+// empty nothing is taken and it returns how long until the call would pass.
+func (l *RateLimiter) take(category string, limit RateLimit, keys []string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.calls++
+	if l.calls%bucketSweepInterval == 0 {
+		l.sweep(now)
+	}
+
+	var wait time.Duration
+	buckets := make([]*bucket, 0, len(keys))
+	for _, key := range keys {
+		k := bucketKey{category: category, key: key}
+		b, ok := l.buckets[k]
+		if !ok {
+			b = &bucket{tokens: float64(limit.Burst), last: now}
+			l.buckets[k] = b
+		}
+
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+		b.last = now
+		if b.tokens < 1 {
+			if w := time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second)); w > wait {
+				wait = w
+			}
+		}
+		buckets = append(buckets, b)
+	}
+
+	if wait > 0 {
+		return false, wait
+	}
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return true, 0
+}
+
+// sweep drops buckets that have refilled completely, since a new bucket
+// starts out full anyway. Callers must hold l.mu.
+func (l *RateLimiter) sweep(now time.Time) {
+	for k, b := range l.buckets {
+		limit := l.limits[k.category]
+		if b.tokens+now.Sub(b.last).Seconds()*limit.Rate >= float64(limit.Burst) {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// UnaryInterceptor rejects calls over their category's limit with
+// ResourceExhausted and a retry-after trailer in whole seconds. It must run
+// after the auth interceptor to see the caller.
+func (l *RateLimiter) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	category, ok := rateLimitedMethods[info.FullMethod]
+	if !ok {
+		return handler(ctx, req)
+	}
+	limit, ok := l.limits[category]
+	if !ok {
+		return handler(ctx, req)
+	}
+
+	var keys []string
+	if userID := callerID(ctx); userID != 0 {
+		keys = append(keys, fmt.Sprintf("user:%d", userID))
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		keys = append(keys, "ip:"+host)
+	}
+
+	if allowed, wait := l.take(category, limit, keys); !allowed {
+		retryAfter := int(math.Ceil(wait.Seconds()))
+		grpc.SetTrailer(ctx, metadata.Pairs("retry-after", strconv.Itoa(retryAfter)))
+		return nil, status.Errorf(codes.ResourceExhausted, "too many %s, retry in %ds", category, retryAfter)
+	}
+	return handler(ctx, req)
+}
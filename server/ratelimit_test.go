@@ -0,0 +1,134 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		value string
+		want  RateLimit
+		err   string
+	}{
+		{value: "1/s:1", want: RateLimit{Rate: 1, Burst: 1}},
+		{value: "60/m:10", want: RateLimit{Rate: 1, Burst: 10}},
+		{value: "3600/h:5", want: RateLimit{Rate: 1, Burst: 5}},
+		{value: "0.5/s:2", want: RateLimit{Rate: 0.5, Burst: 2}},
+		{value: "30/m:1", want: RateLimit{Rate: 0.5, Burst: 1}},
+		{value: "", err: "missing burst"},
+		{value: "60/m", err: "missing burst"},
+		{value: "60:10", err: "missing time unit"},
+		{value: "x/s:1", err: "calls must be a positive number"},
+		{value: "0/s:1", err: "calls must be a positive number"},
+		{value: "-1/s:1", err: "calls must be a positive number"},
+		{value: "1/s:0", err: "burst must be a positive integer"},
+		{value: "1/s:1.5", err: "burst must be a positive integer"},
+		{value: "1/d:1", err: "unit must be s, m or h"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseRateLimit(tt.value)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimiterTake(t *testing.T) {
+	limit := RateLimit{Rate: 1, Burst: 2}
+
+	tests := []struct {
+		name string
+		// tokens and idle set up the "user" bucket before the call, nil
+		// leaves it to start out full
+		tokens *float64
+		idle   time.Duration
+		keys   []string
+		// ip is how many tokens the "ip" bucket holds
+		ip      float64
+		allowed bool
+		// wait is the smallest wait the call must report
+		wait time.Duration
+	}{
+		{name: "new bucket is full", keys: []string{"user"}, allowed: true},
+		{name: "last token", tokens: float(1), keys: []string{"user"}, allowed: true},
+		{name: "empty", tokens: float(0), keys: []string{"user"}, wait: 900 * time.Millisecond},
+		{name: "partly refilled", tokens: float(0.5), keys: []string{"user"}, wait: 400 * time.Millisecond},
+		{name: "refilled", tokens: float(0), idle: time.Second, keys: []string{"user"}, allowed: true},
+		{name: "refill stops at burst", tokens: float(0), idle: time.Hour, keys: []string{"user"}, allowed: true},
+		{name: "every key needs a token", tokens: float(2), keys: []string{"user", "ip"}, ip: 0, wait: 900 * time.Millisecond},
+		{name: "every key has a token", tokens: float(2), keys: []string{"user", "ip"}, ip: 1, allowed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewRateLimiter(map[string]RateLimit{"messages": limit})
+			now := time.Now()
+			if tt.tokens != nil {
+				l.buckets[bucketKey{"messages", "user"}] = &bucket{tokens: *tt.tokens, last: now.Add(-tt.idle)}
+			}
+			l.buckets[bucketKey{"messages", "ip"}] = &bucket{tokens: tt.ip, last: now}
+			before := l.buckets[bucketKey{"messages", "user"}]
+			var userTokens float64
+			if before != nil {
+				userTokens = before.tokens
+			}
+
+			allowed, wait := l.take("messages", limit, tt.keys)
+			if allowed != tt.allowed {
+				t.Fatalf("got allowed %v, want %v", allowed, tt.allowed)
+			}
+			if allowed {
+				if wait != 0 {
+					t.Errorf("got wait %v on an allowed call", wait)
+				}
+				if b := l.buckets[bucketKey{"messages", "user"}]; b.tokens > float64(limit.Burst)-1 {
+					t.Errorf("%v tokens left after a call, want at most %d", b.tokens, limit.Burst-1)
+				}
+				return
+			}
+			if wait < tt.wait || wait > time.Second {
+				t.Errorf("got wait %v, want between %v and 1s", wait, tt.wait)
+			}
+			// a refused call takes nothing from any bucket
+			if b := l.buckets[bucketKey{"messages", "user"}]; b.tokens < userTokens {
+				t.Errorf("refused call took a token: %v left, had %v", b.tokens, userTokens)
+			}
+		})
+	}
+}
+
+func TestRateLimiterBurst(t *testing.T) {
+	l := NewRateLimiter(map[string]RateLimit{"rooms": {Rate: 1.0 / 60, Burst: 3}})
+	limit := l.limits["rooms"]
+
+	for i := 0; i < limit.Burst; i++ {
+		if allowed, _ := l.take("rooms", limit, []string{"user:1"}); !allowed {
+			t.Fatalf("call %d of the burst was refused", i+1)
+		}
+	}
+	allowed, wait := l.take("rooms", limit, []string{"user:1"})
+	if allowed || wait <= 59*time.Second || wait > time.Minute {
+		t.Errorf("got allowed %v with wait %v past the burst, want a wait of about a minute", allowed, wait)
+	}
+	// buckets are per key
+	if allowed, _ := l.take("rooms", limit, []string{"user:2"}); !allowed {
+		t.Error("another user was refused")
+	}
+}
+
+func float(f float64) *float64 {
+	return &f
+}
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ayushsarode/termiXchat/db"
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxReactions caps how many different emojis a message can collect
+const maxReactions = 20
+
+// maxEmojiRunes leaves room for emojis joined from several code points, such
+// as families and flags
+const maxEmojiRunes = 10
+
+// emojiShortcodes are the names clients can send instead of an emoji
+var emojiShortcodes = map[string]string{
+	"+1":         "👍",
+	"thumbsup":   "👍",
+	"-1":         "👎",
+	"thumbsdown": "👎",
+	"heart":      "❤️",
+	"joy":        "😂",
+	"smile":      "😄",
+	"wink":       "😉",
+	"cry":        "😢",
+	"thinking":   "🤔",
+	"eyes":       "👀",
+	"tada":       "🎉",
+	"fire":       "🔥",
+	"rocket":     "🚀",
+	"clap":       "👏",
+	"pray":       "🙏",
+	"wave":       "👋",
+	"100":        "💯",
+	"check":      "✅",
+	"x":          "❌",
+}
+
+// normalizeEmoji resolves a shortcode and checks that what is left looks
+// like a single emoji rather than text
+func normalizeEmoji(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if len(value) > 2 && strings.HasPrefix(value, ":") && strings.HasSuffix(value, ":") {
+		emoji, ok := emojiShortcodes[strings.ToLower(value[1:len(value)-1])]
+		if !ok {
+			return "", status.Errorf(codes.InvalidArgument, "unknown emoji shortcode %s", value)
+		}
+		return emoji, nil
+	}
+
+	if value == "" || utf8.RuneCountInString(value) > maxEmojiRunes {
+		return "", status.Error(codes.InvalidArgument, "reaction must be a single emoji")
+	}
+	for _, r := range value {
+		// zero width joiners hold multi-part emojis together
+		if r <= unicode.MaxASCII || unicode.IsLetter(r) || unicode.IsDigit(r) || (!unicode.IsGraphic(r) && r != '\u200d') {
+			return "", status.Error(codes.InvalidArgument, "reaction must be a single emoji")
+		}
+	}
+	return value, nil
+}
+
+// reactionsToProto converts the counted reactions of one message
+func reactionsToProto(reactions []*db.Reaction) []*pb.Reaction {
+	result := make([]*pb.Reaction, 0, len(reactions))
+	for _, r := range reactions {
+		result = append(result, &pb.Reaction{Emoji: r.Emoji, Count: r.Count})
+	}
+	return result
+}
+
+// attachReactions fills in the reactions of room messages about to be sent
+// to a client
+func (s *Server) attachReactions(ctx context.Context, messages []*pb.ReceiveMessageResponse) error {
+	ids := make([]int32, 0, len(messages))
+	for _, msg := range messages {
+		ids = append(ids, msg.MessageId)
+	}
+	reactions, err := s.Store.MessageReactions(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, msg := range messages {
+		msg.Reactions = reactionsToProto(reactions[msg.MessageId])
+	}
+	return nil
+}
+
+// reactableMessage loads a room message for a caller in that room. Callers
+// must hold s.Mutex.
+func (s *Server) reactableMessage(ctx context.Context, id int32) (*db.Message, *Room, error) {
+	msg, err := s.Store.GetMessage(ctx, id)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, nil, status.Error(codes.NotFound, "message not found")
+		}
+		log.Printf("Failed to load message: %v", err)
+		return nil, nil, status.Error(codes.Internal, "failed to load message")
+	}
+	if msg.IsDirect || msg.IsSystem {
+		return nil, nil, status.Error(codes.FailedPrecondition, "can only react to room messages")
+	}
+	if msg.DeletedAt != 0 {
+		return nil, nil, status.Error(codes.FailedPrecondition, "message was deleted")
+	}
+
+	room, exists := s.Rooms[msg.RoomID]
+	if !exists {
+		return nil, nil, status.Error(codes.NotFound, "room not found")
+	}
+	if _, ok := room.Users[callerID(ctx)]; !ok {
+		return nil, nil, status.Error(codes.PermissionDenied, "user is not in the room")
+	}
+	return msg, room, nil
+}
+
+// broadcastReactions counts the reactions to a message after a change and
+// sends them to every client in the room. Callers must hold s.Mutex.
+func (s *Server) broadcastReactions(ctx context.Context, room *Room, msg *db.Message) ([]*pb.Reaction, error) {
+	counted, err := s.Store.MessageReactions(ctx, []int32{msg.ID})
+	if err != nil {
+		log.Printf("Failed to count reactions: %v", err)
+		return nil, status.Error(codes.Internal, "failed to count reactions")
+	}
+	reactions := reactionsToProto(counted[msg.ID])
+
+	resp := messageToProto(msg)
+	resp.Event = pb.MessageEvent_MESSAGE_EVENT_REACTIONS
+	resp.Reactions = reactions
+	for _, client := range room.Clients {
+		if err := client.Send(resp); err != nil {
+			log.Printf("Failed to send reactions: %v", err)
+		}
+	}
+	return reactions, nil
+}
+
+// AddReaction reacts to a room message with an emoji
+func (s *Server) AddReaction(ctx context.Context, req *pb.AddReactionRequest) (*pb.AddReactionResponse, error) {
+	emoji, err := normalizeEmoji(req.Emoji)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	msg, room, err := s.reactableMessage(ctx, req.MessageId)
+	if err != nil {
+		return nil, err
+	}
+
+	userID := callerID(ctx)
+	mute, err := s.activeRestriction(ctx, room.ID, userID, db.RestrictionMute)
+	if err != nil {
+		return nil, err
+	}
+	if mute != nil {
+		return nil, status.Errorf(codes.PermissionDenied, "you are muted in this room%s", describeExpiry(mute.ExpiresAt))
+	}
+
+	existing, err := s.Store.MessageReactions(ctx, []int32{msg.ID})
+	if err != nil {
+		log.Printf("Failed to count reactions: %v", err)
+		return nil, status.Error(codes.Internal, "failed to add reaction")
+	}
+	counted := existing[msg.ID]
+	if len(counted) >= maxReactions && !slices.ContainsFunc(counted, func(r *db.Reaction) bool { return r.Emoji == emoji }) {
+		return nil, status.Errorf(codes.FailedPrecondition, "a message can have at most %d different reactions", maxReactions)
+	}
+
+	if err := s.Store.AddReaction(ctx, msg.ID, userID, emoji, time.Now().Unix()); err != nil {
+		if errors.Is(err, db.ErrConflict) {
+			return nil, status.Error(codes.AlreadyExists, "you already reacted with that emoji")
+		}
+		log.Printf("Failed to add reaction: %v", err)
+		return nil, status.Error(codes.Internal, "failed to add reaction")
+	}
+
+	reactions, err := s.broadcastReactions(ctx, room, msg)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.AddReactionResponse{Reactions: reactions}, nil
+}
+
+// RemoveReaction takes back the caller's reaction to a room message
+func (s *Server) RemoveReaction(ctx context.Context, req *pb.RemoveReactionRequest) (*pb.RemoveReactionResponse, error) {
+	emoji, err := normalizeEmoji(req.Emoji)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	msg, room, err := s.reactableMessage(ctx, req.MessageId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.Store.RemoveReaction(ctx, msg.ID, callerID(ctx), emoji); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, "you have not reacted with that emoji")
+		}
+		log.Printf("Failed to remove reaction: %v", err)
+		return nil, status.Error(codes.Internal, "failed to remove reaction")
+	}
+
+	reactions, err := s.broadcastReactions(ctx, room, msg)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.RemoveReactionResponse{Reactions: reactions}, nil
+}
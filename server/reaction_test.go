@@ -0,0 +1,153 @@
+package server
+
+import (
+	"testing"
+
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestNormalizeEmoji(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+		code  codes.Code
+	}{
+		{value: "👍", want: "👍"},
+		{value: " 🎉 ", want: "🎉"},
+		{value: ":+1:", want: "👍"},
+		{value: ":Rocket:", want: "🚀"},
+		{value: "👨‍👩‍👧", want: "👨‍👩‍👧"},
+		{value: ":nope:", code: codes.InvalidArgument},
+		{value: "", code: codes.InvalidArgument},
+		{value: "lol", code: codes.InvalidArgument},
+		{value: "👍a", code: codes.InvalidArgument},
+		{value: "é", code: codes.InvalidArgument},
+		{value: "👍👍👍👍👍👍👍👍👍👍👍", code: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := normalizeEmoji(tt.value)
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("got code %v (%v), want %v", code, err, tt.code)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReactions(t *testing.T) {
+	s := newTestServer(t)
+	alice := addTestUser(t, s, "alice")
+	bob := addTestUser(t, s, "bob")
+	carol := addTestUser(t, s, "carol")
+	room := createTestRoom(t, s, alice, &pb.CreateRoomRequest{Name: "general"})
+	aliceStream, _ := joinTestRoom(t, s, alice, room)
+	joinTestRoom(t, s, bob, room)
+	msg := sendTestMessage(t, s, alice, room, "hello")
+
+	if _, err := s.AddReaction(asUser(alice), &pb.AddReactionRequest{MessageId: msg, Emoji: "👍"}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := s.AddReaction(asUser(bob), &pb.AddReactionRequest{MessageId: msg, Emoji: ":+1:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Reactions) != 1 || resp.Reactions[0].Emoji != "👍" || resp.Reactions[0].Count != 2 {
+		t.Errorf("got reactions %v, want 👍 twice", resp.Reactions)
+	}
+	event := aliceStream.last()
+	if event.MessageId != msg || event.Event != pb.MessageEvent_MESSAGE_EVENT_REACTIONS || len(event.Reactions) != 1 {
+		t.Errorf("got event %v, want the counted reactions to message %d", event, msg)
+	}
+
+	removed, err := s.RemoveReaction(asUser(alice), &pb.RemoveReactionRequest{MessageId: msg, Emoji: "👍"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed.Reactions) != 1 || removed.Reactions[0].Count != 1 {
+		t.Errorf("got reactions %v after removing one, want 👍 once", removed.Reactions)
+	}
+
+	history, err := s.GetMessageHistory(asUser(alice), &pb.GetMessageHistoryRequest{RoomId: room})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := history.Messages[len(history.Messages)-1]; len(last.Reactions) != 1 || last.Reactions[0].Count != 1 {
+		t.Errorf("got reactions %v in history, want 👍 once", last.Reactions)
+	}
+
+	deleted := sendTestMessage(t, s, alice, room, "gone")
+	if _, err := s.DeleteMessage(asUser(alice), &pb.DeleteMessageRequest{MessageId: deleted}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		user   int32
+		msg    int32
+		emoji  string
+		remove bool
+		code   codes.Code
+	}{
+		{name: "twice", user: bob, msg: msg, emoji: "👍", code: codes.AlreadyExists},
+		{name: "not an emoji", user: bob, msg: msg, emoji: "hi", code: codes.InvalidArgument},
+		{name: "unknown message", user: bob, msg: deleted + 100, emoji: "👍", code: codes.NotFound},
+		{name: "deleted message", user: bob, msg: deleted, emoji: "👍", code: codes.FailedPrecondition},
+		{name: "not in the room", user: carol, msg: msg, emoji: "👍", code: codes.PermissionDenied},
+		{name: "remove missing", user: alice, msg: msg, emoji: "👍", remove: true, code: codes.NotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if tt.remove {
+				_, err = s.RemoveReaction(asUser(tt.user), &pb.RemoveReactionRequest{MessageId: tt.msg, Emoji: tt.emoji})
+			} else {
+				_, err = s.AddReaction(asUser(tt.user), &pb.AddReactionRequest{MessageId: tt.msg, Emoji: tt.emoji})
+			}
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("got code %v (%v), want %v", code, err, tt.code)
+			}
+		})
+	}
+
+	if _, err := s.MuteUser(asUser(alice), &pb.MuteUserRequest{RoomId: room, Username: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddReaction(asUser(bob), &pb.AddReactionRequest{MessageId: msg, Emoji: "🎉"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("got %v reacting while muted, want PermissionDenied", err)
+	}
+}
+
+func TestReactionLimit(t *testing.T) {
+	s := newTestServer(t)
+	alice := addTestUser(t, s, "alice")
+	room := createTestRoom(t, s, alice, &pb.CreateRoomRequest{Name: "general"})
+	joinTestRoom(t, s, alice, room)
+	msg := sendTestMessage(t, s, alice, room, "hello")
+
+	// consecutive animal emojis, each different
+	for i := 0; i < maxReactions; i++ {
+		emoji := string(rune('🐀' + i))
+		if _, err := s.AddReaction(asUser(alice), &pb.AddReactionRequest{MessageId: msg, Emoji: emoji}); err != nil {
+			t.Fatalf("reaction %d %s: %v", i+1, emoji, err)
+		}
+	}
+
+	over := string(rune('🐀' + maxReactions))
+	if _, err := s.AddReaction(asUser(alice), &pb.AddReactionRequest{MessageId: msg, Emoji: over}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("got %v for one reaction too many, want FailedPrecondition", err)
+	}
+
+	// more of an emoji already on the message is always fine
+	bob := addTestUser(t, s, "bob")
+	joinTestRoom(t, s, bob, room)
+	if _, err := s.AddReaction(asUser(bob), &pb.AddReactionRequest{MessageId: msg, Emoji: "🐀"}); err != nil {
+		t.Errorf("got %v repeating an existing reaction", err)
+	}
+}
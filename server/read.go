@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/ayushsarode/termiXchat/db"
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// markRead moves a member's read position in the room forward and tells the
+// others in the room when it moved. Callers must hold s.Mutex.
+func (s *Server) markRead(ctx context.Context, room *Room, user *User, messageID int32) (bool, error) {
+	moved, err := s.Store.MarkRoomRead(ctx, room.ID, user.ID, messageID)
+	if err != nil || !moved {
+		return moved, err
+	}
+
+	event := &pb.ReceiveMessageResponse{
+		MessageId: messageID,
+		Username:  user.Username,
+		Timestamp: time.Now().Unix(),
+		Event:     pb.MessageEvent_MESSAGE_EVENT_READ,
+	}
+	for id, client := range room.Clients {
+		if id == user.ID {
+			continue
+		}
+		if err := client.Send(event); err != nil {
+			log.Printf("Failed to send read position: %v", err)
+		}
+	}
+	return true, nil
+}
+
+// MarkRead records that the caller has read a room up to a message
+func (s *Server) MarkRead(ctx context.Context, req *pb.MarkReadRequest) (*pb.MarkReadResponse, error) {
+	if req.MessageId < 0 {
+		return nil, status.Error(codes.InvalidArgument, "message ID cannot be negative")
+	}
+
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	user, exists := s.Users[callerID(ctx)]
+	if !exists {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	room, exists := s.Rooms[req.RoomId]
+	if !exists {
+		return nil, status.Error(codes.NotFound, "room not found")
+	}
+
+	membership, err := s.Store.GetMembership(ctx, room.ID, user.ID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, status.Error(codes.FailedPrecondition, "you are not a member of this room")
+		}
+		log.Printf("Failed to load room membership: %v", err)
+		return nil, status.Error(codes.Internal, "failed to mark room read")
+	}
+
+	messageID := req.MessageId
+	if messageID == 0 {
+		newest, _, err := s.Store.RoomHistory(ctx, room.ID, 0, 0, 1)
+		if err != nil {
+			log.Printf("Failed to load room history: %v", err)
+			return nil, status.Error(codes.Internal, "failed to mark room read")
+		}
+		if len(newest) == 0 {
+			return &pb.MarkReadResponse{LastReadMessageId: membership.LastReadID}, nil
+		}
+		messageID = newest[0].ID
+	} else {
+		msg, err := s.Store.GetMessage(ctx, messageID)
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				return nil, status.Error(codes.NotFound, "message not found")
+			}
+			log.Printf("Failed to load message: %v", err)
+			return nil, status.Error(codes.Internal, "failed to mark room read")
+		}
+		if msg.IsDirect || msg.RoomID != room.ID {
+			return nil, status.Error(codes.InvalidArgument, "message is not in this room")
+		}
+	}
+
+	moved, err := s.markRead(ctx, room, user, messageID)
+	if err != nil {
+		log.Printf("Failed to mark room read: %v", err)
+		return nil, status.Error(codes.Internal, "failed to mark room read")
+	}
+	if !moved {
+		// the member had already read further
+		messageID = membership.LastReadID
+	}
+	return &pb.MarkReadResponse{LastReadMessageId: messageID}, nil
+}
+
+// GetReadReceipts lists the members who have read a room message
+func (s *Server) GetReadReceipts(ctx context.Context, req *pb.GetReadReceiptsRequest) (*pb.GetReadReceiptsResponse, error) {
+	msg, err := s.Store.GetMessage(ctx, req.MessageId)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, "message not found")
+		}
+		log.Printf("Failed to load message: %v", err)
+		return nil, status.Error(codes.Internal, "failed to load read receipts")
+	}
+	if msg.IsDirect {
+		return nil, status.Error(codes.InvalidArgument, "only room messages have read receipts")
+	}
+
+	if err := s.checkReadAccess(ctx, msg.RoomID); err != nil {
+		return nil, err
+	}
+
+	readers, err := s.Store.RoomReaders(ctx, msg.RoomID, msg.ID)
+	if err != nil {
+		log.Printf("Failed to load read receipts: %v", err)
+		return nil, status.Error(codes.Internal, "failed to load read receipts")
+	}
+
+	resp := &pb.GetReadReceiptsResponse{Readers: make([]*pb.ReadReceipt, 0, len(readers))}
+	for _, r := range readers {
+		if r.UserID == msg.UserID {
+			continue
+		}
+		resp.Readers = append(resp.Readers, &pb.ReadReceipt{Username: r.Username, LastReadMessageId: r.LastReadID})
+	}
+	return resp, nil
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"testing"
+
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// unreadCounts returns what GetRoomInfo and ListRooms report unread in the
+// room for the user
+func unreadCounts(t *testing.T, s *Server, userID, roomID int32) (int32, int32) {
+	t.Helper()
+	info, err := s.GetRoomInfo(asUser(userID), &pb.GetRoomInfoRequest{RoomId: roomID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := s.ListRooms(asUser(userID), &pb.ListRoomsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, room := range list.Rooms {
+		if room.RoomId == roomID {
+			return info.UnreadCount, room.UnreadCount
+		}
+	}
+	t.Fatalf("room %d not listed", roomID)
+	return 0, 0
+}
+
+func TestMarkRead(t *testing.T) {
+	s := newTestServer(t)
+	alice := addTestUser(t, s, "alice")
+	bob := addTestUser(t, s, "bob")
+	carol := addTestUser(t, s, "carol")
+	room := createTestRoom(t, s, alice, &pb.CreateRoomRequest{Name: "general"})
+	other := createTestRoom(t, s, alice, &pb.CreateRoomRequest{Name: "random"})
+	aliceStream, _ := joinTestRoom(t, s, alice, room)
+	joinTestRoom(t, s, bob, room)
+	joinTestRoom(t, s, alice, other)
+
+	var messages []int32
+	for _, text := range []string{"one", "two", "three"} {
+		messages = append(messages, sendTestMessage(t, s, alice, room, text))
+	}
+	elsewhere := sendTestMessage(t, s, alice, other, "elsewhere")
+
+	if info, list := unreadCounts(t, s, bob, room); info != 3 || list != 3 {
+		t.Errorf("got %d and %d unread, want 3", info, list)
+	}
+	// nobody has unread messages of their own
+	if info, list := unreadCounts(t, s, alice, room); info != 0 || list != 0 {
+		t.Errorf("got %d and %d unread for the sender, want 0", info, list)
+	}
+
+	tests := []struct {
+		name    string
+		user    int32
+		room    int32
+		message int32
+		want    int32
+		unread  int32
+		code    codes.Code
+	}{
+		{name: "negative", user: bob, room: room, message: -1, code: codes.InvalidArgument},
+		{name: "unknown room", user: bob, room: other + 100, message: messages[0], code: codes.NotFound},
+		{name: "not a member", user: carol, room: room, message: messages[0], code: codes.FailedPrecondition},
+		{name: "unknown message", user: bob, room: room, message: elsewhere + 100, code: codes.NotFound},
+		{name: "other room", user: bob, room: room, message: elsewhere, code: codes.InvalidArgument},
+		{name: "up to a message", user: bob, room: room, message: messages[1], want: messages[1], unread: 1},
+		{name: "backwards", user: bob, room: room, message: messages[0], want: messages[1], unread: 1},
+		{name: "newest", user: bob, room: room, want: messages[2], unread: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := s.MarkRead(asUser(tt.user), &pb.MarkReadRequest{RoomId: tt.room, MessageId: tt.message})
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("got code %v (%v), want %v", code, err, tt.code)
+			}
+			if err != nil {
+				return
+			}
+			if resp.LastReadMessageId != tt.want {
+				t.Errorf("got read up to %d, want %d", resp.LastReadMessageId, tt.want)
+			}
+			if info, list := unreadCounts(t, s, tt.user, tt.room); info != tt.unread || list != tt.unread {
+				t.Errorf("got %d and %d unread, want %d", info, list, tt.unread)
+			}
+		})
+	}
+
+	// the others in the room hear where bob got to
+	event := aliceStream.last()
+	if event.Event != pb.MessageEvent_MESSAGE_EVENT_READ || event.Username != "bob" || event.MessageId != messages[2] {
+		t.Errorf("got event %v, want bob read up to %d", event, messages[2])
+	}
+}
+
+func TestGetReadReceipts(t *testing.T) {
+	s := newTestServer(t)
+	alice := addTestUser(t, s, "alice")
+	bob := addTestUser(t, s, "bob")
+	carol := addTestUser(t, s, "carol")
+	dave := addTestUser(t, s, "dave")
+	room := createTestRoom(t, s, alice, &pb.CreateRoomRequest{Name: "private", Visibility: pb.RoomVisibility_ROOM_VISIBILITY_PRIVATE})
+	for _, user := range []int32{bob, carol} {
+		invite, err := s.CreateInvite(asUser(alice), &pb.CreateInviteRequest{RoomId: room, MaxUses: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.AcceptInvite(asUser(user), &pb.AcceptInviteRequest{InviteCode: invite.InviteCode}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	joinTestRoom(t, s, alice, room)
+
+	first := sendTestMessage(t, s, alice, room, "one")
+	second := sendTestMessage(t, s, alice, room, "two")
+	if _, err := s.MarkRead(asUser(alice), &pb.MarkReadRequest{RoomId: room}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.MarkRead(asUser(bob), &pb.MarkReadRequest{RoomId: room, MessageId: second}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.MarkRead(asUser(carol), &pb.MarkReadRequest{RoomId: room, MessageId: first}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SendDirectMessage(asUser(alice), &pb.SendDirectMessageRequest{RecipientUsername: "bob", Message: "psst"}); err != nil {
+		t.Fatal(err)
+	}
+	dm, err := s.GetConversationHistory(asUser(alice), &pb.GetConversationHistoryRequest{PeerUsername: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		user    int32
+		message int32
+		readers []string
+		code    codes.Code
+	}{
+		{name: "first", user: alice, message: first, readers: []string{"bob", "carol"}},
+		{name: "second", user: bob, message: second, readers: []string{"bob"}},
+		{name: "unknown message", user: alice, message: dm.Messages[0].MessageId + 100, code: codes.NotFound},
+		{name: "direct message", user: alice, message: dm.Messages[0].MessageId, code: codes.InvalidArgument},
+		{name: "not a member", user: dave, message: first, code: codes.NotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := s.GetReadReceipts(asUser(tt.user), &pb.GetReadReceiptsRequest{MessageId: tt.message})
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("got code %v (%v), want %v", code, err, tt.code)
+			}
+			if err != nil {
+				return
+			}
+			// the author is never listed as a reader
+			var got []string
+			for _, r := range resp.Readers {
+				got = append(got, r.Username)
+			}
+			if len(got) != len(tt.readers) {
+				t.Fatalf("got readers %q, want %q", got, tt.readers)
+			}
+			for i := range got {
+				if got[i] != tt.readers[i] {
+					t.Errorf("got readers %q, want %q", got, tt.readers)
+				}
+			}
+		})
+	}
+}
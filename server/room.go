@@ -2,21 +2,64 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
+	"github.com/ayushsarode/termiXchat/db"
 	pb "github.com/ayushsarode/termiXchat/proto"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
 type Room struct {
-	ID        int32
-	Name      string
-	CreatedAt int64
-	Users     map[int32]*User
-	Clients   map[int32]pb.ChatService_JoinRoomServer
+	ID           int32
+	Name         string
+	CreatedAt    int64
+	CreatedBy    int32
+	Metadata     map[string]string
+	Visibility   string
+	PasswordHash string
+	Users        map[int32]*User
+	Clients      map[int32]pb.ChatService_JoinRoomServer
+	// Kicks ends a user's JoinRoom stream with the error sent on it
+	Kicks map[int32]chan error
+	// Typing holds the users typing in the room until their signal expires
+	Typing map[int32]*typingState
+	// TypingStreams receive the users typing in the room as it changes
+	TypingStreams map[int32]pb.ChatService_TypingServer
+}
+
+// newRoom wraps a stored room with empty presence maps
+func newRoom(r *db.Room) *Room {
+	return &Room{
+		ID:            r.ID,
+		Name:          r.Name,
+		CreatedAt:     r.CreatedAt,
+		CreatedBy:     r.CreatedBy,
+		Metadata:      r.Metadata,
+		Visibility:    r.Visibility,
+		PasswordHash:  r.PasswordHash,
+		Users:         make(map[int32]*User),
+		Clients:       make(map[int32]pb.ChatService_JoinRoomServer),
+		Kicks:         make(map[int32]chan error),
+		Typing:        make(map[int32]*typingState),
+		TypingStreams: make(map[int32]pb.ChatService_TypingServer),
+	}
+}
+
+// loadRooms rehydrates the in-memory room map from the database
+func (s *Server) loadRooms(ctx context.Context) error {
+	rooms, err := s.Store.ListRooms(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, room := range rooms {
+		s.Rooms[room.ID] = newRoom(room)
+	}
+	return nil
 }
 
 func (s *Server) CreateRoom(ctx context.Context, req *pb.CreateRoomRequest) (*pb.CreateRoomResponse, error) {
@@ -24,6 +67,15 @@ func (s *Server) CreateRoom(ctx context.Context, req *pb.CreateRoomRequest) (*pb
 		return nil, status.Error(codes.InvalidArgument, "room name cannot be empty")
 	}
 
+	visibility, err := visibilityFromProto(req.Visibility)
+	if err != nil {
+		return nil, err
+	}
+	passwordHash, err := hashRoomPassword(req.Password)
+	if err != nil {
+		return nil, err
+	}
+
 	s.Mutex.Lock()
 	defer s.Mutex.Unlock()
 
@@ -33,19 +85,49 @@ func (s *Server) CreateRoom(ctx context.Context, req *pb.CreateRoomRequest) (*pb
 		}
 	}
 
-	roomID := s.NextRoomID
-	s.NextRoomID++
+	creatorID := callerID(ctx)
+	creator, ok := s.Users[creatorID]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+
+	// the database assigns the ID so it is never reused across restarts
+	room := &db.Room{
+		Name:         req.Name,
+		CreatedAt:    time.Now().Unix(),
+		CreatedBy:    creatorID,
+		Metadata:     req.Metadata,
+		Visibility:   visibility,
+		PasswordHash: passwordHash,
+	}
+	if room.Metadata == nil {
+		room.Metadata = make(map[string]string)
+	}
+	if err := s.Store.CreateRoom(ctx, room); err != nil {
+		if errors.Is(err, db.ErrConflict) {
+			return nil, status.Error(codes.AlreadyExists, "room name already exists")
+		}
+		log.Printf("Failed to create room: %v", err)
+		return nil, status.Error(codes.Internal, "failed to create room")
+	}
 
-	s.Rooms[roomID] = &Room{
-		ID:        roomID,
-		Name:      req.Name,
-		CreatedAt: time.Now().Unix(),
-		Users:     make(map[int32]*User),
-		Clients:   make(map[int32]pb.ChatService_JoinRoomServer),
+	// the creator owns the room and is its first member
+	if err := s.Store.AddMembership(ctx, room.ID, creatorID); err != nil {
+		log.Printf("Failed to record room membership: %v", err)
+	} else if err := s.Store.SetMemberRole(ctx, room.ID, creatorID, db.RoleOwner); err != nil {
+		log.Printf("Failed to record room owner: %v", err)
 	}
 
+	s.Rooms[room.ID] = newRoom(room)
+	s.audit(ctx, &db.AuditEvent{
+		Action:    db.AuditRoomCreate,
+		ActorName: creator.Username,
+		RoomID:    room.ID,
+		Details:   describeAccess(room.Visibility, room.PasswordHash != ""),
+	})
+
 	return &pb.CreateRoomResponse{
-		RoomId: roomID,
+		RoomId: room.ID,
 		Name:   req.Name,
 	}, nil
 }
@@ -60,24 +142,74 @@ func (s *Server) GetRoomInfo(ctx context.Context, req *pb.GetRoomInfoRequest) (*
 		return nil, status.Error(codes.NotFound, "room not found")
 	}
 	
+	// private rooms do not exist as far as non-members can tell
+	if room.Visibility == db.VisibilityPrivate {
+		member, err := s.isMember(ctx, room, callerID(ctx))
+		if err != nil {
+			return nil, err
+		}
+		if !member {
+			return nil, status.Error(codes.NotFound, "room not found")
+		}
+	}
+	
+	// only members have a read position
+	var lastRead, unread int32
+	membership, err := s.Store.GetMembership(ctx, room.ID, callerID(ctx))
+	if err == nil {
+		unread, err = s.Store.RoomUnreadCount(ctx, room.ID, callerID(ctx))
+		if err != nil {
+			log.Printf("Failed to count unread messages: %v", err)
+			return nil, status.Error(codes.Internal, "failed to count unread messages")
+		}
+		lastRead = membership.LastReadID
+	} else if !errors.Is(err, db.ErrNotFound) {
+		log.Printf("Failed to load room membership: %v", err)
+		return nil, status.Error(codes.Internal, "failed to load room")
+	}
+	
 	return &pb.GetRoomInfoResponse{
-		RoomId:    room.ID,
-		Name:      room.Name,
-		UserCount: int32(len(room.Users)),
-		CreatedAt: room.CreatedAt,
+		RoomId:            room.ID,
+		Name:              room.Name,
+		UserCount:         int32(len(room.Users)),
+		CreatedAt:         room.CreatedAt,
+		CreatedBy:         room.CreatedBy,
+		Metadata:          room.Metadata,
+		Visibility:        visibilityToProto(room.Visibility),
+		HasPassword:       room.PasswordHash != "",
+		UnreadCount:       unread,
+		LastReadMessageId: lastRead,
 	}, nil
 }
 
 func (s *Server) ListRooms(ctx context.Context, req *pb.ListRoomsRequest) (*pb.ListRoomsResponse, error) {
+	userID := callerID(ctx)
+	member, err := s.memberRooms(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	unread, err := s.Store.RoomUnreadCounts(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to count unread messages: %v", err)
+		return nil, status.Error(codes.Internal, "failed to count unread messages")
+	}
+	
 	s.Mutex.RLock()
 	defer s.Mutex.RUnlock()
 	
 	rooms := make([]*pb.RoomInfo, 0, len(s.Rooms))
 	for _, room := range s.Rooms {
+		// unlisted and private rooms only show up for their members
+		if room.Visibility != db.VisibilityPublic && !member[room.ID] && room.CreatedBy != userID {
+			continue
+		}
 		rooms = append(rooms, &pb.RoomInfo{
-			RoomId:    room.ID,
-			Name:      room.Name,
-			UserCount: int32(len(room.Users)),
+			RoomId:      room.ID,
+			Name:        room.Name,
+			UserCount:   int32(len(room.Users)),
+			Visibility:  visibilityToProto(room.Visibility),
+			HasPassword: room.PasswordHash != "",
+			UnreadCount: unread[room.ID],
 		})
 	}
 	
@@ -88,9 +220,11 @@ func (s *Server) ListRooms(ctx context.Context, req *pb.ListRoomsRequest) (*pb.L
 
 
 func (s *Server) JoinRoom(req *pb.JoinRoomRequest, stream pb.ChatService_JoinRoomServer) error {
+	userID := callerID(stream.Context())
+
 	s.Mutex.Lock()
 	
-	user, userExists := s.Users[req.UserId]
+	user, userExists := s.Users[userID]
 	if !userExists {
 		s.Mutex.Unlock()
 		return status.Error(codes.NotFound, "user not found")
@@ -102,32 +236,78 @@ func (s *Server) JoinRoom(req *pb.JoinRoomRequest, stream pb.ChatService_JoinRoo
 		return status.Error(codes.NotFound, "room not found")
 	}
 	
-	// add user to room
-	room.Users[req.UserId] = user
-	room.Clients[req.UserId] = stream
+	if err := s.admit(stream.Context(), room, user.ID, req.Password); err != nil {
+		s.Mutex.Unlock()
+		return err
+	}
 	
-	// Create system message for user joining
-	joinMsg := &pb.ReceiveMessageResponse{
-		MessageId:  s.NextMsgID,
-		Username:   "SYSTEM",
-		Message:    fmt.Sprintf("%s has joined the room", user.Username),
-		Timestamp:  time.Now().Unix(),
-		IsSystem:   true,
-		IsDirect:   false,
+	// replay recent history before the client starts receiving live traffic
+	limit := int(req.HistoryLimit)
+	if limit == 0 {
+		limit = defaultHistoryLimit
+	} else if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+	var newest int32
+	if limit > 0 {
+		history, _, err := s.Store.RoomHistory(stream.Context(), room.ID, 0, 0, limit)
+		if err != nil {
+			s.Mutex.Unlock()
+			log.Printf("Failed to load room history: %v", err)
+			return status.Error(codes.Internal, "failed to load room history")
+		}
+		replay := make([]*pb.ReceiveMessageResponse, 0, len(history))
+		for _, msg := range history {
+			replay = append(replay, messageToProto(msg))
+		}
+		if err := s.attachReactions(stream.Context(), replay); err != nil {
+			s.Mutex.Unlock()
+			log.Printf("Failed to load reactions: %v", err)
+			return status.Error(codes.Internal, "failed to load room history")
+		}
+		for _, msg := range replay {
+			if err := stream.Send(msg); err != nil {
+				s.Mutex.Unlock()
+				return err
+			}
+			newest = msg.MessageId
+		}
 	}
-	s.NextMsgID++
 	
-	// brodcast joining message to all users in that room
-	for _, client := range room.Clients {
-		if err := client.Send(joinMsg); err != nil {
-			log.Printf("Failed to send join message: %v", err)
+	if err := s.Store.AddMembership(stream.Context(), room.ID, user.ID); err != nil {
+		s.Mutex.Unlock()
+		log.Printf("Failed to record room membership: %v", err)
+		return status.Error(codes.Internal, "failed to join room")
+	}
+	
+	// the replayed history counts as read
+	if newest > 0 {
+		if _, err := s.markRead(stream.Context(), room, user, newest); err != nil {
+			log.Printf("Failed to mark room read: %v", err)
 		}
 	}
 	
+	// add user to room
+	kick := make(chan error, 1)
+	room.Users[userID] = user
+	room.Clients[userID] = stream
+	room.Kicks[userID] = kick
+	
+	// brodcast joining message to all users in that room
+	s.broadcastSystemMessage(stream.Context(), room, fmt.Sprintf("%s has joined the room", user.Username))
+	
+	// hand over direct messages that arrived while the user was offline
+	s.deliverPendingDirectMessages(stream.Context(), user.ID, stream)
+	
 	s.Mutex.Unlock()
 	
-	// Keep the connection alive until the client disconnects
-	<-stream.Context().Done()
+	// Keep the connection alive until the client disconnects or is kicked,
+	// in which case the kick already removed them from the room
+	select {
+	case <-stream.Context().Done():
+	case err := <-kick:
+		return err
+	}
 	
 	// Handle disconnection
 	s.Mutex.Lock()
@@ -135,35 +315,25 @@ func (s *Server) JoinRoom(req *pb.JoinRoomRequest, stream pb.ChatService_JoinRoo
 	
 	// Check if room and user still exist
 	if room, ok := s.Rooms[req.RoomId]; ok {
-		delete(room.Users, req.UserId)
-		delete(room.Clients, req.UserId)
+		delete(room.Users, userID)
+		delete(room.Clients, userID)
+		delete(room.Kicks, userID)
+		s.stopTyping(room, userID)
 		
-		// Notify other users about the leave
-		leaveMsg := &pb.ReceiveMessageResponse{
-			MessageId:  s.NextMsgID,
-			Username:   "SYSTEM",
-			Message:    fmt.Sprintf("%s has left the room", user.Username),
-			Timestamp:  time.Now().Unix(),
-			IsSystem:   true,
-			IsDirect:   false,
-		}
-		s.NextMsgID++
-		
-		for _, client := range room.Clients {
-			if err := client.Send(leaveMsg); err != nil {
-				log.Printf("Failed to send leave message: %v", err)
-			}
-		}
+		// Notify other users about the leave, the stream context is already done
+		s.broadcastSystemMessage(context.Background(), room, fmt.Sprintf("%s has left the room", user.Username))
 	}
 	
 	return nil
 }
 
 func (s *Server) LeaveRoom(ctx context.Context, req *pb.LeaveRoomRequest) (*pb.LeaveRoomResponse, error) {
+	userID := callerID(ctx)
+
 	s.Mutex.Lock()
 	defer s.Mutex.Unlock()
 	
-	user, userExists := s.Users[req.UserId]
+	user, userExists := s.Users[userID]
 	if !userExists {
 		return nil, status.Error(codes.NotFound, "user not found")
 	}
@@ -174,33 +344,18 @@ func (s *Server) LeaveRoom(ctx context.Context, req *pb.LeaveRoomRequest) (*pb.L
 	}
 	
 	// check if user is in the room
-	if _, ok := room.Users[req.UserId]; !ok {
+	if _, ok := room.Users[userID]; !ok {
 		return &pb.LeaveRoomResponse{
 			Success: false,
 			Message: "user is not in the room",
 		}, nil
 	}
 	
-	// remove the user from room
-	delete(room.Users, req.UserId)
-	delete(room.Clients, req.UserId)
+	// remove the user from room, ending their JoinRoom stream
+	s.evict(room, userID, nil)
 	
 	// notify other users bout user has left the room
-	leaveMsg := &pb.ReceiveMessageResponse{
-		MessageId:  s.NextMsgID,
-		Username:   "SYSTEM",
-		Message:    fmt.Sprintf("%s has left the room", user.Username),
-		Timestamp:  time.Now().Unix(),
-		IsSystem:   true,
-		IsDirect:   false,
-	}
-	s.NextMsgID++
-	
-	for _, client := range room.Clients {
-		if err := client.Send(leaveMsg); err != nil {
-			log.Printf("Failed to send leave message: %v", err)
-		}
-	}
+	s.broadcastSystemMessage(ctx, room, fmt.Sprintf("%s has left the room", user.Username))
 	
 	return &pb.LeaveRoomResponse{
 		Success: true,
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/ayushsarode/termiXchat/db"
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultSearchLimit is the page size used when a search does not set one
+const defaultSearchLimit = 20
+
+// maxSearchLimit caps the page size of a single search
+const maxSearchLimit = 100
+
+// SearchMessages runs a full-text search over stored room messages
+func (s *Server) SearchMessages(ctx context.Context, req *pb.SearchMessagesRequest) (*pb.SearchMessagesResponse, error) {
+	if strings.TrimSpace(req.Query) == "" {
+		return nil, status.Error(codes.InvalidArgument, "search query cannot be empty")
+	}
+	if req.Limit < 0 || req.Offset < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit and offset cannot be negative")
+	}
+
+	limit := int(req.Limit)
+	if limit == 0 {
+		limit = defaultSearchLimit
+	} else if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	q := db.SearchQuery{
+		Text:     req.Query,
+		RoomID:   req.RoomId,
+		Username: req.Username,
+		Since:    req.Since,
+		Until:    req.Until,
+		Limit:    limit,
+		Offset:   int(req.Offset),
+	}
+	if req.RoomId != 0 {
+		if err := s.checkReadAccess(ctx, req.RoomId); err != nil {
+			return nil, err
+		}
+	} else {
+		rooms, err := s.searchableRooms(ctx)
+		if err != nil {
+			return nil, err
+		}
+		q.RoomIDs = rooms
+	}
+
+	results, hasMore, err := s.Store.SearchMessages(ctx, q)
+	if err != nil {
+		log.Printf("Failed to search messages: %v", err)
+		return nil, status.Error(codes.Internal, "failed to search messages")
+	}
+
+	resp := &pb.SearchMessagesResponse{
+		Hits:    make([]*pb.SearchHit, 0, len(results)),
+		HasMore: hasMore,
+	}
+	for _, result := range results {
+		resp.Hits = append(resp.Hits, &pb.SearchHit{
+			Message:  messageToProto(result.Message),
+			RoomId:   result.Message.RoomID,
+			RoomName: result.RoomName,
+			Rank:     result.Rank,
+		})
+	}
+	return resp, nil
+}
+
+// searchableRooms lists the rooms a search across every room covers: public
+// rooms without a password and the rooms the caller is a member of
+func (s *Server) searchableRooms(ctx context.Context) ([]int32, error) {
+	userID := callerID(ctx)
+	member, err := s.memberRooms(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Mutex.RLock()
+	defer s.Mutex.RUnlock()
+
+	rooms := make([]int32, 0, len(s.Rooms))
+	for _, room := range s.Rooms {
+		if (room.Visibility == db.VisibilityPublic && room.PasswordHash == "") || member[room.ID] || room.CreatedBy == userID {
+			rooms = append(rooms, room.ID)
+		}
+	}
+	return rooms, nil
+}
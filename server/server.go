@@ -1,8 +1,11 @@
 package server
 
 import (
-"database/sql"
+	"context"
+	"errors"
+	"fmt"
 	"sync"
+	"time"
 
 	pb "github.com/ayushsarode/termiXchat/proto"
 	"github.com/ayushsarode/termiXchat/db"
@@ -10,27 +13,33 @@ import (
 
 type Server struct {
 	pb.UnimplementedChatServiceServer
-	Mutex      sync.RWMutex
-	Users      map[int32]*User
-	Rooms      map[int32]*Room
-	NextUserID int32
-	NextRoomID int32
-	NextMsgID  int32
-	DB 		   *sql.DB
+	Mutex sync.RWMutex
+	Users map[int32]*User
+	Rooms map[int32]*Room
+	Store db.Store
+	Auth  AuthConfig
+	// Filters check room messages and direct messages before delivery
+	Filters *FilterPipeline
+	// EditWindow limits how long authors can edit their messages, 0 means
+	// forever
+	EditWindow time.Duration
 }
 
-func NewServer() (*Server, error) {
-	database, err := db.InitDB()
-	if err != nil {
-		return nil, err
+func NewServer(store db.Store, auth AuthConfig) (*Server, error) {
+	if len(auth.Secret) == 0 {
+		return nil, errors.New("auth secret cannot be empty")
 	}
 
-	return &Server{
-		Users:      make(map[int32]*User),
-		Rooms:      make(map[int32]*Room),
-		NextUserID: 1,
-		NextRoomID: 1,
-		NextMsgID:  1,
-		DB:         database,
-	}, nil
+	srv := &Server{
+		Users: make(map[int32]*User),
+		Rooms: make(map[int32]*Room),
+		Store: store,
+		Auth:  auth,
+	}
+
+	if err := srv.loadRooms(context.Background()); err != nil {
+		return nil, fmt.Errorf("failed to load rooms: %v", err)
+	}
+
+	return srv, nil
 }
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/ayushsarode/termiXchat/db"
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// threadRoot finds the thread a reply to messageID belongs to. Threads are
+// flat, so replying to a reply joins the thread of the message it answered.
+func (s *Server) threadRoot(ctx context.Context, roomID, messageID int32) (int32, error) {
+	parent, err := s.Store.GetMessage(ctx, messageID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return 0, status.Error(codes.NotFound, "message to reply to not found")
+		}
+		log.Printf("Failed to load message: %v", err)
+		return 0, status.Error(codes.Internal, "failed to load message")
+	}
+	if parent.IsDirect || parent.RoomID != roomID {
+		return 0, status.Error(codes.InvalidArgument, "can only reply to messages in the same room")
+	}
+	if parent.DeletedAt != 0 {
+		return 0, status.Error(codes.FailedPrecondition, "message was deleted")
+	}
+
+	if parent.ThreadRootID != 0 {
+		return parent.ThreadRootID, nil
+	}
+	return parent.ID, nil
+}
+
+// GetThread returns the first message of a thread and one page of its
+// replies, oldest first
+func (s *Server) GetThread(ctx context.Context, req *pb.GetThreadRequest) (*pb.GetThreadResponse, error) {
+	if req.Limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit cannot be negative")
+	}
+
+	limit := int(req.Limit)
+	if limit == 0 {
+		limit = defaultHistoryLimit
+	} else if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+
+	root, err := s.Store.GetMessage(ctx, req.MessageId)
+	if err == nil && root.ThreadRootID != 0 {
+		root, err = s.Store.GetMessage(ctx, root.ThreadRootID)
+	}
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, "message not found")
+		}
+		log.Printf("Failed to load message: %v", err)
+		return nil, status.Error(codes.Internal, "failed to load thread")
+	}
+	if root.IsDirect {
+		return nil, status.Error(codes.InvalidArgument, "only room messages have threads")
+	}
+
+	if err := s.checkReadAccess(ctx, root.RoomID); err != nil {
+		return nil, err
+	}
+
+	replies, hasMore, err := s.Store.ThreadReplies(ctx, root.ID, req.AfterId, limit)
+	if err != nil {
+		log.Printf("Failed to load thread: %v", err)
+		return nil, status.Error(codes.Internal, "failed to load thread")
+	}
+
+	resp := &pb.GetThreadResponse{
+		Message: messageToProto(root),
+		Replies: make([]*pb.ReceiveMessageResponse, 0, len(replies)),
+		HasMore: hasMore,
+	}
+	for _, reply := range replies {
+		resp.Replies = append(resp.Replies, messageToProto(reply))
+	}
+	if err := s.attachReactions(ctx, append([]*pb.ReceiveMessageResponse{resp.Message}, resp.Replies...)); err != nil {
+		log.Printf("Failed to load reactions: %v", err)
+		return nil, status.Error(codes.Internal, "failed to load thread")
+	}
+	return resp, nil
+}
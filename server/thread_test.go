@@ -0,0 +1,136 @@
+package server
+
+import (
+	"testing"
+
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestReplies(t *testing.T) {
+	s := newTestServer(t)
+	alice := addTestUser(t, s, "alice")
+	room := createTestRoom(t, s, alice, &pb.CreateRoomRequest{Name: "general"})
+	other := createTestRoom(t, s, alice, &pb.CreateRoomRequest{Name: "random"})
+	stream, _ := joinTestRoom(t, s, alice, room)
+	joinTestRoom(t, s, alice, other)
+
+	root := sendTestMessage(t, s, alice, room, "question")
+	elsewhere := sendTestMessage(t, s, alice, other, "elsewhere")
+	deleted := sendTestMessage(t, s, alice, room, "gone")
+	if _, err := s.DeleteMessage(asUser(alice), &pb.DeleteMessageRequest{MessageId: deleted}); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := s.SendMessage(asUser(alice), &pb.SendMessageRequest{RoomId: room, Message: "answer", ReplyToMessageId: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		replyTo int32
+		parent  int32
+		root    int32
+		code    codes.Code
+	}{
+		{name: "reply to a reply", replyTo: first.MessageId, parent: first.MessageId, root: root, code: codes.OK},
+		{name: "unknown message", replyTo: deleted + 100, code: codes.NotFound},
+		{name: "other room", replyTo: elsewhere, code: codes.InvalidArgument},
+		{name: "deleted message", replyTo: deleted, code: codes.FailedPrecondition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.SendMessage(asUser(alice), &pb.SendMessageRequest{RoomId: room, Message: "reply", ReplyToMessageId: tt.replyTo})
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("got code %v (%v), want %v", code, err, tt.code)
+			}
+			if err != nil {
+				return
+			}
+			// the reply keeps the message it answers and joins its thread
+			got := stream.last()
+			if got.ReplyToMessageId != tt.parent || got.ThreadRootMessageId != tt.root {
+				t.Errorf("got reply to %d in thread %d, want reply to %d in thread %d",
+					got.ReplyToMessageId, got.ThreadRootMessageId, tt.parent, tt.root)
+			}
+		})
+	}
+}
+
+func TestGetThread(t *testing.T) {
+	s := newTestServer(t)
+	alice := addTestUser(t, s, "alice")
+	bob := addTestUser(t, s, "bob")
+	room := createTestRoom(t, s, alice, &pb.CreateRoomRequest{Name: "private", Visibility: pb.RoomVisibility_ROOM_VISIBILITY_PRIVATE})
+	joinTestRoom(t, s, alice, room)
+
+	root := sendTestMessage(t, s, alice, room, "question")
+	var replies []int32
+	replyTo := root
+	for _, text := range []string{"one", "two", "three"} {
+		resp, err := s.SendMessage(asUser(alice), &pb.SendMessageRequest{RoomId: room, Message: text, ReplyToMessageId: replyTo})
+		if err != nil {
+			t.Fatal(err)
+		}
+		replies = append(replies, resp.MessageId)
+		replyTo = resp.MessageId
+	}
+
+	tests := []struct {
+		name    string
+		req     *pb.GetThreadRequest
+		replies []string
+		hasMore bool
+	}{
+		{name: "from the root", req: &pb.GetThreadRequest{MessageId: root}, replies: []string{"one", "two", "three"}},
+		{name: "from a reply", req: &pb.GetThreadRequest{MessageId: replies[2]}, replies: []string{"one", "two", "three"}},
+		{name: "first page", req: &pb.GetThreadRequest{MessageId: root, Limit: 2}, replies: []string{"one", "two"}, hasMore: true},
+		{name: "next page", req: &pb.GetThreadRequest{MessageId: root, AfterId: replies[1], Limit: 2}, replies: []string{"three"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			thread, err := s.GetThread(asUser(alice), tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if thread.Message.MessageId != root || thread.Message.ReplyCount != 3 {
+				t.Errorf("got root %d with %d replies, want %d with 3", thread.Message.MessageId, thread.Message.ReplyCount, root)
+			}
+			var got []string
+			for _, reply := range thread.Replies {
+				got = append(got, reply.Message)
+			}
+			if len(got) != len(tt.replies) || thread.HasMore != tt.hasMore {
+				t.Fatalf("got %q (more: %v), want %q (more: %v)", got, thread.HasMore, tt.replies, tt.hasMore)
+			}
+			for i := range got {
+				if got[i] != tt.replies[i] {
+					t.Errorf("got %q, want %q", got, tt.replies)
+				}
+			}
+		})
+	}
+
+	errTests := []struct {
+		name string
+		user int32
+		req  *pb.GetThreadRequest
+		code codes.Code
+	}{
+		{name: "negative limit", user: alice, req: &pb.GetThreadRequest{MessageId: root, Limit: -1}, code: codes.InvalidArgument},
+		{name: "unknown message", user: alice, req: &pb.GetThreadRequest{MessageId: root + 100}, code: codes.NotFound},
+		{name: "private room", user: bob, req: &pb.GetThreadRequest{MessageId: replies[0]}, code: codes.NotFound},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.GetThread(asUser(tt.user), tt.req)
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("got code %v (%v), want %v", code, err, tt.code)
+			}
+		})
+	}
+}
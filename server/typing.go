@@ -0,0 +1,159 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"log"
+	"sort"
+	"time"
+
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// typingTimeout is how long a typing signal lasts unless it is sent again.
+// Clients repeat it while the user keeps typing.
+const typingTimeout = 6 * time.Second
+
+// typingState is a user typing in a room, cleared when expiry fires
+type typingState struct {
+	username string
+	expiry   *time.Timer
+}
+
+// typingUpdate lists the users typing in the room other than userID
+func typingUpdate(room *Room, userID int32) *pb.TypingUpdate {
+	update := &pb.TypingUpdate{RoomId: room.ID}
+	for id, state := range room.Typing {
+		if id != userID {
+			update.Usernames = append(update.Usernames, state.username)
+		}
+	}
+	sort.Strings(update.Usernames)
+	return update
+}
+
+// broadcastTyping sends the users typing in the room to every typing stream
+// except that of the user whose state changed. Callers must hold s.Mutex.
+func (s *Server) broadcastTyping(room *Room, changed int32) {
+	for id, stream := range room.TypingStreams {
+		if id == changed {
+			continue
+		}
+		if err := stream.Send(typingUpdate(room, id)); err != nil {
+			log.Printf("Failed to send typing update: %v", err)
+		}
+	}
+}
+
+// startTyping marks a user in the room as typing. Repeated signals only
+// push the expiry back, so the room hears about each user once. Callers
+// must hold s.Mutex and have checked that the user can speak in the room.
+func (s *Server) startTyping(room *Room, userID int32) {
+	if state, ok := room.Typing[userID]; ok {
+		state.expiry.Reset(typingTimeout)
+		return
+	}
+
+	state := &typingState{username: room.Users[userID].Username}
+	state.expiry = time.AfterFunc(typingTimeout, func() {
+		s.Mutex.Lock()
+		defer s.Mutex.Unlock()
+
+		// a later signal may have replaced this one
+		if room.Typing[userID] == state {
+			delete(room.Typing, userID)
+			s.broadcastTyping(room, userID)
+		}
+	})
+	room.Typing[userID] = state
+	s.broadcastTyping(room, userID)
+}
+
+// stopTyping clears a user's typing signal, for instance once they sent
+// their message. Callers must hold s.Mutex.
+func (s *Server) stopTyping(room *Room, userID int32) {
+	state, ok := room.Typing[userID]
+	if !ok {
+		return
+	}
+	state.expiry.Stop()
+	delete(room.Typing, userID)
+	s.broadcastTyping(room, userID)
+}
+
+// Typing relays a user's typing signals to the others in a room and sends
+// them who else is typing there
+func (s *Server) Typing(stream pb.ChatService_TypingServer) error {
+	ctx := stream.Context()
+	userID := callerID(ctx)
+
+	req, err := stream.Recv()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return err
+	}
+	if err := s.checkReadAccess(ctx, req.RoomId); err != nil {
+		return err
+	}
+
+	s.Mutex.Lock()
+	room, exists := s.Rooms[req.RoomId]
+	if !exists {
+		s.Mutex.Unlock()
+		return status.Error(codes.NotFound, "room not found")
+	}
+	member, err := s.isMember(ctx, room, userID)
+	if err != nil {
+		s.Mutex.Unlock()
+		return err
+	}
+	if !member {
+		s.Mutex.Unlock()
+		return status.Error(codes.PermissionDenied, "you are not a member of this room")
+	}
+	// a newer stream from the same user replaces the older one
+	room.TypingStreams[userID] = stream
+	if err := stream.Send(typingUpdate(room, userID)); err != nil {
+		delete(room.TypingStreams, userID)
+		s.Mutex.Unlock()
+		return err
+	}
+	s.Mutex.Unlock()
+
+	defer func() {
+		s.Mutex.Lock()
+		defer s.Mutex.Unlock()
+
+		if room.TypingStreams[userID] == stream {
+			delete(room.TypingStreams, userID)
+			s.stopTyping(room, userID)
+		}
+	}()
+
+	for {
+		if req.RoomId != room.ID {
+			return status.Error(codes.InvalidArgument, "typing requests must name the room the stream was opened for")
+		}
+
+		// typing from someone who cannot speak is dropped, but the stream
+		// stays open so it works again once a mute is lifted
+		s.Mutex.Lock()
+		if !req.Typing {
+			s.stopTyping(room, userID)
+		} else if err := s.checkCanSpeak(ctx, room, userID); err == nil {
+			s.startTyping(room, userID)
+		}
+		s.Mutex.Unlock()
+
+		if req, err = stream.Recv(); err != nil {
+			if errors.Is(err, io.EOF) || status.Code(err) == codes.Canceled {
+				return nil
+			}
+			return err
+		}
+	}
+}
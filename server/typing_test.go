@@ -0,0 +1,207 @@
+package server
+
+import (
+	"context"
+	"io"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/ayushsarode/termiXchat/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testTypingStream is a Typing stream fed from a channel that records the
+// updates the server sends
+type testTypingStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	recv chan *pb.TypingRequest
+
+	mu      sync.Mutex
+	updates [][]string
+}
+
+func (st *testTypingStream) Context() context.Context {
+	return st.ctx
+}
+
+func (st *testTypingStream) Recv() (*pb.TypingRequest, error) {
+	select {
+	case req, ok := <-st.recv:
+		if !ok {
+			return nil, io.EOF
+		}
+		return req, nil
+	case <-st.ctx.Done():
+		return nil, status.FromContextError(st.ctx.Err()).Err()
+	}
+}
+
+func (st *testTypingStream) Send(update *pb.TypingUpdate) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.updates = append(st.updates, update.Usernames)
+	return nil
+}
+
+// signal sends a typing request and returns once the server handled it.
+// Requests are unbuffered and handled one at a time, so the server only
+// takes the repeated request once it is done with the first, and repeating
+// one changes nothing.
+func (st *testTypingStream) signal(roomID int32, typing bool) {
+	for range 2 {
+		st.recv <- &pb.TypingRequest{RoomId: roomID, Typing: typing}
+	}
+}
+
+// typing returns who the last update said was typing, and how many updates
+// there were
+func (st *testTypingStream) typing() ([]string, int) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if len(st.updates) == 0 {
+		return nil, 0
+	}
+	return st.updates[len(st.updates)-1], len(st.updates)
+}
+
+// openTyping opens a typing stream for the room and waits for the server to
+// accept it. The returned channel gets Typing's result once the stream ends.
+func openTyping(t *testing.T, s *Server, userID, roomID int32) (*testTypingStream, <-chan error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(asUser(userID))
+	t.Cleanup(cancel)
+	stream := &testTypingStream{ctx: ctx, recv: make(chan *pb.TypingRequest)}
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Typing(stream)
+	}()
+
+	select {
+	case stream.recv <- &pb.TypingRequest{RoomId: roomID}:
+	case err := <-done:
+		t.Fatalf("open typing stream: %v", err)
+	}
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if _, n := stream.typing(); n > 0 {
+			return stream, done
+		}
+		select {
+		case err := <-done:
+			t.Fatalf("open typing stream: %v", err)
+		default:
+		}
+	}
+	t.Fatal("timed out opening the typing stream")
+	return nil, nil
+}
+
+func TestTyping(t *testing.T) {
+	s := newTestServer(t)
+	alice := addTestUser(t, s, "alice")
+	bob := addTestUser(t, s, "bob")
+	room := createTestRoom(t, s, alice, &pb.CreateRoomRequest{Name: "general"})
+	joinTestRoom(t, s, alice, room)
+	joinTestRoom(t, s, bob, room)
+
+	aliceTyping, _ := openTyping(t, s, alice, room)
+	bobTyping, bobDone := openTyping(t, s, bob, room)
+
+	bobTyping.signal(room, true)
+	if who, _ := aliceTyping.typing(); !slices.Equal(who, []string{"bob"}) {
+		t.Errorf("got %q typing, want bob", who)
+	}
+	// a repeated signal only keeps it alive
+	_, before := aliceTyping.typing()
+	bobTyping.signal(room, true)
+	if _, after := aliceTyping.typing(); after != before {
+		t.Errorf("got %d more updates for a repeated signal, want none", after-before)
+	}
+
+	// sending the message clears the signal
+	sendTestMessage(t, s, bob, room, "hello")
+	if who, _ := aliceTyping.typing(); len(who) != 0 {
+		t.Errorf("got %q typing after the message was sent, want nobody", who)
+	}
+
+	bobTyping.signal(room, true)
+	bobTyping.signal(room, false)
+	if who, _ := aliceTyping.typing(); len(who) != 0 {
+		t.Errorf("got %q typing after bob stopped, want nobody", who)
+	}
+
+	// typing while muted is dropped without closing the stream
+	if _, err := s.MuteUser(asUser(alice), &pb.MuteUserRequest{RoomId: room, Username: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	_, before = aliceTyping.typing()
+	bobTyping.signal(room, true)
+	if _, after := aliceTyping.typing(); after != before {
+		t.Errorf("got %d updates for a muted user, want none", after-before)
+	}
+	select {
+	case err := <-bobDone:
+		t.Fatalf("muting ended the typing stream: %v", err)
+	default:
+	}
+
+	if _, err := s.MuteUser(asUser(alice), &pb.MuteUserRequest{RoomId: room, Username: "bob", Lift: true}); err != nil {
+		t.Fatal(err)
+	}
+	bobTyping.signal(room, true)
+	if who, _ := aliceTyping.typing(); !slices.Equal(who, []string{"bob"}) {
+		t.Errorf("got %q typing after the mute was lifted, want bob", who)
+	}
+
+	// closing the stream clears the signal too
+	close(bobTyping.recv)
+	if err := <-bobDone; err != nil {
+		t.Fatal(err)
+	}
+	if who, _ := aliceTyping.typing(); len(who) != 0 {
+		t.Errorf("got %q typing after bob closed the stream, want nobody", who)
+	}
+}
+
+func TestTypingErrors(t *testing.T) {
+	s := newTestServer(t)
+	alice := addTestUser(t, s, "alice")
+	bob := addTestUser(t, s, "bob")
+	room := createTestRoom(t, s, alice, &pb.CreateRoomRequest{Name: "general"})
+	private := createTestRoom(t, s, alice, &pb.CreateRoomRequest{Name: "private", Visibility: pb.RoomVisibility_ROOM_VISIBILITY_PRIVATE})
+	joinTestRoom(t, s, alice, room)
+
+	tests := []struct {
+		name     string
+		user     int32
+		requests []*pb.TypingRequest
+		code     codes.Code
+	}{
+		{name: "unknown room", user: alice, requests: []*pb.TypingRequest{{RoomId: private + 100}}, code: codes.NotFound},
+		{name: "private room", user: bob, requests: []*pb.TypingRequest{{RoomId: private}}, code: codes.NotFound},
+		{name: "not a member", user: bob, requests: []*pb.TypingRequest{{RoomId: room}}, code: codes.PermissionDenied},
+		{name: "switching rooms", user: alice, requests: []*pb.TypingRequest{{RoomId: room}, {RoomId: private}}, code: codes.InvalidArgument},
+		{name: "closed at once", user: alice, code: codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(asUser(tt.user))
+			defer cancel()
+			stream := &testTypingStream{ctx: ctx, recv: make(chan *pb.TypingRequest, len(tt.requests))}
+			for _, req := range tt.requests {
+				stream.recv <- req
+			}
+			close(stream.recv)
+
+			err := s.Typing(stream)
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("got code %v (%v), want %v", code, err, tt.code)
+			}
+		})
+	}
+}
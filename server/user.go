@@ -2,11 +2,12 @@ package server
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
+	"github.com/ayushsarode/termiXchat/db"
 	pb "github.com/ayushsarode/termiXchat/proto"
 	"golang.org/x/crypto/bcrypt"
 	"google.golang.org/grpc/codes"
@@ -24,6 +25,9 @@ func (s *Server) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb
 	if req.Username == "" || req.Password == "" {
 		return nil, status.Error(codes.InvalidArgument, "username and password are required")
 	}
+	if isReservedUsername(req.Username) {
+		return nil, status.Error(codes.InvalidArgument, "username is reserved")
+	}
 
 	// Hash the password before storing
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
@@ -31,84 +35,90 @@ func (s *Server) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb
 		return nil, status.Error(codes.Internal, "failed to hash password")
 	}
 
-	// Check if username already exists
-	var exists bool
-	err = s.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE username = $1)", req.Username).Scan(&exists)
-	if err != nil {
-		return nil, status.Error(codes.Internal, fmt.Sprintf("database error: %v", err))
-	}
-	if exists {
-		return nil, status.Error(codes.AlreadyExists, "username already exists")
-	}
-
-	// Insert user into database
-	var userID int32
-	err = s.DB.QueryRow(
-		"INSERT INTO users (username, password) VALUES ($1, $2) RETURNING id",
-		req.Username,
-		string(hashedPassword),
-	).Scan(&userID)
-
+	// Insert user into database, failing if the username already exists
+	created, err := s.Store.CreateUser(ctx, req.Username, string(hashedPassword))
 	if err != nil {
+		if errors.Is(err, db.ErrConflict) {
+			return nil, status.Error(codes.AlreadyExists, "username already exists")
+		}
 		return nil, status.Error(codes.Internal, fmt.Sprintf("failed to create user: %v", err))
 	}
 
 	// Update in-memory map for active users
 	s.Mutex.Lock()
-	s.Users[userID] = &User{
-		ID:       userID,
+	s.Users[created.ID] = &User{
+		ID:       created.ID,
 		Username: req.Username,
 		Password: string(hashedPassword),
 	}
 	s.Mutex.Unlock()
 
+	token, expiresAt, err := s.issueToken(ctx, created.ID)
+	if err != nil {
+		log.Printf("Failed to start session: %v", err)
+		return nil, status.Error(codes.Internal, "failed to start session")
+	}
+
 	return &pb.CreateUserResponse{
-		UserId:   userID,
-		Username: req.Username,
+		UserId:    created.ID,
+		Username:  req.Username,
+		Token:     token,
+		ExpiresAt: expiresAt,
 	}, nil
 }
 
 // LoginUser authenticates a user
 func (s *Server) LoginUser(ctx context.Context, req *pb.LoginUserRequest) (*pb.CreateUserResponse, error) {
-	var (
-		userID   int32
-		username string
-		password string
-	)
-
-	// Get user from database
-	err := s.DB.QueryRow(
-		"SELECT id, username, password FROM users WHERE username = $1",
-		req.Username,
-	).Scan(&userID, &username, &password)
+	// refuse attempts while the username or address is backing off
+	subjects := loginSubjects(ctx, req.Username)
+	if err := s.checkLoginAllowed(ctx, subjects); err != nil {
+		return nil, err
+	}
 
+	// Get user from database, unknown usernames count as failures too
+	stored, err := s.Store.GetUserByUsername(ctx, req.Username)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, db.ErrNotFound) {
+			s.recordLoginFailure(ctx, subjects)
 			return nil, status.Error(codes.Unauthenticated, "invalid username or password")
 		}
 		return nil, status.Error(codes.Internal, fmt.Sprintf("database error: %v", err))
 	}
 
 	// Compare passwords
-	err = bcrypt.CompareHashAndPassword([]byte(password), []byte(req.Password))
+	err = bcrypt.CompareHashAndPassword([]byte(stored.PasswordHash), []byte(req.Password))
 	if err != nil {
+		s.recordLoginFailure(ctx, subjects)
 		return nil, status.Error(codes.Unauthenticated, "invalid username or password")
 	}
 
+	// the address keeps its count so one known account cannot reset it
+	if err := s.Store.ClearLoginFailure(ctx, LoginSubject(stored.Username)); err != nil {
+		log.Printf("Failed to clear login failures: %v", err)
+	}
+
 	// Add user to in-memory cache if not present
 	s.Mutex.Lock()
-	if _, exists := s.Users[userID]; !exists {
-		s.Users[userID] = &User{
-			ID:       userID,
-			Username: username,
-			Password: password,
+	if _, exists := s.Users[stored.ID]; !exists {
+		s.Users[stored.ID] = &User{
+			ID:       stored.ID,
+			Username: stored.Username,
+			Password: stored.PasswordHash,
 		}
 	}
 	s.Mutex.Unlock()
 
+	token, expiresAt, err := s.issueToken(ctx, stored.ID)
+	if err != nil {
+		log.Printf("Failed to start session: %v", err)
+		return nil, status.Error(codes.Internal, "failed to start session")
+	}
+
 	return &pb.CreateUserResponse{
-		UserId:   userID,
-		Username: username,
+		UserId:    stored.ID,
+		Username:  stored.Username,
+		Token:     token,
+		ExpiresAt: expiresAt,
 	}, nil
 }
 
@@ -117,29 +127,19 @@ func (s *Server) ChangeUsername(ctx context.Context, req *pb.ChangeUsernameReque
 	if req.NewUsername == "" {
 		return nil, status.Error(codes.InvalidArgument, "new username cannot be empty")
 	}
+	if isReservedUsername(req.NewUsername) {
+		return nil, status.Error(codes.InvalidArgument, "username is reserved")
+	}
+
+	userID := callerID(ctx)
 
 	s.Mutex.Lock()
 	defer s.Mutex.Unlock()
 
-	// Check if the new username already exists
-	var exists bool
-	err := s.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE username = $1 AND id != $2)", 
-		req.NewUsername, req.UserId).Scan(&exists)
-	if err != nil {
-		return nil, status.Error(codes.Internal, fmt.Sprintf("database error: %v", err))
-	}
-	if exists {
-		return &pb.ChangeUsernameResponse{
-			Success: false,
-			Message: "username already taken",
-		}, nil
-	}
-
 	// Get old username
-	var oldUsername string
-	err = s.DB.QueryRow("SELECT username FROM users WHERE id = $1", req.UserId).Scan(&oldUsername)
+	stored, err := s.Store.GetUser(ctx, userID)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, db.ErrNotFound) {
 			return &pb.ChangeUsernameResponse{
 				Success: false,
 				Message: "user not found",
@@ -147,37 +147,39 @@ func (s *Server) ChangeUsername(ctx context.Context, req *pb.ChangeUsernameReque
 		}
 		return nil, status.Error(codes.Internal, fmt.Sprintf("database error: %v", err))
 	}
+	oldUsername := stored.Username
 
-	// Update username in database
-	_, err = s.DB.Exec("UPDATE users SET username = $1 WHERE id = $2", req.NewUsername, req.UserId)
+	// Update username in database, failing if the new one is already taken
+	err = s.Store.UpdateUsername(ctx, userID, req.NewUsername)
 	if err != nil {
+		if errors.Is(err, db.ErrConflict) {
+			return &pb.ChangeUsernameResponse{
+				Success: false,
+				Message: "username already taken",
+			}, nil
+		}
 		return nil, status.Error(codes.Internal, fmt.Sprintf("failed to update username: %v", err))
 	}
 
+	s.audit(ctx, &db.AuditEvent{
+		Action:     db.AuditRename,
+		ActorName:  oldUsername,
+		TargetID:   userID,
+		TargetName: req.NewUsername,
+		Details:    "from " + oldUsername,
+	})
+
 	// Update in-memory user
-	user, exists := s.Users[req.UserId]
+	user, exists := s.Users[userID]
 	if exists {
 		user.Username = req.NewUsername
 	}
 
 	// Broadcast username change to all rooms the user is in
 	for _, room := range s.Rooms {
-		if _, ok := room.Users[req.UserId]; ok {
+		if _, ok := room.Users[userID]; ok {
 			// Broadcast username change to all users in the room
-			systemMsg := &pb.ReceiveMessageResponse{
-				MessageId:  s.NextMsgID,
-				Username:   "SYSTEM",
-				Message:    fmt.Sprintf("%s changed their username to %s", oldUsername, req.NewUsername),
-				Timestamp:  time.Now().Unix(),
-				IsSystem:   true,
-				IsDirect:   false,
-			}
-			s.NextMsgID++
-			for _, client := range room.Clients {
-				if err := client.Send(systemMsg); err != nil {
-					log.Printf("Failed to send system message: %v", err)
-				}
-			}
+			s.broadcastSystemMessage(ctx, room, fmt.Sprintf("%s changed their username to %s", oldUsername, req.NewUsername))
 		}
 	}
 
@@ -189,6 +191,10 @@ func (s *Server) ChangeUsername(ctx context.Context, req *pb.ChangeUsernameReque
 
 // func returns a list of users in a room
 func (s *Server) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error) {
+	if err := s.checkReadAccess(ctx, req.RoomId); err != nil {
+		return nil, err
+	}
+
 	s.Mutex.RLock()
 	defer s.Mutex.RUnlock()
 
@@ -201,18 +207,23 @@ func (s *Server) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.L
 	
 	// for each user in the room, fetch their latest info from the database
 	for id := range room.Users {
-		var username string
-		err := s.DB.QueryRow("SELECT username FROM users WHERE id = $1", id).Scan(&username)
+		user, err := s.Store.GetUser(ctx, id)
 		if err != nil {
 			log.Printf("Error fetching username for user ID %d: %v", id, err)
 			continue
 		}
 		
+		role, err := s.roomRole(ctx, room, id)
+		if err != nil {
+			return nil, err
+		}
+		
 		users = append(users, &pb.UserInfo{
 			UserId:     id,
-			Username:   username,
+			Username:   user.Username,
 			//rn we're using just current time
 			LastActive: time.Now().Unix(),
+			Role:       roleToProto(role),
 		})
 	}
 